@@ -0,0 +1,516 @@
+package didagle
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const COMPLETION_PROCESSOR = "processor"
+const COMPLETION_VERTEX = "vertex"
+const COMPLETION_DATA = "data"
+const COMPLETION_CONFIG = "config"
+const COMPLETION_GRAPH = "graph"
+const COMPLETION_FIELD = "field"
+
+// Completion is a candidate for the value at a position, Kind is one of COMPLETION_*.
+type Completion struct {
+	Label  string `json:"label"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ScriptAnalysis answers editor queries on a script content, it's available even if the script fails to build,
+// then vertices/data/configs are collected from the script text instead of the built graphs.
+type ScriptAnalysis struct {
+	idx         *tomlIndex
+	cluster     *GraphCluster
+	built       bool
+	diagnostics []Diagnostic
+}
+
+var vertexPathRegex = regexp.MustCompile(`^graph\[(\d+)\]\.vertex\[(\d+)\]`)
+var tomlIndexRegex = regexp.MustCompile(`\[\d+\]`)
+
+// AnalyzeScript builds the script content with the op metas and indexes it for position queries.
+func AnalyzeScript(opMeta []OperatorMeta, content string) *ScriptAnalysis {
+	config := &DAGConfig{opMeta: opMeta}
+	err := config.loadTomlScriptContent(content)
+	p := &ScriptAnalysis{idx: newTomlIndex(content), cluster: &config.graph}
+	if nil == p.cluster.opsMap {
+		p.cluster.opsMap = make(map[string]OperatorMeta)
+		for _, op := range opMeta {
+			p.cluster.opsMap[op.FullName()] = op
+		}
+	}
+	var scriptErr *ScriptError
+	if nil == err || !errors.As(err, &scriptErr) {
+		p.diagnostics = append(p.diagnostics, config.Warnings()...)
+	}
+	if nil != err {
+		p.diagnostics = append(p.diagnostics, p.locateError(content, err)...)
+		return p
+	}
+	p.built = true
+	for _, issue := range config.Lint() {
+		diag := Diagnostic{Severity: DIAG_WARNING, Message: fmt.Sprintf("%s: %s", issue.Rule, issue.Message)}
+		diag.Path = p.vertexPath(issue.Graph, issue.Vertex)
+		if len(diag.Path) == 0 {
+			diag.Path = p.graphPath(issue.Graph)
+		}
+		diag.Position = p.idx.keyPosition(diag.Path)
+		p.diagnostics = append(p.diagnostics, diag)
+	}
+	return p
+}
+
+// Diagnostics returns build errors, unknown keys and lint issues located in the script.
+func (p *ScriptAnalysis) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+// LocateError converts the error of building the script content to diagnostics located in the content.
+func LocateError(content string, err error) []Diagnostic {
+	p := &ScriptAnalysis{idx: newTomlIndex(content)}
+	return p.locateError(content, err)
+}
+
+// locateError converts the build error to diagnostics, a BuildError is located at the value of its key
+// in the vertex/graph, or the key itself if the value is empty.
+func (p *ScriptAnalysis) locateError(content string, err error) []Diagnostic {
+	var scriptErr *ScriptError
+	if errors.As(err, &scriptErr) {
+		return scriptErr.Diagnostics
+	}
+	diag := Diagnostic{Severity: DIAG_ERROR, Message: err.Error(), Position: Position{Line: 1, Column: 1}}
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) && parseErr.Position.Start <= len(content) {
+		prefix := content[:parseErr.Position.Start]
+		diag.Line = strings.Count(prefix, "\n") + 1
+		diag.Column = len(prefix) - strings.LastIndexByte(prefix, '\n')
+		return []Diagnostic{diag}
+	}
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		return []Diagnostic{diag}
+	}
+	base := p.graphPath(buildErr.Graph)
+	if len(buildErr.Vertex) > 0 {
+		if path := p.vertexPath(buildErr.Graph, buildErr.Vertex); len(path) > 0 {
+			base = path
+		}
+	}
+	if len(buildErr.Graph) > 0 && len(base) == 0 {
+		return []Diagnostic{diag}
+	}
+	if len(buildErr.Value) > 0 {
+		diag.Path = p.findValue(base, buildErr.Key, buildErr.Value, buildErr.Duplicate)
+	}
+	if len(diag.Path) == 0 {
+		diag.Path = joinKey(base, buildErr.Key)
+	}
+	if len(diag.Path) > 0 {
+		diag.Position = p.idx.keyPosition(diag.Path)
+	}
+	return []Diagnostic{diag}
+}
+
+func joinKey(base string, key string) string {
+	if len(base) == 0 || len(key) == 0 {
+		return base + key
+	}
+	return base + "." + key
+}
+
+func (p *ScriptAnalysis) graphPath(name string) string {
+	for i := 0; i < p.idx.tables["graph"]; i++ {
+		if path := fmt.Sprintf("graph[%d]", i); p.idx.texts[path+".name"] == name {
+			return path
+		}
+	}
+	return ""
+}
+
+// vertexPath returns the path like 'graph[0].vertex[1]' of the vertex in the graph, or in any graph if graph is empty.
+func (p *ScriptAnalysis) vertexPath(graph string, id string) string {
+	for i := 0; i < p.idx.tables["graph"]; i++ {
+		if len(graph) > 0 && p.idx.texts[fmt.Sprintf("graph[%d].name", i)] != graph {
+			continue
+		}
+		table := fmt.Sprintf("graph[%d].vertex", i)
+		for j := 0; j < p.idx.tables[table]; j++ {
+			path := fmt.Sprintf("%s[%d]", table, j)
+			if p.scriptVertexId(path) == id {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// findValue returns the path of the first(or last) value with the text under the key of the base path, the key
+// is relative to the base without array indexes, any key matches if it's empty.
+func (p *ScriptAnalysis) findValue(base string, key string, text string, last bool) string {
+	prefix := ""
+	if len(base) > 0 {
+		prefix = base + "."
+	}
+	var paths []string
+	for path, value := range p.idx.texts {
+		if value != text || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		rel := tomlIndexRegex.ReplaceAllString(path[len(prefix):], "")
+		if len(key) == 0 || rel == key || strings.HasPrefix(rel, key+".") {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return ""
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return p.idx.values[paths[i]].before(p.idx.values[paths[j]])
+	})
+	if last {
+		return paths[len(paths)-1]
+	}
+	return paths[0]
+}
+
+func (p *ScriptAnalysis) scriptVertexId(path string) string {
+	if id := p.idx.texts[path+".id"]; len(id) > 0 {
+		return id
+	}
+	return p.idx.texts[path+".processor"]
+}
+
+// valueContext describes the value at a position: key is the path relative to the vertex(or the root)
+// without array indexes, like 'deps', 'input.id' or 'select_args.match'.
+type valueContext struct {
+	path   string
+	graph  string
+	vertex string
+	key    string
+	text   string
+}
+
+func (p *ScriptAnalysis) contextAt(pos Position) *valueContext {
+	path := p.idx.valueAt(pos)
+	if len(path) == 0 {
+		return nil
+	}
+	ctx := &valueContext{path: path, text: p.idx.texts[path]}
+	rest := path
+	if m := vertexPathRegex.FindStringSubmatch(path); nil != m {
+		ctx.graph = p.idx.texts["graph["+m[1]+"].name"]
+		ctx.vertex = m[0]
+		rest = strings.TrimPrefix(path[len(m[0]):], ".")
+	} else if strings.HasPrefix(path, "graph[") {
+		idx := strings.Index(path, "]")
+		ctx.graph = p.idx.texts[path[:idx+1]+".name"]
+		rest = strings.TrimPrefix(path[idx+1:], ".")
+	}
+	ctx.key = tomlIndexRegex.ReplaceAllString(rest, "")
+	return ctx
+}
+
+// dataPrefix returns the path of the input/output entry like 'graph[0].vertex[1].input[2]'.
+func (p *valueContext) dataPrefix() string {
+	idx := strings.LastIndex(p.path, "put[")
+	if idx < 0 {
+		return ""
+	}
+	end := strings.Index(p.path[idx:], "]")
+	return p.path[:idx+end+1]
+}
+
+func (p *ScriptAnalysis) processors() []Completion {
+	completions := make([]Completion, 0, len(p.cluster.opsMap))
+	for name := range p.cluster.opsMap {
+		op := p.cluster.opsMap[name]
+		completions = append(completions, Completion{Label: name, Kind: COMPLETION_PROCESSOR, Detail: opSignature(&op)})
+	}
+	return completions
+}
+
+func (p *ScriptAnalysis) vertexs(graph string, exclude string) []Completion {
+	var completions []Completion
+	if g := p.builtGraph(graph); nil != g {
+		for _, v := range g.sortedVertexs() {
+			if !v.isGenerated && v.ID != exclude {
+				completions = append(completions, Completion{Label: v.ID, Kind: COMPLETION_VERTEX, Detail: v.Processor})
+			}
+		}
+		return completions
+	}
+	for i := 0; i < p.idx.tables["graph"]; i++ {
+		if p.idx.texts[fmt.Sprintf("graph[%d].name", i)] != graph {
+			continue
+		}
+		table := fmt.Sprintf("graph[%d].vertex", i)
+		for j := 0; j < p.idx.tables[table]; j++ {
+			path := fmt.Sprintf("%s[%d]", table, j)
+			if id := p.scriptVertexId(path); len(id) > 0 && id != exclude {
+				completions = append(completions, Completion{Label: id, Kind: COMPLETION_VERTEX, Detail: p.idx.texts[path+".processor"]})
+			}
+		}
+	}
+	return completions
+}
+
+func (p *ScriptAnalysis) datas(graph string) []Completion {
+	var completions []Completion
+	if g := p.builtGraph(graph); nil != g {
+		for id, v := range g.dataMapping {
+			completions = append(completions, Completion{Label: id, Kind: COMPLETION_DATA, Detail: "produced by " + v.ID})
+		}
+		return completions
+	}
+	for i := 0; i < p.idx.tables["graph"]; i++ {
+		if p.idx.texts[fmt.Sprintf("graph[%d].name", i)] != graph {
+			continue
+		}
+		table := fmt.Sprintf("graph[%d].vertex", i)
+		for j := 0; j < p.idx.tables[table]; j++ {
+			path := fmt.Sprintf("%s[%d]", table, j)
+			for k := 0; ; k++ {
+				output := fmt.Sprintf("%s.output[%d]", path, k)
+				if _, exist := p.idx.values[output]; !exist {
+					break
+				}
+				id := p.idx.texts[output+".id"]
+				if len(id) == 0 {
+					id = p.idx.texts[output+".field"]
+				}
+				completions = append(completions, Completion{Label: id, Kind: COMPLETION_DATA, Detail: "produced by " + p.scriptVertexId(path)})
+			}
+		}
+	}
+	return completions
+}
+
+func (p *ScriptAnalysis) configs() []Completion {
+	var completions []Completion
+	for _, config := range p.cluster.ConfigSetting {
+		completions = append(completions, Completion{Label: config.Name, Kind: COMPLETION_CONFIG, Detail: config.Cond})
+	}
+	return completions
+}
+
+func (p *ScriptAnalysis) graphs() []Completion {
+	var completions []Completion
+	for i := range p.cluster.Graph {
+		completions = append(completions, Completion{Label: p.cluster.Graph[i].Name, Kind: COMPLETION_GRAPH})
+	}
+	return completions
+}
+
+func (p *ScriptAnalysis) fields(op *OperatorMeta, output bool) []Completion {
+	if nil == op {
+		return nil
+	}
+	fields := op.Input
+	if output {
+		fields = op.Output
+	}
+	completions := make([]Completion, 0, len(fields))
+	for _, field := range fields {
+		completions = append(completions, Completion{Label: field.Name, Kind: COMPLETION_FIELD, Detail: field.Type})
+	}
+	return completions
+}
+
+func (p *ScriptAnalysis) builtGraph(name string) *Graph {
+	if !p.built {
+		return nil
+	}
+	return p.cluster.getGraphByName(name)
+}
+
+func (p *ScriptAnalysis) vertexOp(ctx *valueContext) *OperatorMeta {
+	return p.cluster.getOpMeta(p.idx.texts[ctx.vertex+".processor"])
+}
+
+// Complete returns the candidates for the value at the position, sorted by label.
+func (p *ScriptAnalysis) Complete(pos Position) []Completion {
+	ctx := p.contextAt(pos)
+	if nil == ctx {
+		return nil
+	}
+	var completions []Completion
+	switch ctx.key {
+	case "processor", "fallback":
+		completions = p.processors()
+	case "deps", "deps_on_ok", "deps_on_err", "if", "else", "successor":
+		completions = p.vertexs(ctx.graph, p.scriptVertexId(ctx.vertex))
+	case "expect_config", "select_args.match":
+		completions = p.configs()
+	case "input.id", "input.aggregate":
+		completions = p.datas(ctx.graph)
+	case "input.field":
+		completions = p.fields(p.vertexOp(ctx), false)
+	case "output.field":
+		completions = p.fields(p.vertexOp(ctx), true)
+	case "graph":
+		if len(ctx.vertex) > 0 {
+			completions = p.graphs()
+		}
+	}
+	sort.Slice(completions, func(i, j int) bool {
+		return completions[i].Label < completions[j].Label
+	})
+	return completions
+}
+
+// producerPath returns the path of the vertex producing the data in the graph.
+func (p *ScriptAnalysis) producerPath(graph string, data string) string {
+	if g := p.builtGraph(graph); nil != g {
+		v := g.getVertexByData(data)
+		if nil == v {
+			return ""
+		}
+		if path := p.vertexPath(graph, v.ID); len(path) > 0 {
+			return path
+		}
+		// generated by templates or fan_out, like 'template:vertex' or 'fan_out:vertex'
+		from := v.expandedFrom[strings.LastIndex(v.expandedFrom, ":")+1:]
+		return p.vertexPath(graph, from)
+	}
+	for _, c := range p.datas(graph) {
+		if c.Label == data {
+			return p.vertexPath(graph, strings.TrimPrefix(c.Detail, "produced by "))
+		}
+	}
+	return ""
+}
+
+// Definition returns the position defining the value at the position: the producer vertex of a data id,
+// the vertex of a dep/successor id, the config_setting of expect_config/select_args or the sub graph.
+func (p *ScriptAnalysis) Definition(pos Position) (Position, bool) {
+	ctx := p.contextAt(pos)
+	if nil == ctx || len(ctx.text) == 0 {
+		return Position{}, false
+	}
+	path := ""
+	switch ctx.key {
+	case "deps", "deps_on_ok", "deps_on_err", "if", "else", "successor":
+		path = p.vertexPath(ctx.graph, ctx.text)
+	case "input.id", "input.aggregate":
+		path = p.producerPath(ctx.graph, ctx.text)
+	case "input.field":
+		if len(p.idx.texts[ctx.dataPrefix()+".id"]) == 0 {
+			path = p.producerPath(ctx.graph, ctx.text)
+		}
+	case "expect_config", "select_args.match":
+		name := strings.TrimPrefix(ctx.text, "!")
+		for i := 0; i < p.idx.tables["config_setting"]; i++ {
+			if config := fmt.Sprintf("config_setting[%d]", i); p.idx.texts[config+".name"] == name {
+				path = config
+			}
+		}
+	case "graph":
+		if len(ctx.vertex) > 0 {
+			path = p.graphPath(ctx.text)
+		}
+	}
+	if len(path) == 0 {
+		return Position{}, false
+	}
+	return p.idx.keyPosition(path), true
+}
+
+func opSignature(op *OperatorMeta) string {
+	names := func(fields []FieldMeta) string {
+		s := make([]string, 0, len(fields))
+		for _, field := range fields {
+			s = append(s, field.Name)
+		}
+		return strings.Join(s, ", ")
+	}
+	return "(" + names(op.Input) + ") -> (" + names(op.Output) + ")"
+}
+
+func opMarkdown(op *OperatorMeta) string {
+	var b strings.Builder
+	b.WriteString("**" + op.FullName() + "**\n")
+	for _, kind := range []string{"input", "output"} {
+		fields := op.Input
+		if kind == "output" {
+			fields = op.Output
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		b.WriteString("\n" + kind + ":\n")
+		for _, field := range fields {
+			b.WriteString("- `" + field.Name + "` " + field.Type)
+			if field.Flags.Extern == 1 {
+				b.WriteString(" extern")
+			}
+			if field.Flags.Agrregate == 1 {
+				b.WriteString(" aggregate")
+			}
+			if field.Flags.InOut == 1 {
+				b.WriteString(" in_out")
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Hover returns markdown describing the value at the position: the operator of a processor, the type of
+// an input/output field, or the processor of a referred vertex.
+func (p *ScriptAnalysis) Hover(pos Position) string {
+	ctx := p.contextAt(pos)
+	if nil == ctx || len(ctx.text) == 0 {
+		return ""
+	}
+	switch ctx.key {
+	case "processor", "fallback":
+		if op := p.cluster.getOpMeta(ctx.text); nil != op {
+			return opMarkdown(op)
+		}
+	case "input.id", "input.field", "input.aggregate", "output.id", "output.field":
+		prefix := ctx.dataPrefix()
+		field := p.idx.texts[prefix+".field"]
+		output := strings.HasPrefix(ctx.key, "output")
+		var lines []string
+		if op := p.vertexOp(ctx); nil != op {
+			fields := op.Input
+			if output {
+				fields = op.Output
+			}
+			for _, f := range fields {
+				if f.Name == field {
+					lines = append(lines, "`"+field+"` "+f.Type+" of **"+op.FullName()+"**")
+				}
+			}
+		}
+		if !output {
+			data := p.idx.texts[prefix+".id"]
+			if ctx.key == "input.aggregate" || len(data) == 0 {
+				data = ctx.text
+			}
+			if producer := p.producerPath(ctx.graph, data); len(producer) > 0 {
+				lines = append(lines, "produced by vertex `"+p.scriptVertexId(producer)+"`")
+			}
+		}
+		return strings.Join(lines, "\n\n")
+	case "deps", "deps_on_ok", "deps_on_err", "if", "else", "successor":
+		if path := p.vertexPath(ctx.graph, ctx.text); len(path) > 0 {
+			line := strconv.Itoa(p.idx.keyPosition(path).Line)
+			processor := p.idx.texts[path+".processor"]
+			if op := p.cluster.getOpMeta(processor); nil != op {
+				return "vertex `" + ctx.text + "` at line " + line + "\n\n" + opMarkdown(op)
+			}
+			return "vertex `" + ctx.text + "` at line " + line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,178 @@
+package didagle
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testAnalysisScript = `
+[[config_setting]]
+name = "on"
+cond = "x==1"
+[[graph]]
+name = "main"
+[[graph.vertex]]
+processor = "gen"
+output = [{ field = "x" }]
+successor = ["use"]
+[[graph.vertex]]
+processor = "use"
+input = [{ field = "x" }]
+output = [{ field = "y" }]
+successor = ["sink"]
+[[graph.vertex]]
+processor = "sink"
+input = [{ field = "y", id = "y" }]
+expect_config = "on"
+`
+
+// positionOf returns the position inside the n-th occurrence of the needle in the content.
+func positionOf(t *testing.T, content string, needle string, n int) Position {
+	t.Helper()
+	offset := 0
+	for i := 0; i <= n; i++ {
+		idx := strings.Index(content[offset:], needle)
+		if idx < 0 {
+			t.Fatalf("No %s in script", needle)
+		}
+		if i < n {
+			offset += idx + len(needle)
+		} else {
+			offset += idx
+		}
+	}
+	prefix := content[:offset]
+	return Position{Line: strings.Count(prefix, "\n") + 1, Column: offset - strings.LastIndexByte(prefix, '\n') + 1}
+}
+
+func parseTestOps(t *testing.T) []OperatorMeta {
+	t.Helper()
+	ops, err := DecodeOpMeta(strings.NewReader(testChainOps))
+	if nil != err {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+func TestAnalyzeDiagnostics(t *testing.T) {
+	ops := parseTestOps(t)
+	if diags := AnalyzeScript(ops, testAnalysisScript).Diagnostics(); len(diags) > 0 {
+		t.Fatalf("Unexpected diagnostics:%v", diags)
+	}
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		message string
+		strict  bool
+	}{
+		{"successor", `successor = ["sink"]`, `successor = ["nope"]`, "No successor id:nope", false},
+		{"processor", `processor = "sink"`, `id = "sink"` + "\n" + `processor = "nope"`, "No Processor:nope found", true},
+		{"expect_config", `expect_config = "on"`, `expect_config = "nope"`, "No config_setting with name:nope", false},
+		{"input", `id = "y"`, `id = "nope"`, "No dep input id:nope", false},
+		{"deps", `successor = ["use"]`, `deps = ["nope"]` + "\n" + `successor = ["use"]`, "No dep vertex id:nope", false},
+		{"retry", `expect_config = "on"`, `expect_config = "on"` + "\nretry = -1", "Invalid retry", false},
+		{"duplicate graph", `name = "main"`, `name = "main"` + "\n[[graph]]\nname = \"main\"", "Duplicate graph name", false},
+	}
+	for _, test := range tests {
+		script := strings.Replace(testAnalysisScript, test.old, test.new, 1)
+		if test.strict {
+			script = "strict_dsl = true\n" + script
+		}
+		diags := AnalyzeScript(ops, script).Diagnostics()
+		if len(diags) != 1 || !strings.Contains(diags[0].Message, test.message) {
+			t.Errorf("%s: unexpected diagnostics:%v", test.name, diags)
+			continue
+		}
+		needle := "nope"
+		n := 0
+		switch test.name {
+		case "retry":
+			needle = "retry"
+		case "duplicate graph":
+			needle, n = `"main"`, 1
+		}
+		if want := positionOf(t, script, needle, n); diags[0].Line != want.Line {
+			t.Errorf("%s: diagnostic at line %d, want line %d", test.name, diags[0].Line, want.Line)
+		}
+	}
+}
+
+func TestAnalyzeComplete(t *testing.T) {
+	ops := parseTestOps(t)
+	a := AnalyzeScript(ops, testAnalysisScript)
+	labels := func(completions []Completion) []string {
+		var s []string
+		for _, c := range completions {
+			s = append(s, c.Label)
+		}
+		return s
+	}
+	tests := []struct {
+		needle string
+		n      int
+		want   []string
+	}{
+		{`"sink"]`, 0, []string{"gen", "sink"}},
+		{`"gen"`, 0, []string{"gen", "other", "sink", "use"}},
+		{`"on"`, 1, []string{"on"}},
+		{`"y" }`, 0, []string{"y"}},
+		{`"y" }`, 1, []string{"x", "y"}},
+		{`field = "x" }]
+output`, 0, []string{"x"}},
+	}
+	for _, test := range tests {
+		pos := positionOf(t, testAnalysisScript, test.needle, test.n)
+		if strings.HasPrefix(test.needle, "field") {
+			pos.Column += len(`field = "`)
+		}
+		if got := labels(a.Complete(pos)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: completions:%v, want:%v", test.needle, got, test.want)
+		}
+	}
+}
+
+func TestAnalyzeDefinition(t *testing.T) {
+	a := AnalyzeScript(parseTestOps(t), testAnalysisScript)
+	tests := []struct {
+		needle string
+		n      int
+		want   string
+		wantN  int
+	}{
+		{`"use"]`, 0, `[[graph.vertex]]`, 1},
+		{`"y" }`, 1, `[[graph.vertex]]`, 1},
+		{`"on"`, 1, `[[config_setting]]`, 0},
+	}
+	for _, test := range tests {
+		got, exist := a.Definition(positionOf(t, testAnalysisScript, test.needle, test.n))
+		want := positionOf(t, testAnalysisScript, test.want, test.wantN)
+		if !exist || got.Line != want.Line {
+			t.Errorf("%s: definition at line %d, want line %d", test.needle, got.Line, want.Line)
+		}
+	}
+	if _, exist := a.Definition(positionOf(t, testAnalysisScript, `"x==1"`, 0)); exist {
+		t.Errorf("Expect no definition for cond")
+	}
+}
+
+func TestAnalyzeHover(t *testing.T) {
+	a := AnalyzeScript(parseTestOps(t), testAnalysisScript)
+	tests := []struct {
+		needle string
+		want   []string
+	}{
+		{`"gen"`, []string{"**gen**", "- `x` int"}},
+		{`"y", id`, []string{"`y` int of **sink**", "produced by vertex `use`"}},
+		{`"sink"]`, []string{"vertex `sink` at line 16", "**sink**"}},
+	}
+	for _, test := range tests {
+		got := a.Hover(positionOf(t, testAnalysisScript, test.needle, 0))
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: hover:%q, want:%q", test.needle, got, want)
+			}
+		}
+	}
+}
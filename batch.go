@@ -0,0 +1,419 @@
+package didagle
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultOpMetaFileNames are the op meta file names searched from a script's directory up to the walked
+// directory when the script is not matched by any manifest rule.
+var DefaultOpMetaFileNames = []string{"op_meta.json", "all_processors.json"}
+
+// ManifestRule pairs scripts matching the slash separated glob pattern(relative to the manifest file)
+// with an op meta file or a directory of op meta files.
+type ManifestRule struct {
+	Pattern string `json:"pattern"`
+	Meta    string `json:"meta"`
+}
+
+// Manifest is the json file pairing scripts with op meta files in a script repository.
+type Manifest struct {
+	Rules []ManifestRule `json:"rules"`
+	// Fragments are glob patterns of scripts only valid when included, they are not built standalone.
+	// Scripts included by other scripts in the repository are fragments too.
+	Fragments []string `json:"fragments"`
+
+	dir string
+}
+
+func LoadManifest(file string) (*Manifest, error) {
+	content, err := os.ReadFile(file)
+	if nil != err {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(content, manifest); nil != err {
+		return nil, fmt.Errorf("Failed to parse manifest:%s with err:%v", file, err)
+	}
+	manifest.dir = filepath.Dir(file)
+	patterns := manifest.Fragments
+	for _, rule := range manifest.Rules {
+		patterns = append(patterns, rule.Pattern)
+	}
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); nil != err {
+			return nil, fmt.Errorf("Invalid pattern:%s in manifest:%s", pattern, file)
+		}
+	}
+	return manifest, nil
+}
+
+func (p *Manifest) rel(script string) string {
+	rel, err := filepath.Rel(p.dir, script)
+	if nil != err {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (p *Manifest) match(script string) string {
+	rel := p.rel(script)
+	for _, rule := range p.Rules {
+		if ok, _ := filepath.Match(rule.Pattern, rel); ok && len(rel) > 0 {
+			return filepath.Join(p.dir, rule.Meta)
+		}
+	}
+	return ""
+}
+
+func (p *Manifest) isFragment(script string) bool {
+	rel := p.rel(script)
+	for _, pattern := range p.Fragments {
+		if ok, _ := filepath.Match(pattern, rel); ok && len(rel) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// BatchOptions controls how a script repository is validated.
+type BatchOptions struct {
+	// Dirs are walked recursively for '*.toml' scripts.
+	Dirs []string
+	// Manifest pairs scripts with op meta files, scripts not matched fallback to the DefaultMeta & convention.
+	Manifest *Manifest
+	// DefaultMeta is used for scripts not paired by manifest or convention, comma separated op meta files or directories.
+	DefaultMeta string
+	// Parallel is the number of scripts built concurrently, default to the number of CPUs.
+	Parallel int
+}
+
+// ScriptReport is the validation result of one script.
+type ScriptReport struct {
+	Script   string        `json:"script"`
+	Meta     string        `json:"meta,omitempty"`
+	Errors   []string      `json:"errors,omitempty"`
+	Duration time.Duration `json:"duration"`
+
+	// root is the walked directory containing the script
+	root   string
+	config *DAGConfig
+}
+
+func (p *ScriptReport) Ok() bool {
+	return len(p.Errors) == 0
+}
+
+// BatchReport is the consolidated validation result of a script repository.
+type BatchReport struct {
+	Scripts []*ScriptReport `json:"scripts"`
+	Passed  int             `json:"passed"`
+	Failed  int             `json:"failed"`
+}
+
+func (p *BatchReport) Ok() bool {
+	return p.Failed == 0
+}
+
+// findOpMeta searches op meta files from the script's directory up to the root directory, files outside
+// the repository are never paired.
+func findOpMeta(script string, root string) string {
+	dir, err := filepath.Abs(filepath.Dir(script))
+	if nil != err {
+		return ""
+	}
+	root, err = filepath.Abs(root)
+	if nil != err {
+		return ""
+	}
+	for {
+		for _, name := range DefaultOpMetaFileNames {
+			file := filepath.Join(dir, name)
+			if _, err := os.Stat(file); nil == err {
+				return file
+			}
+		}
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func (p *BatchOptions) pairOpMeta(script string, root string) string {
+	if nil != p.Manifest {
+		if meta := p.Manifest.match(script); len(meta) > 0 {
+			return meta
+		}
+	}
+	if meta := findOpMeta(script, root); len(meta) > 0 {
+		return meta
+	}
+	return p.DefaultMeta
+}
+
+func (p *BatchOptions) collectScripts() ([]*ScriptReport, error) {
+	var scripts []*ScriptReport
+	for _, dir := range p.Dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if nil != err {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(path, ".toml") {
+				scripts = append(scripts, &ScriptReport{Script: path, root: dir})
+			}
+			return nil
+		})
+		if nil != err {
+			return nil, err
+		}
+	}
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Script < scripts[j].Script
+	})
+	fragments, err := includedScripts(scripts)
+	if nil != err {
+		return nil, err
+	}
+	var standalone []*ScriptReport
+	for _, rs := range scripts {
+		abs, err := filepath.Abs(rs.Script)
+		if nil != err {
+			return nil, err
+		}
+		if fragments[abs] || (nil != p.Manifest && p.Manifest.isFragment(rs.Script)) {
+			continue
+		}
+		standalone = append(standalone, rs)
+	}
+	return standalone, nil
+}
+
+// includedScripts returns the absolute paths of scripts included by the scripts, decode errors are
+// ignored here and reported when the scripts are built.
+func includedScripts(scripts []*ScriptReport) (map[string]bool, error) {
+	included := make(map[string]bool)
+	for _, rs := range scripts {
+		var cluster struct {
+			Include []string `toml:"include"`
+		}
+		if _, err := toml.DecodeFile(rs.Script, &cluster); nil != err {
+			continue
+		}
+		for _, file := range cluster.Include {
+			path := file
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(rs.Script), file)
+			}
+			abs, err := filepath.Abs(path)
+			if nil != err {
+				return nil, err
+			}
+			included[abs] = true
+		}
+	}
+	return included, nil
+}
+
+// visibleClusters returns the clusters a script refers by name in sub graph vertices: the cluster of the
+// script in the same directory, or the only cluster with the name in the repository. clusters are keyed
+// by the script path.
+func visibleClusters(script string, clusters map[string]*GraphCluster) map[string]*GraphCluster {
+	visible := make(map[string]*GraphCluster)
+	local := make(map[string]bool)
+	count := make(map[string]int)
+	for path, cluster := range clusters {
+		name := filepath.Base(path)
+		if filepath.Dir(path) == filepath.Dir(script) {
+			visible[name] = cluster
+			local[name] = true
+			continue
+		}
+		count[name]++
+		if !local[name] {
+			visible[name] = cluster
+		}
+	}
+	for name, n := range count {
+		if n > 1 && !local[name] {
+			delete(visible, name)
+		}
+	}
+	return visible
+}
+
+type opMetaCache struct {
+	sync.Mutex
+	metas map[string][]OperatorMeta
+	errs  map[string]error
+}
+
+// load merges the comma separated op meta files or directories, results are cached by the whole list.
+func (p *opMetaCache) load(files string) ([]OperatorMeta, error) {
+	p.Lock()
+	defer p.Unlock()
+	if err, exist := p.errs[files]; exist {
+		return nil, err
+	}
+	if meta, exist := p.metas[files]; exist {
+		return meta, nil
+	}
+	var metaFiles OpMetaFiles
+	for _, file := range strings.Split(files, ",") {
+		if file = strings.TrimSpace(file); len(file) > 0 {
+			metaFiles = append(metaFiles, file)
+		}
+	}
+	meta, err := metaFiles.OpMetas()
+	if nil != err {
+		p.errs[files] = err
+		return nil, err
+	}
+	p.metas[files] = meta
+	return meta, nil
+}
+
+// ValidateRepository builds every script under the dirs in parallel, then resolves cross cluster sub graph references.
+func ValidateRepository(opt *BatchOptions) (*BatchReport, error) {
+	scripts, err := opt.collectScripts()
+	if nil != err {
+		return nil, err
+	}
+	report := &BatchReport{Scripts: scripts}
+	for _, rs := range scripts {
+		rs.Meta = opt.pairOpMeta(rs.Script, rs.root)
+	}
+	parallel := opt.Parallel
+	if parallel <= 0 {
+		parallel = runtime.NumCPU()
+	}
+	cache := &opMetaCache{metas: make(map[string][]OperatorMeta), errs: make(map[string]error)}
+	tasks := make(chan *ScriptReport)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rs := range tasks {
+				start := time.Now()
+				var opMeta []OperatorMeta
+				var err error
+				if len(rs.Meta) > 0 {
+					opMeta, err = cache.load(rs.Meta)
+				}
+				if nil == err {
+					rs.config, err = newDAGConfigByMeta(opMeta, rs.Script)
+				}
+				if nil != err {
+					rs.Errors = append(rs.Errors, err.Error())
+				}
+				rs.Duration = time.Since(start)
+			}
+		}()
+	}
+	for _, rs := range report.Scripts {
+		tasks <- rs
+	}
+	close(tasks)
+	wg.Wait()
+
+	clusters := make(map[string]*GraphCluster)
+	for _, rs := range report.Scripts {
+		if nil != rs.config {
+			clusters[filepath.Clean(rs.Script)] = &rs.config.graph
+		}
+	}
+	for _, rs := range report.Scripts {
+		if nil == rs.config {
+			continue
+		}
+		for _, err := range rs.config.graph.verifySubGraphs(visibleClusters(filepath.Clean(rs.Script), clusters)) {
+			rs.Errors = append(rs.Errors, err.Error())
+		}
+	}
+	for _, rs := range report.Scripts {
+		if rs.Ok() {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+func (p *BatchReport) WriteText(w io.Writer) {
+	for _, rs := range p.Scripts {
+		if rs.Ok() {
+			fmt.Fprintf(w, "ok   %s\n", rs.Script)
+			continue
+		}
+		fmt.Fprintf(w, "FAIL %s (meta:%s)\n", rs.Script, rs.Meta)
+		for _, err := range rs.Errors {
+			fmt.Fprintf(w, "     %s\n", err)
+		}
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", p.Passed, p.Failed)
+}
+
+func (p *BatchReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+func (p *BatchReport) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{Name: "didagle", Tests: len(p.Scripts), Failures: p.Failed}
+	for _, rs := range p.Scripts {
+		tc := junitTestCase{
+			Name:      rs.Script,
+			ClassName: "didagle.validate",
+			Time:      fmt.Sprintf("%.3f", rs.Duration.Seconds()),
+		}
+		if !rs.Ok() {
+			tc.Failure = &junitFailure{Message: rs.Errors[0], Content: strings.Join(rs.Errors, "\n")}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); nil != err {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); nil != err {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
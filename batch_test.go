@@ -0,0 +1,142 @@
+package didagle
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); nil != err {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); nil != err {
+			t.Fatal(err)
+		}
+	}
+}
+
+const testBatchGraph = `
+[[graph]]
+name = "%s"
+[[graph.vertex]]
+processor = "a"
+successor = ["b"]
+[[graph.vertex]]
+processor = "b"
+`
+
+const testBatchSubGraph = `
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "call"
+cluster = "x.toml"
+graph = "sub"
+successor = ["b"]
+[[graph.vertex]]
+processor = "b"
+`
+
+func TestValidateRepository(t *testing.T) {
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	writeTestFiles(t, dir, map[string]string{
+		// outside the repository, must not be paired
+		"op_meta.json":     "not json",
+		"repo/a/x.toml":    strings.Replace(testBatchGraph, "%s", "sub", 1),
+		"repo/a/main.toml": testBatchSubGraph,
+		"repo/b/x.toml":    strings.Replace(testBatchGraph, "%s", "sub", 1),
+		"repo/c/main.toml": testBatchSubGraph,
+		"repo/d/main.toml": `
+include = ["frag.toml"]
+[[config_setting]]
+name = "on"
+cond = "x==1"
+`,
+		"repo/d/frag.toml": `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+processor = "a"
+expect_config = "on"
+successor = ["b"]
+[[graph.vertex]]
+processor = "b"
+`,
+		"repo/partials/p.toml": "[[graph]]\nname = \"p\"\n[[graph.vertex]]\nprocessor = \"a\"\nexpect_config = \"on\"\n",
+		"repo/manifest.json":   `{"fragments": ["partials/*.toml"]}`,
+	})
+	manifest, err := LoadManifest(filepath.Join(repo, "manifest.json"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	report, err := ValidateRepository(&BatchOptions{Dirs: []string{repo}, Manifest: manifest})
+	if nil != err {
+		t.Fatal(err)
+	}
+	errs := make(map[string]string)
+	for _, rs := range report.Scripts {
+		rel, _ := filepath.Rel(repo, rs.Script)
+		errs[rel] = strings.Join(rs.Errors, ";")
+		if len(rs.Meta) > 0 {
+			t.Errorf("Unexpected meta:%s paired with %s", rs.Meta, rel)
+		}
+	}
+	want := map[string]string{
+		"a/main.toml": "",
+		"a/x.toml":    "",
+		"b/x.toml":    "",
+		"c/main.toml": "[main/call]No cluster:x.toml found for sub graph:sub",
+		"d/main.toml": "",
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("Unexpected errors:%v", errs)
+	}
+	if report.Passed != 4 || report.Failed != 1 {
+		t.Errorf("Unexpected report passed:%d failed:%d", report.Passed, report.Failed)
+	}
+}
+
+func TestValidateRepositoryDefaultMetas(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"metas/a.json": `[{"name":"a","input":[],"output":[{"name":"x","type":"int"}]}]`,
+		"metas/b.json": `[{"name":"b","input":[{"name":"x","type":"int"}],"output":[]}]`,
+		"repo/main.toml": `
+strict_dsl = true
+[[graph]]
+name = "main"
+[[graph.vertex]]
+processor = "a"
+[[graph.vertex]]
+processor = "b"
+`,
+	})
+	a, b := filepath.Join(dir, "metas", "a.json"), filepath.Join(dir, "metas", "b.json")
+	tests := []struct {
+		meta   string
+		errMsg string
+	}{
+		{a + "," + b, ""},
+		{" " + a + " , " + b + ",", ""},
+		{a, "No Processor:b found"},
+		{a + "," + filepath.Join(dir, "nope.json"), "nope.json"},
+	}
+	for _, test := range tests {
+		report, err := ValidateRepository(&BatchOptions{Dirs: []string{filepath.Join(dir, "repo")}, DefaultMeta: test.meta})
+		if nil != err {
+			t.Fatal(err)
+		}
+		rs := report.Scripts[0]
+		errs := strings.Join(rs.Errors, ";")
+		if rs.Meta != test.meta || (len(test.errMsg) == 0) != (len(errs) == 0) || !strings.Contains(errs, test.errMsg) {
+			t.Errorf("meta:%s: expect err:%s, but got meta:%s errors:%s", test.meta, test.errMsg, rs.Meta, errs)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+func init() {
+	register("batch", "Validate all scripts under directories and report for CI", runBatch)
+}
+
+func runBatch(args []string) int {
+	var meta string
+	fs := newFlagSet("batch", &meta, nil)
+	manifest := fs.String("manifest", "", "Specify manifest file pairing scripts with op meta files")
+	format := fs.String("format", "text", "Specify report format:text/json/junit")
+	output := fs.String("o", "", "Specify report file, default to stdout")
+	parallel := fs.Int("j", 0, "Specify number of scripts built concurrently, default to number of CPUs")
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	opt := &didagle.BatchOptions{
+		Dirs:        fs.Args(),
+		DefaultMeta: meta,
+		Parallel:    *parallel,
+	}
+	if len(*manifest) > 0 {
+		m, err := didagle.LoadManifest(*manifest)
+		if nil != err {
+			printError(false, err)
+			return exitUsage
+		}
+		opt.Manifest = m
+	}
+	report, err := didagle.ValidateRepository(opt)
+	if nil != err {
+		printError(false, err)
+		return exitIOError
+	}
+	var w io.Writer = os.Stdout
+	if len(*output) > 0 {
+		file, err := os.Create(*output)
+		if nil != err {
+			printError(false, err)
+			return exitIOError
+		}
+		defer file.Close()
+		w = file
+	}
+	switch *format {
+	case "text":
+		report.WriteText(w)
+	case "json":
+		err = report.WriteJSON(w)
+	case "junit":
+		err = report.WriteJUnit(w)
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid report format:%s\n", *format)
+		return exitUsage
+	}
+	if nil != err {
+		printError(false, err)
+		return exitIOError
+	}
+	if !report.Ok() {
+		return exitFailed
+	}
+	return exitOk
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+func init() {
+	register("validate", "Build scripts and report errors", runValidate)
+	register("lint", "Report suspicious constructs in scripts", runLint)
+	register("meta", "Validate op meta files, or print the op meta json schema", runMeta)
+}
+
+func runMeta(args []string) int {
+	var jsonOutput bool
+	fs := newFlagSet("meta", nil, &jsonOutput)
+	schema := fs.Bool("schema", false, "Print the op meta json schema")
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if *schema {
+		os.Stdout.Write(didagle.OpMetaJSONSchema)
+		return exitOk
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	code := exitOk
+	var results []validateResult
+	for _, file := range fs.Args() {
+		rs := validateResult{Script: file, Ok: true}
+		if _, err := didagle.OpMetaFiles([]string{file}).OpMetas(); nil != err {
+			rs.Ok = false
+			rs.Error = err.Error()
+			if code != exitIOError {
+				code = loadErrorCode(err)
+			}
+		}
+		results = append(results, rs)
+	}
+	if jsonOutput {
+		printJSON(results)
+		return code
+	}
+	for _, rs := range results {
+		if rs.Ok {
+			fmt.Printf("ok   %s\n", rs.Script)
+		} else {
+			fmt.Printf("FAIL %s: %s\n", rs.Script, rs.Error)
+		}
+	}
+	return code
+}
+
+type validateResult struct {
+	Script   string               `json:"script"`
+	Ok       bool                 `json:"ok"`
+	Error    string               `json:"error,omitempty"`
+	Warnings []didagle.Diagnostic `json:"warnings,omitempty"`
+}
+
+func runValidate(args []string) int {
+	var meta string
+	var jsonOutput bool
+	fs := newFlagSet("validate", &meta, &jsonOutput)
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	code := exitOk
+	var results []validateResult
+	for _, script := range fs.Args() {
+		rs := validateResult{Script: script, Ok: true}
+		cfg, err := loadScript(meta, script)
+		if nil != err {
+			rs.Ok = false
+			rs.Error = err.Error()
+			if code != exitIOError {
+				code = loadErrorCode(err)
+			}
+		} else {
+			rs.Warnings = cfg.Warnings()
+		}
+		results = append(results, rs)
+	}
+	if jsonOutput {
+		printJSON(results)
+		return code
+	}
+	for _, rs := range results {
+		if rs.Ok {
+			fmt.Printf("ok   %s\n", rs.Script)
+		} else {
+			fmt.Printf("FAIL %s: %s\n", rs.Script, rs.Error)
+		}
+		for _, warning := range rs.Warnings {
+			fmt.Printf("     %s:%v\n", rs.Script, warning)
+		}
+	}
+	return code
+}
+
+type lintResult struct {
+	Script string              `json:"script"`
+	Error  string              `json:"error,omitempty"`
+	Issues []didagle.LintIssue `json:"issues"`
+}
+
+func runLint(args []string) int {
+	var meta string
+	var jsonOutput bool
+	fs := newFlagSet("lint", &meta, &jsonOutput)
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	code := exitOk
+	var results []lintResult
+	for _, script := range fs.Args() {
+		rs := lintResult{Script: script}
+		cfg, err := loadScript(meta, script)
+		if nil != err {
+			rs.Error = err.Error()
+			if code != exitIOError {
+				code = loadErrorCode(err)
+			}
+		} else {
+			rs.Issues = cfg.Lint()
+			if len(rs.Issues) > 0 && code == exitOk {
+				code = exitFailed
+			}
+		}
+		results = append(results, rs)
+	}
+	if jsonOutput {
+		printJSON(results)
+		return code
+	}
+	for _, rs := range results {
+		if len(rs.Error) > 0 {
+			fmt.Printf("%s: %s\n", rs.Script, rs.Error)
+		}
+		for _, issue := range rs.Issues {
+			location := rs.Script
+			if len(issue.Graph) > 0 {
+				location += ":" + issue.Graph
+			}
+			if len(issue.Vertex) > 0 {
+				location += "/" + issue.Vertex
+			}
+			fmt.Printf("%s: [%s] %s\n", location, issue.Rule, issue.Message)
+		}
+	}
+	return code
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+func init() {
+	register("diff", "Show structural differences between two scripts", runDiff)
+}
+
+func runDiff(args []string) int {
+	var meta string
+	var jsonOutput bool
+	fs := newFlagSet("diff", &meta, &jsonOutput)
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	a, err := loadScript(meta, fs.Arg(0))
+	if nil != err {
+		printError(jsonOutput, err)
+		return loadErrorCode(err)
+	}
+	b, err := loadScript(meta, fs.Arg(1))
+	if nil != err {
+		printError(jsonOutput, err)
+		return loadErrorCode(err)
+	}
+	entries := a.Diff(b)
+	if jsonOutput {
+		if nil == entries {
+			entries = []didagle.DiffEntry{}
+		}
+		printJSON(entries)
+	} else {
+		for _, entry := range entries {
+			fmt.Println(entry)
+		}
+	}
+	if len(entries) > 0 {
+		return exitFailed
+	}
+	return exitOk
+}
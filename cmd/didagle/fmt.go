@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+func init() {
+	register("fmt", "Format scripts into the canonical layout", runFmt)
+}
+
+func runFmt(args []string) int {
+	fs := newFlagSet("fmt", nil, nil)
+	write := fs.Bool("w", false, "Write result to the source file instead of stdout")
+	list := fs.Bool("l", false, "List files whose formatting differs, exit with failure if any")
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	code := exitOk
+	for _, script := range fs.Args() {
+		content, err := os.ReadFile(script)
+		if nil != err {
+			printError(false, err)
+			return exitIOError
+		}
+		formated, err := didagle.FormatScript(string(content))
+		if nil != err {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", script, err)
+			code = exitFailed
+			continue
+		}
+		switch {
+		case *list:
+			if formated != string(content) {
+				fmt.Println(script)
+				code = exitFailed
+			}
+		case *write:
+			if formated != string(content) {
+				if err := os.WriteFile(script, []byte(formated), 0644); nil != err {
+					printError(false, err)
+					return exitIOError
+				}
+			}
+		default:
+			fmt.Print(formated)
+		}
+	}
+	return code
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+func init() {
+	register("gen", "Generate go operator stubs from op meta files", runGen)
+}
+
+func runGen(args []string) int {
+	var meta string
+	fs := newFlagSet("gen", &meta, nil)
+	pkg := fs.String("pkg", "operators", "Specify go package name of generated files")
+	output := fs.String("o", ".", "Specify output directory")
+	ops := fs.String("ops", "", "Specify operators(comma separated) to generate, default to all")
+	force := fs.Bool("f", false, "Overwrite existing files")
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if len(meta) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	metas, err := didagle.OpMetaFiles(strings.Split(meta, ",")).OpMetas()
+	if nil != err {
+		printError(false, err)
+		return loadErrorCode(err)
+	}
+	if len(*ops) > 0 {
+		selected := make(map[string]bool)
+		for _, name := range strings.Split(*ops, ",") {
+			selected[strings.TrimSpace(name)] = true
+		}
+		var filtered []didagle.OperatorMeta
+		for _, m := range metas {
+			if selected[m.FullName()] {
+				filtered = append(filtered, m)
+				delete(selected, m.FullName())
+			}
+		}
+		for name := range selected {
+			printError(false, fmt.Errorf("No operator:%s found in op meta", name))
+			return exitFailed
+		}
+		metas = filtered
+	}
+	files, err := didagle.GenerateOperators(*pkg, metas)
+	if nil != err {
+		printError(false, err)
+		return exitFailed
+	}
+	if err := os.MkdirAll(*output, 0755); nil != err {
+		printError(false, err)
+		return exitIOError
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(*output, name)
+		if _, err := os.Stat(path); nil == err && !*force {
+			fmt.Fprintf(os.Stderr, "Skip existing file:%s\n", path)
+			continue
+		}
+		if err := os.WriteFile(path, files[name], 0644); nil != err {
+			printError(false, err)
+			return exitIOError
+		}
+		fmt.Println(path)
+	}
+	return exitOk
+}
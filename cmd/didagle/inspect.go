@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+func init() {
+	register("plan", "Print the topological execution order of graphs", runPlan)
+	register("query", "Query vertices by data/processor/dependency", runQuery)
+	register("stats", "Print size metrics of a script", runStats)
+	register("simulate", "Dry run a graph under cond outcomes and report unreachable vertices", runSimulate)
+}
+
+// outcomeFlags collects repeated '-set key=true|false' flags.
+type outcomeFlags map[string]bool
+
+func (p outcomeFlags) String() string {
+	return fmt.Sprint(map[string]bool(p))
+}
+
+func (p outcomeFlags) Set(s string) error {
+	idx := strings.LastIndex(s, "=")
+	if idx <= 0 {
+		return fmt.Errorf("Invalid outcome:%s, expect key=true|false", s)
+	}
+	ok, err := strconv.ParseBool(strings.TrimSpace(s[idx+1:]))
+	if nil != err {
+		return fmt.Errorf("Invalid outcome:%s, expect key=true|false", s)
+	}
+	p[strings.TrimSpace(s[:idx])] = ok
+	return nil
+}
+
+func runPlan(args []string) int {
+	var meta string
+	var jsonOutput bool
+	fs := newFlagSet("plan", &meta, &jsonOutput)
+	graph := fs.String("graph", "", "Specify the only graph to plan")
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	cfg, err := loadScript(meta, fs.Arg(0))
+	if nil != err {
+		printError(jsonOutput, err)
+		return loadErrorCode(err)
+	}
+	plans, err := cfg.Plan()
+	if nil != err {
+		printError(jsonOutput, err)
+		return exitFailed
+	}
+	var selected []*didagle.GraphPlan
+	for _, plan := range plans {
+		if len(*graph) == 0 || plan.Graph == *graph {
+			selected = append(selected, plan)
+		}
+	}
+	if len(selected) == 0 {
+		printError(jsonOutput, fmt.Errorf("No graph with name:%s", *graph))
+		return exitFailed
+	}
+	if jsonOutput {
+		printJSON(selected)
+		return exitOk
+	}
+	for _, plan := range selected {
+		fmt.Printf("%s:\n", plan.Graph)
+		for i, stage := range plan.Stages {
+			fmt.Printf("  %d: %s\n", i, strings.Join(stage, " "))
+		}
+	}
+	return exitOk
+}
+
+func runQuery(args []string) int {
+	var meta string
+	var jsonOutput bool
+	fs := newFlagSet("query", &meta, &jsonOutput)
+	expr := fs.String("q", "", "Query expression like 'paths a b in graph', see didagle.DAGConfig.Query")
+	consumers := fs.String("consumers", "", "Find vertices consuming the data id")
+	producers := fs.String("producers", "", "Find vertices producing the data id")
+	processor := fs.String("processor", "", "Find vertices using the processor")
+	deps := fs.String("deps", "", "Find vertices the vertex id transitively depends on")
+	dependents := fs.String("dependents", "", "Find vertices transitively depending on the vertex id")
+	paths := fs.String("paths", "", "Find all paths between vertex ids like 'from,to'")
+	config := fs.String("config", "", "Find vertices using the config_setting")
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	var exprs []string
+	if len(*expr) > 0 {
+		exprs = append(exprs, *expr)
+	}
+	for _, q := range []struct {
+		kind  string
+		value string
+	}{
+		{"consumers", *consumers},
+		{"producers", *producers},
+		{"processor", *processor},
+		{"deps", *deps},
+		{"dependents", *dependents},
+		{"paths", strings.Replace(*paths, ",", " ", 1)},
+		{"config", *config},
+	} {
+		if len(q.value) > 0 {
+			exprs = append(exprs, q.kind+" "+q.value)
+		}
+	}
+	if len(exprs) != 1 {
+		fmt.Fprintf(os.Stderr, "Exactly one of -q/-consumers/-producers/-processor/-deps/-dependents/-paths/-config is required\n")
+		return exitUsage
+	}
+	q, err := didagle.ParseQuery(exprs[0])
+	if nil != err {
+		printError(jsonOutput, err)
+		return exitUsage
+	}
+	results := []didagle.QueryResult{}
+	for _, script := range fs.Args() {
+		cfg, err := loadScript(meta, script)
+		if nil != err {
+			printError(jsonOutput, err)
+			return loadErrorCode(err)
+		}
+		results = append(results, cfg.EvalQuery(q)...)
+	}
+	if jsonOutput {
+		printJSON(results)
+		return exitOk
+	}
+	for _, rs := range results {
+		fmt.Println(rs)
+	}
+	return exitOk
+}
+
+func runStats(args []string) int {
+	var meta string
+	var jsonOutput bool
+	fs := newFlagSet("stats", &meta, &jsonOutput)
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	cfg, err := loadScript(meta, fs.Arg(0))
+	if nil != err {
+		printError(jsonOutput, err)
+		return loadErrorCode(err)
+	}
+	stats := cfg.Stats()
+	if jsonOutput {
+		printJSON(stats)
+		return exitOk
+	}
+	fmt.Printf("cluster:%s config_settings:%d graphs:%d\n", stats.Name, stats.ConfigSettings, len(stats.Graphs))
+	for _, g := range stats.Graphs {
+		fmt.Printf("  graph:%s vertexs:%d edges:%d cond:%d sub_graph:%d datas:%d stages:%d\n",
+			g.Name, g.Vertexs, g.Edges, g.CondVertexs, g.SubGraphVertexs, g.Datas, g.Stages)
+	}
+	processors := make([]string, 0, len(stats.Processors))
+	for name := range stats.Processors {
+		processors = append(processors, name)
+	}
+	sort.Strings(processors)
+	for _, name := range processors {
+		fmt.Printf("  processor:%s used:%d\n", name, stats.Processors[name])
+	}
+	return exitOk
+}
+
+func runSimulate(args []string) int {
+	var meta string
+	var jsonOutput bool
+	fs := newFlagSet("simulate", &meta, &jsonOutput)
+	graph := fs.String("graph", "", "Specify the graph to simulate")
+	outcomes := make(outcomeFlags)
+	fs.Var(outcomes, "set", "Set the outcome of a cond/expect expression, config_setting or vertex id as key=true|false, repeatable, other decisions are enumerated")
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() != 1 || len(*graph) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	cfg, err := loadScript(meta, fs.Arg(0))
+	if nil != err {
+		printError(jsonOutput, err)
+		return loadErrorCode(err)
+	}
+	report, err := cfg.Simulate(*graph, outcomes)
+	if nil != err {
+		printError(jsonOutput, err)
+		return exitFailed
+	}
+	if jsonOutput {
+		printJSON(report)
+	} else {
+		for i, run := range report.Runs {
+			keys := make([]string, 0, len(run.Outcomes))
+			for key := range run.Outcomes {
+				keys = append(keys, fmt.Sprintf("%s=%v", key, run.Outcomes[key]))
+			}
+			sort.Strings(keys)
+			fmt.Printf("run %d: %s\n", i, strings.Join(keys, " "))
+			fmt.Printf("  executed: %s\n", strings.Join(run.Executed, " "))
+			fmt.Printf("  skipped: %s\n", strings.Join(run.Skipped, " "))
+			fmt.Printf("  stopped: %s\n", strings.Join(run.Stopped, " "))
+		}
+		if len(report.Unreachable) > 0 {
+			fmt.Printf("unreachable: %s\n", strings.Join(report.Unreachable, " "))
+		}
+	}
+	if len(report.Unreachable) > 0 {
+		return exitFailed
+	}
+	return exitOk
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+func init() {
+	register("lsp", "Serve the language server protocol over stdio", runLsp)
+}
+
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+// rpcResponse is a response with the result, json-rpc requires 'result' even if null on success.
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text,omitempty"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+var lspCompletionKinds = map[string]int{
+	didagle.COMPLETION_PROCESSOR: 3,
+	didagle.COMPLETION_FIELD:     5,
+	didagle.COMPLETION_DATA:      6,
+	didagle.COMPLETION_GRAPH:     9,
+	didagle.COMPLETION_VERTEX:    18,
+	didagle.COMPLETION_CONFIG:    21,
+}
+
+type lspDocument struct {
+	lines    []string
+	analysis *didagle.ScriptAnalysis
+}
+
+// toPosition converts the 0-based utf-16 lsp position to the 1-based byte position.
+func (p *lspDocument) toPosition(pos lspPosition) didagle.Position {
+	if pos.Line >= len(p.lines) {
+		return didagle.Position{Line: pos.Line + 1, Column: 1}
+	}
+	line := p.lines[pos.Line]
+	units := 0
+	for offset, r := range line {
+		if units >= pos.Character {
+			return didagle.Position{Line: pos.Line + 1, Column: offset + 1}
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return didagle.Position{Line: pos.Line + 1, Column: len(line) + 1}
+}
+
+func (p *lspDocument) toLspPosition(pos didagle.Position) lspPosition {
+	if pos.Line < 1 {
+		return lspPosition{}
+	}
+	if pos.Line > len(p.lines) {
+		return lspPosition{Line: pos.Line - 1}
+	}
+	line := p.lines[pos.Line-1]
+	if pos.Column-1 < len(line) {
+		line = line[:pos.Column-1]
+	}
+	units := 0
+	for len(line) > 0 {
+		r, size := utf8.DecodeRuneInString(line)
+		units += len(utf16.Encode([]rune{r}))
+		line = line[size:]
+	}
+	return lspPosition{Line: pos.Line - 1, Character: units}
+}
+
+type lspServer struct {
+	opMeta    []didagle.OperatorMeta
+	documents map[string]*lspDocument
+	writer    io.Writer
+	mutex     sync.Mutex
+	shutdown  bool
+}
+
+func (p *lspServer) write(msg *rpcMessage) {
+	msg.JSONRPC = "2.0"
+	p.send(msg)
+}
+
+func (p *lspServer) send(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if nil != err {
+		log.Printf("Failed to marshal lsp message with err:%v", err)
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	fmt.Fprintf(p.writer, "Content-Length: %d\r\n\r\n", len(b))
+	p.writer.Write(b)
+}
+
+func (p *lspServer) reply(id *json.RawMessage, result interface{}) {
+	p.send(&rpcResponse{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (p *lspServer) notify(method string, params interface{}) {
+	b, _ := json.Marshal(params)
+	p.write(&rpcMessage{Method: method, Params: b})
+}
+
+func (p *lspServer) update(uri string, text string) {
+	doc := &lspDocument{lines: strings.Split(text, "\n"), analysis: didagle.AnalyzeScript(p.opMeta, text)}
+	p.documents[uri] = doc
+	diagnostics := []lspDiagnostic{}
+	for _, diag := range doc.analysis.Diagnostics() {
+		start := doc.toLspPosition(diag.Position)
+		end := lspPosition{Line: start.Line}
+		if start.Line < len(doc.lines) {
+			end = doc.toLspPosition(didagle.Position{Line: start.Line + 1, Column: len(doc.lines[start.Line]) + 1})
+		}
+		severity := 1
+		if diag.Severity == didagle.DIAG_WARNING {
+			severity = 2
+		}
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    lspRange{Start: start, End: end},
+			Severity: severity,
+			Source:   "didagle",
+			Message:  diag.Message,
+		})
+	}
+	p.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diagnostics})
+}
+
+// handle processes one message, returns false once 'exit' is received.
+func (p *lspServer) handle(msg *rpcMessage) bool {
+	switch msg.Method {
+	case "initialize":
+		p.reply(msg.ID, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1,
+				"completionProvider": map[string]interface{}{"triggerCharacters": []string{"\"", "'"}},
+				"definitionProvider": true,
+				"hoverProvider":      true,
+			},
+			"serverInfo": map[string]string{"name": "didagle"},
+		})
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+	case "shutdown":
+		p.shutdown = true
+		p.reply(msg.ID, nil)
+	case "exit":
+		return false
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if nil == json.Unmarshal(msg.Params, &params) {
+			p.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		if nil == json.Unmarshal(msg.Params, &params) && len(params.ContentChanges) > 0 {
+			p.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params lspPositionParams
+		if nil == json.Unmarshal(msg.Params, &params) {
+			delete(p.documents, params.TextDocument.URI)
+			p.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": params.TextDocument.URI, "diagnostics": []lspDiagnostic{}})
+		}
+	case "textDocument/completion", "textDocument/definition", "textDocument/hover":
+		var params lspPositionParams
+		if err := json.Unmarshal(msg.Params, &params); nil != err {
+			p.write(&rpcMessage{ID: msg.ID, Error: &rpcError{Code: rpcInvalidParams, Message: err.Error()}})
+			return true
+		}
+		doc, exist := p.documents[params.TextDocument.URI]
+		if !exist {
+			p.reply(msg.ID, nil)
+			return true
+		}
+		p.reply(msg.ID, p.query(msg.Method, params.TextDocument.URI, doc, doc.toPosition(params.Position)))
+	default:
+		if nil != msg.ID {
+			p.write(&rpcMessage{ID: msg.ID, Error: &rpcError{Code: rpcMethodNotFound, Message: "Unsupported method:" + msg.Method}})
+		}
+	}
+	return true
+}
+
+func (p *lspServer) query(method string, uri string, doc *lspDocument, pos didagle.Position) interface{} {
+	switch method {
+	case "textDocument/completion":
+		items := []map[string]interface{}{}
+		for _, c := range doc.analysis.Complete(pos) {
+			items = append(items, map[string]interface{}{"label": c.Label, "kind": lspCompletionKinds[c.Kind], "detail": c.Detail})
+		}
+		return items
+	case "textDocument/definition":
+		def, ok := doc.analysis.Definition(pos)
+		if !ok {
+			return nil
+		}
+		start := doc.toLspPosition(def)
+		return map[string]interface{}{"uri": uri, "range": lspRange{Start: start, End: start}}
+	default:
+		hover := doc.analysis.Hover(pos)
+		if len(hover) == 0 {
+			return nil
+		}
+		return map[string]interface{}{"contents": map[string]string{"kind": "markdown", "value": hover}}
+	}
+}
+
+// serve reads 'Content-Length' framed json-rpc messages until 'exit' or EOF, returns the exit code.
+func (p *lspServer) serve(r io.Reader) int {
+	reader := textproto.NewReader(bufio.NewReader(r))
+	for {
+		header, err := reader.ReadMIMEHeader()
+		if nil != err {
+			if err != io.EOF {
+				log.Printf("Failed to read lsp header with err:%v", err)
+			}
+			return exitFailed
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if nil != err || length < 0 {
+			log.Printf("Invalid lsp Content-Length:%s", header.Get("Content-Length"))
+			return exitFailed
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader.R, body); nil != err {
+			log.Printf("Failed to read lsp body with err:%v", err)
+			return exitFailed
+		}
+		msg := &rpcMessage{}
+		if err := json.Unmarshal(body, msg); nil != err {
+			log.Printf("Invalid lsp message with err:%v", err)
+			continue
+		}
+		if !p.handle(msg) {
+			if p.shutdown {
+				return exitOk
+			}
+			return exitFailed
+		}
+	}
+}
+
+func runLsp(args []string) int {
+	var meta string
+	fs := newFlagSet("lsp", &meta, nil)
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	var files didagle.OpMetaFiles
+	for _, file := range strings.Split(meta, ",") {
+		if file = strings.TrimSpace(file); len(file) > 0 {
+			files = append(files, file)
+		}
+	}
+	opMeta, err := files.OpMetas()
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitIOError
+	}
+	// stdout is the protocol channel, logs of the library go to stderr
+	log.SetOutput(os.Stderr)
+	server := &lspServer{opMeta: opMeta, documents: make(map[string]*lspDocument), writer: os.Stdout}
+	return server.serve(os.Stdin)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+const (
+	exitOk      = 0
+	exitFailed  = 1
+	exitUsage   = 2
+	exitIOError = 3
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands []*command
+
+func register(name, usage string, run func(args []string) int) {
+	commands = append(commands, &command{name: name, usage: usage, run: run})
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: didagle <command> [flags] [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'didagle <command> -h' for the flags of a command.\n")
+}
+
+// newFlagSet creates the flag set of a sub command with the common '-meta' & '-json' flags.
+func newFlagSet(name string, meta *string, jsonOutput *bool) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if nil != meta {
+		fs.StringVar(meta, "meta", "", "Specify input op meta files or directories(comma separated)")
+	}
+	if nil != jsonOutput {
+		fs.BoolVar(jsonOutput, "json", false, "Output in json")
+	}
+	return fs
+}
+
+// loadScript builds the script with op metas from comma separated files or directories.
+func loadScript(meta string, script string) (*didagle.DAGConfig, error) {
+	var files didagle.OpMetaFiles
+	for _, file := range strings.Split(meta, ",") {
+		if file = strings.TrimSpace(file); len(file) > 0 {
+			files = append(files, file)
+		}
+	}
+	return didagle.NewDAGConfigByProviders(script, files)
+}
+
+// loadErrorCode returns exitIOError if the error is failed to read a file, otherwise exitFailed.
+func loadErrorCode(err error) int {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return exitIOError
+	}
+	return exitFailed
+}
+
+func printJSON(v interface{}) {
+	// keep '->' of query paths readable
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func printError(jsonOutput bool, err error) {
+	if jsonOutput {
+		printJSON(map[string]string{"error": err.Error()})
+	} else {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		os.Exit(exitOk)
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command:%s, available:%s\n", name, commandNames())
+	os.Exit(exitUsage)
+}
+
+func commandNames() string {
+	names := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	return strings.Join(names, ",")
+}
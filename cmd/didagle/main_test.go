@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testValidScript = `[[graph]]
+name = "main"
+[[graph.vertex]]
+processor = "a"
+successor = ["b"]
+[[graph.vertex]]
+processor = "b"
+`
+
+const testLintScript = `[[graph]]
+name = "main"
+[[graph.vertex]]
+processor = "a"
+output = [{ field = "unused" }]
+successor = ["b"]
+[[graph.vertex]]
+processor = "b"
+`
+
+const testBrokenScript = `[[graph]]
+name = "main"
+[[graph.vertex]]
+processor = "a"
+successor = ["missing"]
+`
+
+// runCommand runs the sub command, returns the exit code and the stdout.
+func runCommand(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	var cmd *command
+	for _, c := range commands {
+		if c.name == args[0] {
+			cmd = c
+		}
+	}
+	if nil == cmd {
+		t.Fatalf("No command:%s", args[0])
+	}
+	stdout, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	devNull, _ := os.Open(os.DevNull)
+	defer devNull.Close()
+	savedStdout, savedStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, devNull
+	code := cmd.run(args[1:])
+	os.Stdout, os.Stderr = savedStdout, savedStderr
+	content, _ := ioutil.ReadFile(stdout.Name())
+	return code, string(content)
+}
+
+func writeScripts(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); nil != err {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"valid.toml":  testValidScript,
+		"lint.toml":   testLintScript,
+		"broken.toml": testBrokenScript,
+		"ugly.toml":   "[[graph]]\n  name=\"main\"\n",
+		"ops.json":    `[{"name":"a","input":[],"output":[]}]`,
+		"bad.json":    `[{"name":"a","inputs":[]}]`,
+	})
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	tests := []struct {
+		args   []string
+		code   int
+		output string
+	}{
+		{[]string{"validate", path("valid.toml")}, exitOk, "ok   " + path("valid.toml")},
+		{[]string{"validate", "-meta", path("ops.json") + "," + path("ops.json"), path("valid.toml")}, exitOk, "ok"},
+		{[]string{"validate", path("valid.toml"), path("broken.toml")}, exitFailed, "FAIL " + path("broken.toml")},
+		{[]string{"validate", path("broken.toml"), path("nope.toml"), path("valid.toml")}, exitIOError, "FAIL " + path("nope.toml")},
+		{[]string{"validate", "-meta", path("nope.json"), path("valid.toml")}, exitIOError, "FAIL"},
+		{[]string{"validate"}, exitUsage, ""},
+		{[]string{"validate", "-nope", path("valid.toml")}, exitUsage, ""},
+		{[]string{"lint", path("valid.toml")}, exitOk, ""},
+		{[]string{"lint", path("lint.toml")}, exitFailed, "[unused-output]"},
+		{[]string{"lint", path("lint.toml"), path("nope.toml")}, exitIOError, "[unused-output]"},
+		{[]string{"meta", path("ops.json")}, exitOk, "ok   " + path("ops.json")},
+		{[]string{"meta", path("bad.json")}, exitFailed, "unknown field"},
+		{[]string{"meta", path("nope.json")}, exitIOError, "FAIL"},
+		{[]string{"meta", "-schema"}, exitOk, "\"$schema\""},
+		{[]string{"fmt", "-l", path("valid.toml")}, exitOk, ""},
+		{[]string{"fmt", "-l", path("valid.toml"), path("ugly.toml")}, exitFailed, path("ugly.toml")},
+		{[]string{"fmt", path("nope.toml")}, exitIOError, ""},
+		{[]string{"diff", path("valid.toml"), path("valid.toml")}, exitOk, ""},
+		{[]string{"diff", path("valid.toml"), path("lint.toml")}, exitFailed, "changed graph:main/vertex:a/output"},
+		{[]string{"diff", path("valid.toml"), path("broken.toml")}, exitFailed, ""},
+		{[]string{"diff", path("valid.toml"), path("nope.toml")}, exitIOError, ""},
+		{[]string{"diff", path("valid.toml")}, exitUsage, ""},
+		{[]string{"plan", path("valid.toml")}, exitOk, "a"},
+		{[]string{"stats", path("nope.toml")}, exitIOError, ""},
+	}
+	for _, test := range tests {
+		code, output := runCommand(t, test.args...)
+		if code != test.code || !strings.Contains(output, test.output) {
+			t.Errorf("%v: expect exit:%d with output:%s, but got %d:\n%s", test.args, test.code, test.output, code, output)
+		}
+	}
+	// fmt -w rewrites the file into the canonical layout
+	if code, _ := runCommand(t, "fmt", "-w", path("ugly.toml")); code != exitOk {
+		t.Errorf("Expect fmt -w ok, but got %d", code)
+	}
+	if code, _ := runCommand(t, "fmt", "-l", path("ugly.toml")); code != exitOk {
+		t.Errorf("Expect formatted file after fmt -w, but got %d", code)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	dir := writeScripts(t, map[string]string{"valid.toml": testValidScript, "lint.toml": testLintScript, "broken.toml": testBrokenScript})
+	code, output := runCommand(t, "validate", "-json", filepath.Join(dir, "valid.toml"), filepath.Join(dir, "broken.toml"))
+	var validated []validateResult
+	if err := json.Unmarshal([]byte(output), &validated); nil != err || code != exitFailed {
+		t.Fatalf("Unexpected validate output:%d %v\n%s", code, err, output)
+	}
+	if len(validated) != 2 || !validated[0].Ok || validated[1].Ok || !strings.Contains(validated[1].Error, "missing") {
+		t.Errorf("Unexpected validate results:%+v", validated)
+	}
+
+	code, output = runCommand(t, "lint", "-json", filepath.Join(dir, "lint.toml"))
+	var linted []lintResult
+	if err := json.Unmarshal([]byte(output), &linted); nil != err || code != exitFailed {
+		t.Fatalf("Unexpected lint output:%d %v\n%s", code, err, output)
+	}
+	if len(linted) != 1 || len(linted[0].Issues) != 1 || linted[0].Issues[0].Rule != "unused-output" || linted[0].Issues[0].Vertex != "a" {
+		t.Errorf("Unexpected lint results:%+v", linted)
+	}
+
+	code, output = runCommand(t, "diff", "-json", filepath.Join(dir, "valid.toml"), filepath.Join(dir, "nope.toml"))
+	var failed map[string]string
+	if err := json.Unmarshal([]byte(output), &failed); nil != err || code != exitIOError || len(failed["error"]) == 0 {
+		t.Errorf("Unexpected diff error output:%d %v\n%s", code, err, output)
+	}
+	code, output = runCommand(t, "diff", "-json", filepath.Join(dir, "valid.toml"), filepath.Join(dir, "valid.toml"))
+	if code != exitOk || strings.TrimSpace(output) != "[]" {
+		t.Errorf("Expect empty json diff, but got %d:%s", code, output)
+	}
+}
+
+func TestBatchMetas(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"a.json": `[{"name":"a","input":[],"output":[]}]`,
+		"b.json": `[{"name":"b","input":[],"output":[]}]`,
+	})
+	repo := writeScripts(t, map[string]string{"main.toml": "strict_dsl = true\n" + testValidScript})
+	metas := filepath.Join(dir, "a.json") + "," + filepath.Join(dir, "b.json")
+	if code, output := runCommand(t, "batch", "-meta", metas, repo); code != exitOk {
+		t.Errorf("Expect batch ok with two meta files, but got %d:\n%s", code, output)
+	}
+	if code, output := runCommand(t, "batch", "-meta", filepath.Join(dir, "a.json"), repo); code != exitFailed || !strings.Contains(output, "No Processor:b found") {
+		t.Errorf("Expect batch failure without meta of b, but got %d:\n%s", code, output)
+	}
+}
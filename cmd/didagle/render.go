@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+func init() {
+	register("render", "Render a script into dot/svg/png/mermaid/json", runRender)
+}
+
+func runRender(args []string) int {
+	var meta string
+	fs := newFlagSet("render", &meta, nil)
+	format := fs.String("format", "dot", "Specify output format:dot/svg/png/mermaid/json")
+	output := fs.String("o", "", "Specify output file, default to stdout")
+	clusters := fs.String("clusters", "", "Specify other toml scripts(comma separated) referenced by sub graph vertices")
+	expand := fs.Int("expand", 0, "Specify max depth to inline sub graphs")
+	graph := fs.String("graph", "", "Specify the only graph to render")
+	vertex := fs.String("vertex", "", "Specify the vertex id to focus on")
+	data := fs.String("data", "", "Specify the data id to focus on")
+	direction := fs.String("direction", "both", "Specify focus direction:up/down/both")
+	if err := fs.Parse(args); nil != err {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	opt := &didagle.RenderOptions{
+		SubGraphDepth: *expand,
+		Graph:         *graph,
+		FocusVertex:   *vertex,
+		FocusData:     *data,
+	}
+	switch *direction {
+	case "up":
+		opt.FocusDirection = didagle.FOCUS_UPSTREAM
+	case "down":
+		opt.FocusDirection = didagle.FOCUS_DOWNSTREAM
+	case "both":
+		opt.FocusDirection = didagle.FOCUS_BOTH
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid direction:%s\n", *direction)
+		return exitUsage
+	}
+	cfg, err := loadScript(meta, fs.Arg(0))
+	if nil != err {
+		printError(false, err)
+		return loadErrorCode(err)
+	}
+	for _, file := range strings.Split(*clusters, ",") {
+		file = strings.TrimSpace(file)
+		if len(file) == 0 {
+			continue
+		}
+		other, err := loadScript(meta, file)
+		if nil != err {
+			printError(false, err)
+			return loadErrorCode(err)
+		}
+		opt.Clusters = append(opt.Clusters, other)
+	}
+	content, err := cfg.Render(*format, opt)
+	if nil != err {
+		printError(false, err)
+		return exitFailed
+	}
+	if len(*output) == 0 {
+		os.Stdout.Write(content)
+		return exitOk
+	}
+	if err := os.WriteFile(*output, content, 0644); nil != err {
+		printError(false, err)
+		return exitIOError
+	}
+	return exitOk
+}
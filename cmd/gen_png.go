@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/yinqiwen/go-didagle"
 )
@@ -10,16 +12,53 @@ import (
 func main() {
 	meta := flag.String("meta", "", "Specify input op meta file")
 	script := flag.String("toml", "", "Specify input toml script")
+	clusters := flag.String("clusters", "", "Specify other toml scripts(comma separated) referenced by sub graph vertices")
+	expand := flag.Int("expand", 0, "Specify max depth to inline sub graphs")
+	graph := flag.String("graph", "", "Specify the only graph to render")
+	vertex := flag.String("vertex", "", "Specify the vertex id to focus on")
+	data := flag.String("data", "", "Specify the data id to focus on")
+	direction := flag.String("direction", "both", "Specify focus direction:up/down/both")
 	flag.Parse()
 
 	if len(*meta) == 0 || len(*script) == 0 {
 		flag.Usage()
-		return
+		os.Exit(2)
 	}
 	cfg, err := didagle.NewDAGConfigByFile(*meta, *script)
 	if nil != err {
 		log.Printf("%v", err)
-		return
+		os.Exit(1)
+	}
+	opt := &didagle.RenderOptions{
+		SubGraphDepth: *expand,
+		Graph:         *graph,
+		FocusVertex:   *vertex,
+		FocusData:     *data,
+	}
+	switch *direction {
+	case "up":
+		opt.FocusDirection = didagle.FOCUS_UPSTREAM
+	case "down":
+		opt.FocusDirection = didagle.FOCUS_DOWNSTREAM
+	case "both":
+		opt.FocusDirection = didagle.FOCUS_BOTH
+	default:
+		log.Printf("Invalid direction:%s", *direction)
+		os.Exit(2)
+	}
+	for _, file := range strings.Split(*clusters, ",") {
+		file = strings.TrimSpace(file)
+		if len(file) == 0 {
+			continue
+		}
+		other, err := didagle.NewDAGConfigByFile(*meta, file)
+		if nil != err {
+			log.Printf("%v", err)
+			os.Exit(1)
+		}
+		opt.Clusters = append(opt.Clusters, other)
+	}
+	if err := cfg.GenPngWithOptions("", opt); nil != err {
+		os.Exit(1)
 	}
-	cfg.GenPng("")
 }
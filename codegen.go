@@ -0,0 +1,303 @@
+package didagle
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+var cppScalarTypes = map[string]string{
+	"bool":        "bool",
+	"int":         "int",
+	"int8_t":      "int8",
+	"int16_t":     "int16",
+	"int32_t":     "int32",
+	"int64_t":     "int64",
+	"uint8_t":     "uint8",
+	"uint16_t":    "uint16",
+	"uint32_t":    "uint32",
+	"uint64_t":    "uint64",
+	"float":       "float32",
+	"double":      "float64",
+	"std::string": "string",
+	"string":      "string",
+}
+
+func splitTemplateArgs(s string) []string {
+	var args []string
+	depth := 0
+	start := 0
+	for i, c := range s {
+		switch c {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(args, strings.TrimSpace(s[start:]))
+}
+
+// goTypeOf maps the op meta field type(a go type or a common c++ type) to a go type, ok is false if unknown.
+func goTypeOf(typ string) (string, bool) {
+	typ = strings.TrimSpace(typ)
+	typ = strings.TrimPrefix(typ, "const ")
+	typ = strings.TrimSpace(strings.TrimSuffix(typ, "&"))
+	if t, exist := cppScalarTypes[typ]; exist {
+		return t, true
+	}
+	if strings.HasSuffix(typ, "*") {
+		t, ok := goTypeOf(strings.TrimSuffix(typ, "*"))
+		return "*" + t, ok
+	}
+	if idx := strings.Index(typ, "<"); idx > 0 && strings.HasSuffix(typ, ">") {
+		args := splitTemplateArgs(typ[idx+1 : len(typ)-1])
+		switch strings.TrimSpace(typ[:idx]) {
+		case "std::vector", "std::list", "std::deque", "std::set", "std::unordered_set":
+			if len(args) == 1 {
+				t, ok := goTypeOf(args[0])
+				return "[]" + t, ok
+			}
+		case "std::map", "std::unordered_map":
+			if len(args) == 2 {
+				k, kok := goTypeOf(args[0])
+				v, vok := goTypeOf(args[1])
+				return "map[" + k + "]" + v, kok && vok
+			}
+		case "std::shared_ptr", "std::unique_ptr":
+			if len(args) == 1 {
+				t, ok := goTypeOf(args[0])
+				return "*" + t, ok
+			}
+		}
+		return "interface{}", false
+	}
+	if len(typ) > 0 && !strings.Contains(typ, "::") {
+		if expr, err := parser.ParseExpr(typ); nil == err && isBuiltinGoType(expr) {
+			return typ, true
+		}
+	}
+	return "interface{}", false
+}
+
+var goBuiltinTypes = map[string]bool{
+	"bool": true, "string": true, "byte": true, "rune": true, "error": true, "any": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// isBuiltinGoType returns true if the type expression only refers builtin types, so it compiles without imports.
+func isBuiltinGoType(expr ast.Expr) bool {
+	builtin := true
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.Ident:
+			if !goBuiltinTypes[t.Name] {
+				builtin = false
+			}
+		case *ast.SelectorExpr, *ast.FuncType, *ast.ChanType, *ast.StructType, *ast.Ellipsis:
+			builtin = false
+		}
+		return builtin
+	})
+	return builtin
+}
+
+// goIdentifier converts names like 'user_type' or 'recall@v2' into exported go identifier 'UserType'/'RecallV2'.
+func goIdentifier(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			upper = true
+			continue
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		b.WriteRune(c)
+	}
+	s := b.String()
+	if len(s) == 0 || unicode.IsDigit(rune(s[0])) {
+		s = "Op" + s
+	}
+	return s
+}
+
+func goFileName(name string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(name) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(c)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+type genField struct {
+	GoName  string
+	GoType  string
+	Tag     string
+	Comment string
+}
+
+type genOperator struct {
+	Package  string
+	Name     string
+	TypeName string
+	Fields   []genField
+}
+
+func newGenField(meta FieldMeta, kind string, used map[string]bool) genField {
+	f := genField{GoName: goIdentifier(meta.Name)}
+	for used[f.GoName] {
+		f.GoName += "_"
+	}
+	used[f.GoName] = true
+	t, ok := goTypeOf(meta.Type)
+	if !ok && len(meta.Type) > 0 {
+		f.Comment = fmt.Sprintf("// TODO: map type '%s'", meta.Type)
+	}
+	opts := []string{kind, "name=" + meta.Name}
+	if meta.Flags.Extern > 0 {
+		opts = append(opts, "extern")
+	}
+	if meta.Flags.InOut > 0 {
+		opts = append(opts, "in_out")
+	}
+	if meta.Flags.Agrregate > 0 {
+		opts = append(opts, "aggregate")
+		if !strings.HasPrefix(t, "map[") && !strings.HasPrefix(t, "[]") {
+			t = "map[string]" + t
+		}
+	}
+	f.GoType = t
+	f.Tag = fmt.Sprintf("`%s:\"%s\"`", OP_FIELD_TAG, strings.Join(opts, ","))
+	return f
+}
+
+func newGenOperator(pkg string, meta OperatorMeta) *genOperator {
+	op := &genOperator{
+		Package:  pkg,
+		Name:     meta.FullName(),
+		TypeName: goIdentifier(meta.FullName()),
+	}
+	used := make(map[string]bool)
+	outputs := make(map[string]bool)
+	for _, output := range meta.Output {
+		outputs[output.Name] = true
+	}
+	for _, input := range meta.Input {
+		if input.Flags.InOut > 0 && outputs[input.Name] {
+			delete(outputs, input.Name)
+		}
+		op.Fields = append(op.Fields, newGenField(input, "input", used))
+	}
+	for _, output := range meta.Output {
+		if !outputs[output.Name] {
+			continue
+		}
+		op.Fields = append(op.Fields, newGenField(output, "output", used))
+	}
+	return op
+}
+
+var operatorStubTemplate = template.Must(template.New("operator").Parse(`// Generated by 'didagle gen' from op meta, implement the Execute method.
+
+package {{.Package}}
+
+import (
+	"context"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+// {{.TypeName}} is the go operator '{{.Name}}'.
+type {{.TypeName}} struct {
+{{- range .Fields}}
+	{{.GoName}} {{.GoType}} {{.Tag}} {{.Comment}}
+{{- end}}
+}
+
+func init() {
+	didagle.MustRegisterOperator("{{.Name}}", &{{.TypeName}}{})
+}
+
+func (op *{{.TypeName}}) Execute(ctx context.Context, args map[string]interface{}) error {
+	// TODO: implement operator '{{.Name}}'
+	return nil
+}
+`))
+
+var operatorTestTemplate = template.Must(template.New("operator_test").Parse(`package {{.Package}}
+
+import (
+	"context"
+	"testing"
+)
+
+func Test{{.TypeName}}Execute(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      *{{.TypeName}}
+		args    map[string]interface{}
+		wantErr bool
+	}{
+		{name: "empty", op: &{{.TypeName}}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.op.Execute(context.Background(), tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+`))
+
+func executeGoTemplate(t *template.Template, data interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := t.Execute(&buffer, data); nil != err {
+		return nil, err
+	}
+	return format.Source(buffer.Bytes())
+}
+
+// GenerateOperators generates go operator stubs & test scaffolds for the op metas,
+// the result maps file name to file content.
+func GenerateOperators(pkg string, metas []OperatorMeta) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, meta := range metas {
+		op := newGenOperator(pkg, meta)
+		name := goFileName(meta.FullName())
+		if _, exist := files[name+".go"]; exist {
+			return nil, fmt.Errorf("Duplicate operator:%s to generate", meta.FullName())
+		}
+		content, err := executeGoTemplate(operatorStubTemplate, op)
+		if nil != err {
+			return nil, fmt.Errorf("Failed to generate operator:%s with err:%v", meta.FullName(), err)
+		}
+		files[name+".go"] = content
+		content, err = executeGoTemplate(operatorTestTemplate, op)
+		if nil != err {
+			return nil, fmt.Errorf("Failed to generate operator test:%s with err:%v", meta.FullName(), err)
+		}
+		files[name+"_test.go"] = content
+	}
+	return files, nil
+}
@@ -0,0 +1,110 @@
+package didagle
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testGenOps = `[
+{"name":"recall","version":"v2",
+ "input":[
+  {"name":"user_id","id":1,"type":"int64_t"},
+  {"name":"items","id":2,"type":"std::vector<std::string>","flags":{"is_aggregate":1}},
+  {"name":"scores","id":3,"type":"std::map<std::string, double>"},
+  {"name":"ctx","id":4,"type":"const Context&","flags":{"is_extern":1}},
+  {"name":"state","id":5,"type":"std::shared_ptr<int32_t>","flags":{"is_in_out":1}}
+ ],
+ "output":[
+  {"name":"state","id":5,"type":"std::shared_ptr<int32_t>","flags":{"is_in_out":1}},
+  {"name":"result","id":6,"type":"[]map[string]float64"},
+  {"name":"Result","id":7,"type":"bool"}
+ ]},
+{"name":"2nd-stage","input":[],"output":[]}
+]`
+
+// structFields parses the go source, returns 'name type tag' of the fields of the struct type.
+func structFields(t *testing.T, src []byte, name string) []string {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if nil != err {
+		t.Fatalf("Generated source does not parse:%v\n%s", err, src)
+	}
+	if file.Name.Name != "ops" {
+		t.Errorf("Unexpected package:%s", file.Name.Name)
+	}
+	var fields []string
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok || spec.Name.Name != name {
+			return true
+		}
+		found = true
+		for _, field := range spec.Type.(*ast.StructType).Fields.List {
+			typ := string(src[fset.Position(field.Type.Pos()).Offset:fset.Position(field.Type.End()).Offset])
+			fields = append(fields, field.Names[0].Name+" "+typ+" "+field.Tag.Value)
+		}
+		return false
+	})
+	if !found {
+		t.Fatalf("No struct:%s generated", name)
+	}
+	return fields
+}
+
+func TestGenerateOperators(t *testing.T) {
+	metas, err := DecodeOpMeta(strings.NewReader(testGenOps))
+	if nil != err {
+		t.Fatal(err)
+	}
+	files, err := GenerateOperators("ops", metas)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"2nd_stage.go", "2nd_stage_test.go", "recall_v2.go", "recall_v2_test.go"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expect files:%v, but got %v", want, names)
+	}
+	for _, name := range names {
+		if formatted, err := format.Source(files[name]); nil != err || string(formatted) != string(files[name]) {
+			t.Errorf("Generated %s is not gofmt formatted with err:%v\n%s", name, err, files[name])
+		}
+	}
+	want := []string{
+		"UserId int64 `didagle:\"input,name=user_id\"`",
+		"Items []string `didagle:\"input,name=items,aggregate\"`",
+		"Scores map[string]float64 `didagle:\"input,name=scores\"`",
+		"Ctx interface{} `didagle:\"input,name=ctx,extern\"`",
+		"State *int32 `didagle:\"input,name=state,in_out\"`",
+		"Result []map[string]float64 `didagle:\"output,name=result\"`",
+		"Result_ bool `didagle:\"output,name=Result\"`",
+	}
+	if got := structFields(t, files["recall_v2.go"], "RecallV2"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected fields of RecallV2:\n%s", strings.Join(got, "\n"))
+	}
+	if !strings.Contains(string(files["recall_v2.go"]), "// TODO: map type 'const Context&'") ||
+		!strings.Contains(string(files["recall_v2.go"]), `didagle.MustRegisterOperator("recall@v2", &RecallV2{})`) {
+		t.Errorf("Unexpected generated operator:\n%s", files["recall_v2.go"])
+	}
+	if got := structFields(t, files["2nd_stage.go"], "Op2ndStage"); len(got) != 0 {
+		t.Errorf("Unexpected fields of Op2ndStage:%v", got)
+	}
+	if !strings.Contains(string(files["recall_v2_test.go"]), "func TestRecallV2Execute(t *testing.T)") {
+		t.Errorf("Unexpected generated test:\n%s", files["recall_v2_test.go"])
+	}
+
+	if _, err := GenerateOperators("ops", append(metas, metas[0])); nil == err || !strings.Contains(err.Error(), "Duplicate operator:recall@v2") {
+		t.Errorf("Expect duplicate operator error, but got:%v", err)
+	}
+}
@@ -1,7 +1,6 @@
 package didagle
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -18,29 +17,42 @@ type DAGConfig struct {
 	graph  GraphCluster
 
 	scriptPath string
+	warnings   []Diagnostic
 }
 
 func (p *DAGConfig) loadTomlScriptFile(tomlScript string) error {
-	if _, err := toml.DecodeFile(tomlScript, &p.graph); err != nil {
-		log.Printf("Failed to parse toml script file:%s with err:%v", tomlScript, err)
-		return err
-	}
-	p.graph.name = filepath.Base(tomlScript)
-	err := p.graph.build(p.opMeta)
+	content, err := ioutil.ReadFile(tomlScript)
 	if nil != err {
-		log.Printf("Failed to build graph with err:%v", err)
+		log.Printf("Failed to read toml script file:%s with err:%v", tomlScript, err)
 		return err
 	}
-	return nil
+	p.graph.dir = filepath.Dir(tomlScript)
+	return p.loadTomlScript(string(content), filepath.Base(tomlScript))
 }
 
 func (p *DAGConfig) loadTomlScriptContent(tomlScript string) error {
-	if _, err := toml.Decode(tomlScript, &p.graph); err != nil {
-		log.Printf("Failed to parse toml script file:%s with err:%v", tomlScript, err)
+	return p.loadTomlScript(tomlScript, "DefaultCluster")
+}
+
+func (p *DAGConfig) loadTomlScript(content string, name string) error {
+	md, err := toml.Decode(content, &p.graph)
+	if err != nil {
+		log.Printf("Failed to parse toml script:%s with err:%v", name, err)
+		return err
+	}
+	severity := DIAG_WARNING
+	if p.graph.StrictDsl {
+		severity = DIAG_ERROR
+	}
+	p.warnings = checkUnknownKeys(content, md, &p.graph, severity)
+	if p.graph.StrictDsl && len(p.warnings) > 0 {
+		err := &ScriptError{Diagnostics: p.warnings}
+		log.Printf("Failed to parse toml script:%s with err:%v", name, err)
 		return err
 	}
-	p.graph.name = "DefaultCluster"
-	err := p.graph.build(p.opMeta)
+	p.graph.name = name
+	err = p.graph.build(p.opMeta)
+	p.warnings = append(p.warnings, p.graph.includeWarnings...)
 	if nil != err {
 		log.Printf("Failed to build graph with err:%v", err)
 		return err
@@ -48,17 +60,42 @@ func (p *DAGConfig) loadTomlScriptContent(tomlScript string) error {
 	return nil
 }
 
+// Warnings returns the unknown keys found in the script and included scripts when 'strict_dsl' is not enabled,
+// messages of included scripts are prefixed with the file.
+func (p *DAGConfig) Warnings() []Diagnostic {
+	return p.warnings
+}
+
+// Name returns the cluster name used by sub graph vertices to refer this config.
+func (p *DAGConfig) Name() string {
+	return p.graph.name
+}
+
 func (p *DAGConfig) DumpDot() string {
+	return p.DumpDotWithOptions(nil)
+}
+
+func (p *DAGConfig) DumpDotWithOptions(opt *RenderOptions) string {
 	builder := &strings.Builder{}
-	p.graph.dumpDot(builder)
+	p.graph.dumpDot(builder, opt)
 	return builder.String()
 }
 
 func (p *DAGConfig) GenPng(filePath string) error {
+	return p.GenPngWithOptions(filePath, nil)
+}
+
+func (p *DAGConfig) GenPngWithOptions(filePath string, opt *RenderOptions) error {
+	if nil != opt {
+		if err := opt.verify(&p.graph); nil != err {
+			log.Printf("Invalid render options with err:%v", err)
+			return err
+		}
+	}
 	if len(filePath) > 0 {
 		p.scriptPath = filePath
 	}
-	dot := p.DumpDot()
+	dot := p.DumpDotWithOptions(opt)
 	dotFile := p.scriptPath + ".dot"
 	err := ioutil.WriteFile(dotFile, []byte(dot), 0755)
 	if nil != err {
@@ -76,19 +113,24 @@ func (p *DAGConfig) GenPng(filePath string) error {
 	return nil
 }
 
-func NewDAGConfigByFile(opMetaFile string, tomlScript string) (*DAGConfig, error) {
+func loadOpMetaFile(opMetaFile string) ([]OperatorMeta, error) {
 	jsonFile, err := os.Open(opMetaFile)
 	if err != nil {
 		log.Printf("Failed to load op meta file:%s with err:%v", opMetaFile, err)
 		return nil, err
 	}
-	config := &DAGConfig{}
-	err = json.NewDecoder(jsonFile).Decode(&config.opMeta)
+	defer jsonFile.Close()
+	opMeta, err := DecodeOpMeta(jsonFile)
 	if nil != err {
 		log.Printf("Failed to parse op meta file:%s with err:%v", opMetaFile, err)
 		return nil, err
 	}
-	err = config.loadTomlScriptFile(tomlScript)
+	return opMeta, nil
+}
+
+func newDAGConfigByMeta(opMeta []OperatorMeta, tomlScript string) (*DAGConfig, error) {
+	config := &DAGConfig{opMeta: opMeta}
+	err := config.loadTomlScriptFile(tomlScript)
 	if nil != err {
 		return nil, err
 	}
@@ -96,11 +138,30 @@ func NewDAGConfigByFile(opMetaFile string, tomlScript string) (*DAGConfig, error
 	return config, nil
 }
 
+// NewDAGConfigByProviders builds the toml script with operator metas merged from all providers.
+func NewDAGConfigByProviders(tomlScript string, providers ...OpMetaProvider) (*DAGConfig, error) {
+	opMeta, err := MergeOpMetas(providers...)
+	if nil != err {
+		log.Printf("Failed to merge op metas with err:%v", err)
+		return nil, err
+	}
+	return newDAGConfigByMeta(opMeta, tomlScript)
+}
+
+func NewDAGConfigByFile(opMetaFile string, tomlScript string) (*DAGConfig, error) {
+	opMeta, err := loadOpMetaFile(opMetaFile)
+	if nil != err {
+		return nil, err
+	}
+	return newDAGConfigByMeta(opMeta, tomlScript)
+}
+
 func NewDAGConfigByContent(opMeta string, tomlScript string) (*DAGConfig, error) {
 	opMeta = strings.TrimSpace(opMeta)
 	config := &DAGConfig{}
 	if len(opMeta) > 0 {
-		err := json.Unmarshal([]byte(opMeta), &config.opMeta)
+		var err error
+		config.opMeta, err = DecodeOpMeta(strings.NewReader(opMeta))
 		if nil != err {
 			log.Printf("Failed to parse op meta %s with err:%v", opMeta, err)
 			return nil, err
@@ -0,0 +1,143 @@
+package didagle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const DIFF_ADDED = "added"
+const DIFF_REMOVED = "removed"
+const DIFF_CHANGED = "changed"
+
+// DiffEntry is one structural difference between two graph clusters.
+type DiffEntry struct {
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (p DiffEntry) String() string {
+	if len(p.Detail) == 0 {
+		return fmt.Sprintf("%s %s", p.Kind, p.Path)
+	}
+	return fmt.Sprintf("%s %s: %s", p.Kind, p.Path, p.Detail)
+}
+
+func expectString(expect int) string {
+	switch expect {
+	case V_RESULT_OK:
+		return "ok"
+	case V_RESULT_ERR:
+		return "err"
+	default:
+		return "all"
+	}
+}
+
+func (p *Vertex) depsString() string {
+	deps := make([]string, 0, len(p.depsResults))
+	for _, dep := range p.sortedDeps() {
+		deps = append(deps, dep.ID+":"+dep.Expect)
+	}
+	return strings.Join(deps, ",")
+}
+
+func dataString(datas []GraphData) string {
+	ss := make([]string, 0, len(datas))
+	for _, data := range datas {
+		s := data.Field + "=" + data.ID
+		if len(data.Aggregate) > 0 {
+			s += "[" + strings.Join(data.Aggregate, ",") + "]"
+		}
+		ss = append(ss, s)
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, ",")
+}
+
+func diffVertex(path string, a, b *Vertex, entries []DiffEntry) []DiffEntry {
+	fields := []struct {
+		name   string
+		va, vb string
+	}{
+		{"processor", a.Processor, b.Processor},
+		{"cond", a.Cond, b.Cond},
+		{"expect_config", a.ExpectConfig, b.ExpectConfig},
+		{"sub_graph", a.Cluster + "::" + a.Graph, b.Cluster + "::" + b.Graph},
+		{"deps", a.depsString(), b.depsString()},
+		{"input", dataString(a.Input), dataString(b.Input)},
+		{"output", dataString(a.Output), dataString(b.Output)},
+	}
+	for _, f := range fields {
+		if f.va != f.vb {
+			entries = append(entries, DiffEntry{
+				Kind:   DIFF_CHANGED,
+				Path:   path + "/" + f.name,
+				Detail: fmt.Sprintf("'%s' -> '%s'", f.va, f.vb),
+			})
+		}
+	}
+	return entries
+}
+
+func diffGraph(a, b *Graph, entries []DiffEntry) []DiffEntry {
+	for _, va := range a.sortedVertexs() {
+		path := "graph:" + a.Name + "/vertex:" + va.ID
+		vb := b.getVertexById(va.ID)
+		if nil == vb {
+			entries = append(entries, DiffEntry{Kind: DIFF_REMOVED, Path: path})
+			continue
+		}
+		entries = diffVertex(path, va, vb, entries)
+	}
+	for _, vb := range b.sortedVertexs() {
+		if nil == a.getVertexById(vb.ID) {
+			entries = append(entries, DiffEntry{Kind: DIFF_ADDED, Path: "graph:" + b.Name + "/vertex:" + vb.ID})
+		}
+	}
+	return entries
+}
+
+// Diff returns the structural differences from this cluster to the other one, compared on built vertices.
+func (p *DAGConfig) Diff(other *DAGConfig) []DiffEntry {
+	var entries []DiffEntry
+	a, b := &p.graph, &other.graph
+	configs := make(map[string]string)
+	for _, c := range a.ConfigSetting {
+		configs[c.Name] = c.Cond
+	}
+	for _, c := range b.ConfigSetting {
+		cond, exist := configs[c.Name]
+		if !exist {
+			entries = append(entries, DiffEntry{Kind: DIFF_ADDED, Path: "config_setting:" + c.Name})
+		} else if cond != c.Cond {
+			entries = append(entries, DiffEntry{
+				Kind:   DIFF_CHANGED,
+				Path:   "config_setting:" + c.Name,
+				Detail: fmt.Sprintf("'%s' -> '%s'", cond, c.Cond),
+			})
+		}
+		delete(configs, c.Name)
+	}
+	for _, c := range a.ConfigSetting {
+		if _, exist := configs[c.Name]; exist {
+			entries = append(entries, DiffEntry{Kind: DIFF_REMOVED, Path: "config_setting:" + c.Name})
+		}
+	}
+	for i := range a.Graph {
+		ga := &a.Graph[i]
+		gb := b.getGraphByName(ga.Name)
+		if nil == gb {
+			entries = append(entries, DiffEntry{Kind: DIFF_REMOVED, Path: "graph:" + ga.Name})
+			continue
+		}
+		entries = diffGraph(ga, gb, entries)
+	}
+	for i := range b.Graph {
+		if nil == a.getGraphByName(b.Graph[i].Name) {
+			entries = append(entries, DiffEntry{Kind: DIFF_ADDED, Path: "graph:" + b.Graph[i].Name})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,100 @@
+package didagle
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testDiffScript = `
+[[config_setting]]
+name = "on"
+cond = "x==1"
+[[config_setting]]
+name = "gone"
+cond = "y==1"
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "a"
+processor = "p"
+output = [{ field = "x" }]
+successor = ["b", "c"]
+[[graph.vertex]]
+id = "b"
+processor = "p"
+input = [{ field = "x" }]
+expect_config = "on"
+[[graph.vertex]]
+id = "c"
+processor = "p"
+[[graph]]
+name = "old"
+[[graph.vertex]]
+processor = "p"
+successor = ["q"]
+[[graph.vertex]]
+processor = "q"
+`
+
+const testDiffNewScript = `
+[[config_setting]]
+name = "on"
+cond = "x==2"
+[[config_setting]]
+name = "fresh"
+cond = "z==1"
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "a"
+processor = "q"
+output = [{ field = "x", id = "x1" }]
+if = ["b"]
+successor = ["d"]
+[[graph.vertex]]
+id = "b"
+processor = "p"
+input = [{ field = "x", id = "x1" }]
+expect_config = "!on"
+[[graph.vertex]]
+id = "d"
+processor = "p"
+[[graph]]
+name = "new"
+[[graph.vertex]]
+processor = "p"
+successor = ["q"]
+[[graph.vertex]]
+processor = "q"
+`
+
+func TestDiff(t *testing.T) {
+	from := mustBuild(t, "", testDiffScript)
+	to := mustBuild(t, "", testDiffNewScript)
+	want := []DiffEntry{
+		{DIFF_CHANGED, "config_setting:on", "'x==1' -> 'x==2'"},
+		{DIFF_ADDED, "config_setting:fresh", ""},
+		{DIFF_REMOVED, "config_setting:gone", ""},
+		{DIFF_CHANGED, "graph:main/vertex:a/processor", "'p' -> 'q'"},
+		{DIFF_CHANGED, "graph:main/vertex:a/output", "'x=x' -> 'x=x1'"},
+		{DIFF_CHANGED, "graph:main/vertex:b/expect_config", "'on' -> '!on'"},
+		{DIFF_CHANGED, "graph:main/vertex:b/deps", "'a:all' -> 'a:ok'"},
+		{DIFF_CHANGED, "graph:main/vertex:b/input", "'x=x' -> 'x=x1'"},
+		{DIFF_REMOVED, "graph:main/vertex:c", ""},
+		{DIFF_ADDED, "graph:main/vertex:d", ""},
+		{DIFF_REMOVED, "graph:old", ""},
+		{DIFF_ADDED, "graph:new", ""},
+	}
+	if got := from.Diff(to); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected diff:\n%v", got)
+	}
+	if got := from.Diff(mustBuild(t, "", testDiffScript)); len(got) != 0 {
+		t.Errorf("Expect no diff of the same script, but got %v", got)
+	}
+	if got := (DiffEntry{DIFF_REMOVED, "graph:old", ""}).String(); got != "removed graph:old" {
+		t.Errorf("Unexpected entry string:%s", got)
+	}
+	if got := want[0].String(); got != "changed config_setting:on: 'x==1' -> 'x==2'" {
+		t.Errorf("Unexpected entry string:%s", got)
+	}
+}
@@ -0,0 +1,736 @@
+package didagle
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CondEvaluator evaluates the cond expression of cond vertices & config settings against the current data.
+type CondEvaluator func(ctx context.Context, expr string, data map[string]interface{}) (bool, error)
+
+type ExecutorOptions struct {
+	// Registry of go operators, DefaultRegistry if nil.
+	Registry *OperatorRegistry
+	EvalCond CondEvaluator
+	// Clusters referenced by sub graph vertices.
+	Clusters []*DAGConfig
+	// Trace records timings, goroutines, cond outcomes and output data sizes of vertices.
+	Trace bool
+	// Instrumentation receives graph & vertex execution events if not nil, see Metrics & Tracing.
+	Instrumentation Instrumentation
+}
+
+// Executor executes graphs of a DAGConfig with go operators, it's safe for concurrent use.
+type Executor struct {
+	registry   *OperatorRegistry
+	evalCond   CondEvaluator
+	cluster    *GraphCluster
+	clusters   map[string]*GraphCluster
+	pools      map[*Graph]*graphPool
+	executions chan *execution
+	trace      bool
+	instrument Instrumentation
+}
+
+// VertexResult is the execution result of a vertex, Graph is the path of the graph like 'main/call_vertex/sub'
+// for vertices of sub graphs.
+type VertexResult struct {
+	Graph    string `json:"graph"`
+	ID       string `json:"id"`
+	Result   int    `json:"result"`
+	Err      error  `json:"-"`
+	Attempts int    `json:"attempts,omitempty"`
+	// Fallback is true if the fallback processor or default outputs are applied.
+	Fallback bool `json:"fallback,omitempty"`
+	// Skipped is true if the vertex is not executed, its Result is V_RESULT_SKIP, see depsMatched.
+	Skipped bool `json:"skipped,omitempty"`
+	// Cancelled is true if the vertex is not executed since the context is done, it's also Skipped.
+	Cancelled bool `json:"cancelled,omitempty"`
+
+	// Fields below are only recorded with ExecutorOptions.Trace.
+	Start     time.Time `json:"-"`
+	End       time.Time `json:"-"`
+	Goroutine uint64    `json:"goroutine,omitempty"`
+	// Conds maps evaluated cond expressions & expect_config names to the outcomes.
+	Conds map[string]bool `json:"conds,omitempty"`
+	// DataSizes maps output data ids to their sizes, the length for string/slice/map.
+	DataSizes map[string]int `json:"data_sizes,omitempty"`
+}
+
+type ExecuteResult struct {
+	Graph   string
+	Data    map[string]interface{}
+	Vertexs []*VertexResult
+	// Err is the context error if the execution is cancelled or exceeds the deadline.
+	Err error
+}
+
+// Completed returns results of executed vertices, whatever ok or err.
+func (p *ExecuteResult) Completed() []*VertexResult {
+	var vs []*VertexResult
+	for _, v := range p.Vertexs {
+		if !v.Skipped {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// Skipped returns results of vertices not executed, including the cancelled ones.
+func (p *ExecuteResult) Skipped() []*VertexResult {
+	var vs []*VertexResult
+	for _, v := range p.Vertexs {
+		if v.Skipped {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// Get returns the result of the vertex in the graph path, nil if not found.
+func (p *ExecuteResult) Get(graph string, id string) *VertexResult {
+	for _, v := range p.Vertexs {
+		if v.Graph == graph && v.ID == id {
+			return v
+		}
+	}
+	return nil
+}
+
+func NewExecutor(config *DAGConfig, opt *ExecutorOptions) *Executor {
+	p := &Executor{
+		registry: DefaultRegistry,
+		cluster:  &config.graph,
+		clusters: make(map[string]*GraphCluster),
+	}
+	if nil != opt {
+		if nil != opt.Registry {
+			p.registry = opt.Registry
+		}
+		p.evalCond = opt.EvalCond
+		p.trace = opt.Trace
+		p.instrument = opt.Instrumentation
+		for _, c := range opt.Clusters {
+			if nil != c {
+				p.clusters[c.graph.name] = &c.graph
+			}
+		}
+	}
+	p.clusters[config.graph.name] = &config.graph
+	p.pools = make(map[*Graph]*graphPool)
+	for _, cluster := range p.clusters {
+		for i := range cluster.Graph {
+			g := &cluster.Graph[i]
+			p.pools[g] = newGraphPool(p, g, int(cluster.DefaultContextPoolSize))
+		}
+	}
+	if size := int(config.graph.DefaultContextPoolSize); size > 0 {
+		p.executions = make(chan *execution, size)
+		for i := 0; i < size; i++ {
+			p.executions <- p.newExecution()
+		}
+	}
+	return p
+}
+
+// graphPool holds pre-built contexts of a graph, sized by 'default_context_pool_size' of the cluster.
+// Contexts are created on demand if the pool is empty, and dropped on release if the pool is full.
+type graphPool struct {
+	executor *Executor
+	g        *Graph
+	contexts chan *graphContext
+}
+
+func newGraphPool(executor *Executor, g *Graph, size int) *graphPool {
+	p := &graphPool{executor: executor, g: g}
+	if size > 0 {
+		p.contexts = make(chan *graphContext, size)
+		for i := 0; i < size; i++ {
+			p.contexts <- newGraphContext(executor, g)
+		}
+	}
+	return p
+}
+
+func (p *graphPool) get() *graphContext {
+	select {
+	case c := <-p.contexts:
+		return c
+	default:
+		return newGraphContext(p.executor, p.g)
+	}
+}
+
+func (p *graphPool) put(c *graphContext) {
+	c.reset()
+	select {
+	case p.contexts <- c:
+	default:
+	}
+}
+
+// dataStore holds the data of one execution, shared by the graph and its sub graphs.
+type dataStore struct {
+	mutex sync.RWMutex
+	data  map[string]interface{}
+}
+
+func (p *dataStore) get(id string) (interface{}, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	v, exist := p.data[id]
+	return v, exist
+}
+
+func (p *dataStore) set(id string, v interface{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.data[id] = v
+}
+
+func (p *dataStore) snapshot() map[string]interface{} {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	data := make(map[string]interface{}, len(p.data))
+	for k, v := range p.data {
+		data[k] = v
+	}
+	return data
+}
+
+// execution is the state of one Execute call.
+type execution struct {
+	executor *Executor
+	store    *dataStore
+	mutex    sync.Mutex
+	results  []*VertexResult
+	configs  map[string]bool
+}
+
+func (p *Executor) newExecution() *execution {
+	return &execution{
+		executor: p,
+		store:    &dataStore{data: make(map[string]interface{})},
+		configs:  make(map[string]bool),
+	}
+}
+
+func (p *Executor) getExecution() *execution {
+	select {
+	case e := <-p.executions:
+		return e
+	default:
+		return p.newExecution()
+	}
+}
+
+func (p *Executor) putExecution(e *execution) {
+	for id := range e.store.data {
+		delete(e.store.data, id)
+	}
+	for name := range e.configs {
+		delete(e.configs, name)
+	}
+	// results are returned to the caller, never reused
+	e.results = nil
+	select {
+	case p.executions <- e:
+	default:
+	}
+}
+
+func (p *execution) addResult(r *VertexResult) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.results = append(p.results, r)
+}
+
+// evalConfig evaluates the config setting once per execution, name with prefix '!' is negated.
+func (p *execution) evalConfig(ctx context.Context, cluster *GraphCluster, name string) (bool, error) {
+	negated := len(name) > 0 && name[0] == '!'
+	if negated {
+		name = name[1:]
+	}
+	key := cluster.name + "::" + name
+	p.mutex.Lock()
+	v, exist := p.configs[key]
+	p.mutex.Unlock()
+	if !exist {
+		var cond string
+		for _, c := range cluster.ConfigSetting {
+			if c.Name == name {
+				cond = c.Cond
+			}
+		}
+		var err error
+		if v, err = p.eval(ctx, cond); nil != err {
+			return false, fmt.Errorf("Failed to eval config_setting:%s with err:%v", name, err)
+		}
+		p.mutex.Lock()
+		p.configs[key] = v
+		p.mutex.Unlock()
+	}
+	return v != negated, nil
+}
+
+func (p *execution) eval(ctx context.Context, expr string) (bool, error) {
+	if nil == p.executor.evalCond {
+		return false, fmt.Errorf("No cond evaluator to eval:%s", expr)
+	}
+	return p.executor.evalCond(ctx, expr, p.store.snapshot())
+}
+
+// Execute executes the graph of the config with the initial data, the error is only returned if the
+// graph could not be executed, failures of vertices are reported in the result.
+// Once ctx is done, in-flight operators see the cancelled ctx and no more vertex is executed,
+// the remaining vertices(also those of running sub graphs) are reported as cancelled.
+func (p *Executor) Execute(ctx context.Context, graph string, data map[string]interface{}) (*ExecuteResult, error) {
+	g := p.cluster.getGraphByName(graph)
+	if nil == g {
+		return nil, fmt.Errorf("No graph:%s found in cluster:%s", graph, p.cluster.name)
+	}
+	e := p.getExecution()
+	defer p.putExecution(e)
+	for k, v := range data {
+		e.store.data[k] = v
+	}
+	e.executeGraph(ctx, g, g.Name, nil)
+	sort.SliceStable(e.results, func(i, j int) bool {
+		if e.results[i].Graph != e.results[j].Graph {
+			return e.results[i].Graph < e.results[j].Graph
+		}
+		return e.results[i].ID < e.results[j].ID
+	})
+	return &ExecuteResult{Graph: g.Name, Data: e.store.snapshot(), Vertexs: e.results, Err: ctx.Err()}, nil
+}
+
+// graphContext schedules vertices of one graph execution once their deps are done, the dependency counters,
+// results and operator instances are pre-built and reused across executions.
+type graphContext struct {
+	e    *execution
+	g    *Graph
+	path string
+	// stack of graphs from the executed graph to this graph, used to fail recursive sub graph calls
+	stack     []*Graph
+	mutex     sync.Mutex
+	pending   map[string]int
+	results   map[string]*VertexResult
+	operators map[string]*boundOperator
+	wg        sync.WaitGroup
+	failed    bool
+}
+
+func newGraphContext(executor *Executor, g *Graph) *graphContext {
+	c := &graphContext{
+		g:         g,
+		pending:   make(map[string]int, len(g.vertexMap)),
+		results:   make(map[string]*VertexResult, len(g.vertexMap)),
+		operators: make(map[string]*boundOperator),
+	}
+	for id, v := range g.vertexMap {
+		if len(v.Processor) == 0 || len(v.Cond) > 0 || len(v.Graph) > 0 {
+			continue
+		}
+		if op, err := executor.newOperator(v.opName); nil == err {
+			c.operators[id] = op
+		}
+	}
+	return c
+}
+
+func (p *graphContext) reset() {
+	p.e = nil
+	p.path = ""
+	p.stack = nil
+	p.failed = false
+	for id := range p.results {
+		delete(p.results, id)
+	}
+}
+
+// operator returns the operator instance of the vertex reset to zero value.
+func (p *graphContext) operator(v *Vertex) (*boundOperator, error) {
+	p.mutex.Lock()
+	op, exist := p.operators[v.ID]
+	p.mutex.Unlock()
+	if !exist {
+		var err error
+		if op, err = p.e.executor.newOperator(v.opName); nil != err {
+			return nil, err
+		}
+		p.mutex.Lock()
+		p.operators[v.ID] = op
+		p.mutex.Unlock()
+	}
+	op.value.Elem().Set(reflect.Zero(op.r.typ))
+	return op, nil
+}
+
+// discard drops the operator instance abandoned by a timeout or cancellation, it may still be running.
+func (p *graphContext) discard(v *Vertex, op *boundOperator) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.operators[v.ID] == op {
+		delete(p.operators, v.ID)
+	}
+}
+
+// executeGraph executes the graph called by the graphs of the stack, returns V_RESULT_ERR if any vertex failed with error.
+func (p *execution) executeGraph(ctx context.Context, g *Graph, path string, stack []*Graph) int {
+	pool := p.executor.pools[g]
+	r := pool.get()
+	defer pool.put(r)
+	r.e = p
+	r.path = path
+	r.stack = append(stack[:len(stack):len(stack)], g)
+	if instrument := p.executor.instrument; nil != instrument {
+		info := &GraphInfo{Cluster: g.cluster.name, Graph: g.Name, Path: path}
+		start := time.Now()
+		ctx = instrument.StartGraph(ctx, info)
+		result := r.execute(ctx)
+		instrument.EndGraph(ctx, info, result, time.Since(start))
+		return result
+	}
+	return r.execute(ctx)
+}
+
+func (p *graphContext) execute(ctx context.Context) int {
+	var ready []*Vertex
+	for _, v := range p.g.sortedVertexs() {
+		p.pending[v.ID] = len(v.depsResults)
+		if len(v.depsResults) == 0 {
+			ready = append(ready, v)
+		}
+	}
+	for _, v := range ready {
+		p.schedule(ctx, v)
+	}
+	p.wg.Wait()
+	if p.failed {
+		return V_RESULT_ERR
+	}
+	return V_RESULT_OK
+}
+
+func (p *graphContext) schedule(ctx context.Context, v *Vertex) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		instrument := p.e.executor.instrument
+		if !p.e.executor.trace && nil == instrument {
+			p.done(ctx, v, p.run(ctx, v))
+			return
+		}
+		vctx := ctx
+		var info *VertexInfo
+		if nil != instrument {
+			info = &VertexInfo{
+				Cluster:   p.g.cluster.name,
+				Graph:     p.g.Name,
+				Path:      p.path,
+				Vertex:    v.ID,
+				DotId:     v.getDotId(),
+				Processor: v.opName,
+				Cond:      v.Cond,
+			}
+			vctx = instrument.StartVertex(ctx, info)
+		}
+		start := time.Now()
+		result := p.run(vctx, v)
+		end := time.Now()
+		if p.e.executor.trace {
+			result.Start = start
+			result.End = end
+			result.Goroutine = goroutineId()
+		}
+		if nil != instrument {
+			instrument.EndVertex(vctx, info, result, end.Sub(start))
+		}
+		p.done(ctx, v, result)
+	}()
+}
+
+func (p *graphContext) done(ctx context.Context, v *Vertex, result *VertexResult) {
+	p.e.addResult(result)
+	var ready []*Vertex
+	p.mutex.Lock()
+	p.results[v.ID] = result
+	if nil != result.Err {
+		p.failed = true
+	}
+	for _, successor := range v.successorVertex {
+		p.pending[successor.ID]--
+		if p.pending[successor.ID] == 0 {
+			ready = append(ready, successor)
+		}
+	}
+	p.mutex.Unlock()
+	for _, successor := range ready {
+		p.schedule(ctx, successor)
+	}
+}
+
+func (p *graphContext) run(ctx context.Context, v *Vertex) *VertexResult {
+	result := &VertexResult{Graph: p.path, ID: v.ID, Result: V_RESULT_ERR}
+	if nil != ctx.Err() {
+		result.skip()
+		result.Cancelled = true
+		return result
+	}
+	p.mutex.Lock()
+	matched := depsMatched(v, p.results)
+	p.mutex.Unlock()
+	if !matched {
+		result.skip()
+		return result
+	}
+	if len(v.ExpectConfig) > 0 {
+		ok, err := p.e.evalConfig(ctx, p.g.cluster, v.ExpectConfig)
+		if nil != err {
+			result.Err = err
+			return result
+		}
+		p.traceCond(result, v.ExpectConfig, ok)
+		if !ok {
+			result.skip()
+			return result
+		}
+	}
+	if len(v.Cond) > 0 {
+		ok, err := p.e.eval(ctx, v.Cond)
+		if nil != err {
+			result.Err = err
+		} else if ok {
+			result.Result = V_RESULT_OK
+		}
+		if nil == err {
+			p.traceCond(result, v.Cond, ok)
+		}
+		return result
+	}
+	var err error
+	for result.Attempts = 1; ; result.Attempts++ {
+		if err = p.attempt(ctx, v); nil == err || result.Attempts > v.Retry || nil != ctx.Err() {
+			break
+		}
+		if backoff := v.retryBackoff << uint(result.Attempts-1); backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+			case <-timer.C:
+			}
+			timer.Stop()
+			if nil != ctx.Err() {
+				break
+			}
+		}
+	}
+	if nil == err {
+		result.Result = V_RESULT_OK
+	} else {
+		result.Err = fmt.Errorf("[%s/%s]%v", p.path, v.ID, err)
+		result.Fallback = p.fallback(ctx, v)
+	}
+	p.traceOutputs(result, v)
+	return result
+}
+
+// attempt executes the vertex once within its timeout.
+func (p *graphContext) attempt(ctx context.Context, v *Vertex) error {
+	if v.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.timeout)
+		defer cancel()
+	}
+	if len(v.Graph) > 0 {
+		return p.executeSubGraph(ctx, v)
+	}
+	if len(v.Processor) == 0 {
+		return nil
+	}
+	op, err := p.operator(v)
+	if nil != err {
+		return err
+	}
+	return p.executeOperator(ctx, v, op)
+}
+
+func (p *graphContext) executeSubGraph(ctx context.Context, v *Vertex) error {
+	cluster, exist := p.e.executor.clusters[v.Cluster]
+	if !exist {
+		return fmt.Errorf("No cluster:%s found", v.Cluster)
+	}
+	g := cluster.getGraphByName(v.Graph)
+	if nil == g {
+		return fmt.Errorf("No graph:%s found in cluster:%s", v.Graph, v.Cluster)
+	}
+	for _, caller := range p.stack {
+		if caller == g {
+			return fmt.Errorf("Sub graph:%s::%s is called recursively by %s", v.Cluster, v.Graph, p.path)
+		}
+	}
+	result := p.e.executeGraph(ctx, g, p.path+"/"+v.ID+"/"+g.Name, p.stack)
+	if nil != ctx.Err() {
+		return ctx.Err()
+	}
+	if result != V_RESULT_OK {
+		return fmt.Errorf("Sub graph:%s::%s failed", v.Cluster, v.Graph)
+	}
+	return nil
+}
+
+// fallback applies the fallback processor and default outputs after all attempts failed.
+func (p *graphContext) fallback(ctx context.Context, v *Vertex) bool {
+	applied := false
+	if len(v.Fallback) > 0 && nil == ctx.Err() {
+		op, err := p.e.executor.newOperator(v.fallbackOpName)
+		if nil == err {
+			err = p.executeOperator(ctx, v, op)
+		}
+		applied = nil == err
+	}
+	for id, value := range v.DefaultOutput {
+		if _, exist := p.e.store.get(id); !exist {
+			p.e.store.set(id, value)
+			applied = true
+		}
+	}
+	return applied
+}
+
+type boundOperator struct {
+	r     *registeredOperator
+	value reflect.Value
+}
+
+func (p *Executor) newOperator(name string) (*boundOperator, error) {
+	r := p.registry.get(name)
+	if nil == r {
+		return nil, fmt.Errorf("No go operator:%s registered", name)
+	}
+	return &boundOperator{r: r, value: reflect.New(r.typ)}, nil
+}
+
+func dataIdOf(list []GraphData, field string) *GraphData {
+	for i := range list {
+		if list[i].Field == field {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+func assignData(field reflect.Value, id string, value interface{}) error {
+	if nil == value {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(field.Type()) {
+		return fmt.Errorf("Data:%s with type %s is not assignable to %s", id, v.Type(), field.Type())
+	}
+	field.Set(v)
+	return nil
+}
+
+func (p *graphContext) injectInput(v *Vertex, field reflect.Value, binding opFieldBinding) error {
+	data := dataIdOf(v.Input, binding.name)
+	if nil == data || len(data.Aggregate) == 0 {
+		id := binding.name
+		if nil != data {
+			id = data.ID
+		}
+		value, _ := p.e.store.get(id)
+		return assignData(field, id, value)
+	}
+	switch field.Kind() {
+	case reflect.Map:
+		m := reflect.MakeMap(field.Type())
+		for _, id := range data.Aggregate {
+			if value, exist := p.e.store.get(id); exist && nil != value {
+				elem := reflect.New(field.Type().Elem()).Elem()
+				if err := assignData(elem, id, value); nil != err {
+					return err
+				}
+				m.SetMapIndex(reflect.ValueOf(id).Convert(field.Type().Key()), elem)
+			}
+		}
+		field.Set(m)
+	case reflect.Slice:
+		s := reflect.MakeSlice(field.Type(), 0, len(data.Aggregate))
+		for _, id := range data.Aggregate {
+			if value, exist := p.e.store.get(id); exist && nil != value {
+				elem := reflect.New(field.Type().Elem()).Elem()
+				if err := assignData(elem, id, value); nil != err {
+					return err
+				}
+				s = reflect.Append(s, elem)
+			}
+		}
+		field.Set(s)
+	default:
+		return fmt.Errorf("Aggregate input:%s must be a map or slice", binding.name)
+	}
+	return nil
+}
+
+// selectArgs returns args of the first select_args whose config setting matches, or the vertex args.
+func (p *graphContext) selectArgs(ctx context.Context, v *Vertex) (map[string]interface{}, error) {
+	for _, cond := range v.SelectArgs {
+		ok, err := p.e.evalConfig(ctx, p.g.cluster, cond.Match)
+		if nil != err {
+			return nil, err
+		}
+		if ok {
+			return cond.Args, nil
+		}
+	}
+	return v.Args, nil
+}
+
+func (p *graphContext) executeOperator(ctx context.Context, v *Vertex, op *boundOperator) error {
+	instance := op.value.Elem()
+	for _, binding := range op.r.inputs {
+		if err := p.injectInput(v, instance.FieldByIndex(binding.index), binding); nil != err {
+			return err
+		}
+	}
+	args, err := p.selectArgs(ctx, v)
+	if nil != err {
+		return err
+	}
+	operator, ok := op.value.Interface().(Operator)
+	if !ok {
+		return fmt.Errorf("Go operator:%s does not implement Operator", op.r.meta.FullName())
+	}
+	errc := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); nil != r {
+				errc <- fmt.Errorf("Go operator:%s panic:%v", op.r.meta.FullName(), r)
+			}
+		}()
+		errc <- operator.Execute(ctx, args)
+	}()
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		// the operator is abandoned, its outputs are never collected
+		p.discard(v, op)
+		return ctx.Err()
+	}
+	if nil != err {
+		return err
+	}
+	for _, binding := range op.r.outputs {
+		id := binding.name
+		if data := dataIdOf(v.Output, binding.name); nil != data {
+			id = data.ID
+		}
+		p.e.store.set(id, instance.FieldByIndex(binding.index).Interface())
+	}
+	return nil
+}
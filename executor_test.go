@@ -0,0 +1,491 @@
+package didagle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testVersionV1Op struct {
+	Version string `didagle:"output,name=version"`
+}
+
+func (p *testVersionV1Op) Execute(ctx context.Context, args map[string]interface{}) error {
+	p.Version = "v1"
+	return nil
+}
+
+type testVersionV2Op struct {
+	Version string `didagle:"output,name=version"`
+}
+
+func (p *testVersionV2Op) Execute(ctx context.Context, args map[string]interface{}) error {
+	p.Version = "v2"
+	return nil
+}
+
+func writeTestScript(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.toml")
+	if err := os.WriteFile(path, []byte(script), 0644); nil != err {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExecuteVersionedOperator(t *testing.T) {
+	registry := NewOperatorRegistry()
+	registry.Register("foo@v1", &testVersionV1Op{})
+	registry.Register("foo@v2", &testVersionV2Op{})
+	registry.Register("nop", &testNopOp{})
+	cfg, err := NewDAGConfigByProviders(writeTestScript(t, `
+strict_dsl = true
+[[graph]]
+name = "g"
+[[graph.vertex]]
+processor = "foo"
+successor = ["nop"]
+[[graph.vertex]]
+processor = "nop"
+[[graph]]
+name = "pinned"
+[[graph.vertex]]
+processor = "foo@v1"
+successor = ["nop"]
+[[graph.vertex]]
+processor = "nop"
+`), registry)
+	if nil != err {
+		t.Fatalf("Failed to build with err:%v", err)
+	}
+	executor := NewExecutor(cfg, &ExecutorOptions{Registry: registry})
+	for graph, version := range map[string]string{"g": "v2", "pinned": "v1"} {
+		rs, err := executor.Execute(context.Background(), graph, nil)
+		if nil != err {
+			t.Fatal(err)
+		}
+		for _, v := range rs.Vertexs {
+			if nil != v.Err {
+				t.Errorf("Vertex:%s/%s failed with err:%v", v.Graph, v.ID, v.Err)
+			}
+		}
+		if rs.Data["version"] != version {
+			t.Errorf("Graph:%s expect version:%s, but got %v", graph, version, rs.Data["version"])
+		}
+	}
+	dot, err := cfg.Render("dot", nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dot), `"pinned_foo@v1"`) || strings.Contains(string(dot), " pinned_foo@v1") {
+		t.Errorf("Expect quoted dot id of versioned vertex:%s", dot)
+	}
+}
+
+func TestDotQuote(t *testing.T) {
+	tests := map[string]string{
+		"g_a":       "g_a",
+		"_a1":       "_a1",
+		"1a":        `"1a"`,
+		"g_foo@v1":  `"g_foo@v1"`,
+		"g_a.b":     `"g_a.b"`,
+		`g_"x"`:     `"g_\"x\""`,
+		"cluster_g": "cluster_g",
+	}
+	for id, want := range tests {
+		if got := dotQuote(id); got != want {
+			t.Errorf("dotQuote(%s):%s, want:%s", id, got, want)
+		}
+	}
+}
+
+func TestExecuteRecursiveSubGraph(t *testing.T) {
+	registry := newTestRegistry(t)
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.toml": `
+[[graph]]
+name = "self"
+[[graph.vertex]]
+id = "call"
+graph = "self"
+if = ["after"]
+[[graph.vertex]]
+id = "after"
+processor = "nop"
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "call"
+cluster = "b.toml"
+graph = "other"
+else = ["recover"]
+[[graph.vertex]]
+id = "recover"
+processor = "nop"
+`,
+		"b.toml": `
+[[graph]]
+name = "other"
+[[graph.vertex]]
+id = "back"
+cluster = "a.toml"
+graph = "main"
+if = ["after"]
+[[graph.vertex]]
+id = "after"
+processor = "nop"
+`,
+	})
+	a, err := NewDAGConfigByProviders(filepath.Join(dir, "a.toml"), registry)
+	if nil != err {
+		t.Fatal(err)
+	}
+	b, err := NewDAGConfigByProviders(filepath.Join(dir, "b.toml"), registry)
+	if nil != err {
+		t.Fatal(err)
+	}
+	executor := NewExecutor(a, &ExecutorOptions{Registry: registry, Clusters: []*DAGConfig{b}})
+	tests := map[string][]string{
+		"self": {"self:after=skipped", "self:call=err"},
+		"main": {"main:call=err", "main:recover=ok", "main/call/other:after=skipped", "main/call/other:back=err"},
+	}
+	for graph, want := range tests {
+		rs, err := executor.Execute(context.Background(), graph, nil)
+		if nil != err {
+			t.Fatal(err)
+		}
+		var got []string
+		recursive := false
+		for _, r := range rs.Vertexs {
+			got = append(got, r.Graph+":"+r.ID+"="+resultName(r))
+			recursive = recursive || (nil != r.Err && strings.Contains(r.Err.Error(), "called recursively"))
+		}
+		if !reflect.DeepEqual(got, want) || !recursive {
+			t.Errorf("%s: expect results:%v with recursive call error, but got %v", graph, want, got)
+		}
+	}
+}
+
+var testFlakyAttempts int32
+
+// testFlakyOp fails until it's executed more than 'fails' times.
+type testFlakyOp struct{}
+
+func (p *testFlakyOp) Execute(ctx context.Context, args map[string]interface{}) error {
+	if n := atomic.AddInt32(&testFlakyAttempts, 1); int64(n) <= args["fails"].(int64) {
+		return fmt.Errorf("flaky attempt:%d", n)
+	}
+	return nil
+}
+
+type testSlowOp struct{}
+
+func (p *testSlowOp) Execute(ctx context.Context, args map[string]interface{}) error {
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+	}
+	return nil
+}
+
+type testProduceOp struct {
+	V string `didagle:"output,name=v"`
+}
+
+func (p *testProduceOp) Execute(ctx context.Context, args map[string]interface{}) error {
+	p.V = "fallback"
+	return nil
+}
+
+func TestExecuteFaultTolerance(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.Register("flaky", &testFlakyOp{})
+	registry.Register("slow", &testSlowOp{})
+	registry.Register("produce", &testProduceOp{})
+	cfg, err := NewDAGConfigByProviders(writeTestScript(t, `
+[[graph]]
+name = "retry"
+[[graph.vertex]]
+id = "flaky"
+processor = "flaky"
+args = { fails = 2 }
+retry = 2
+retry_backoff = "20ms"
+if = ["after"]
+[[graph.vertex]]
+id = "after"
+processor = "nop"
+[[graph]]
+name = "exhausted"
+[[graph.vertex]]
+id = "flaky"
+processor = "flaky"
+args = { fails = 5 }
+retry = 1
+else = ["after"]
+[[graph.vertex]]
+id = "after"
+processor = "nop"
+[[graph]]
+name = "timeout"
+[[graph.vertex]]
+id = "slow"
+processor = "slow"
+timeout = "10ms"
+if = ["ok"]
+else = ["recover"]
+[[graph.vertex]]
+id = "ok"
+processor = "nop"
+[[graph.vertex]]
+id = "recover"
+processor = "nop"
+[[graph.vertex]]
+id = "on_err"
+processor = "nop"
+deps_on_err = ["slow"]
+[[graph]]
+name = "fallback"
+[[graph.vertex]]
+id = "bad"
+processor = "fail"
+fallback = "produce"
+else = ["recover"]
+[[graph.vertex]]
+id = "recover"
+processor = "nop"
+[[graph]]
+name = "default"
+[[graph.vertex]]
+id = "bad"
+processor = "fail"
+default_output = { v = "default", w = 1 }
+else = ["recover"]
+[[graph.vertex]]
+id = "recover"
+processor = "nop"
+`), registry)
+	if nil != err {
+		t.Fatalf("Failed to build with err:%v", err)
+	}
+	executor := NewExecutor(cfg, &ExecutorOptions{Registry: registry})
+	tests := []struct {
+		graph    string
+		id       string
+		results  []string
+		attempts int
+		fallback bool
+		data     map[string]interface{}
+		elapsed  time.Duration
+	}{
+		// backoff of the n-th retry is 20ms*2^(n-1)
+		{"retry", "flaky", []string{"after=ok", "flaky=ok"}, 3, false, nil, 60 * time.Millisecond},
+		{"exhausted", "flaky", []string{"after=ok", "flaky=err"}, 2, false, nil, 0},
+		{"timeout", "slow", []string{"ok=skipped", "on_err=ok", "recover=ok", "slow=err"}, 1, false, nil, 0},
+		{"fallback", "bad", []string{"bad=err", "recover=ok"}, 1, true, map[string]interface{}{"v": "fallback"}, 0},
+		{"default", "bad", []string{"bad=err", "recover=ok"}, 1, true, map[string]interface{}{"v": "default", "w": int64(1)}, 0},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&testFlakyAttempts, 0)
+		start := time.Now()
+		rs, err := executor.Execute(context.Background(), test.graph, nil)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < test.elapsed || elapsed > time.Second/2 {
+			t.Errorf("%s: unexpected elapsed:%v", test.graph, elapsed)
+		}
+		var results []string
+		for _, r := range rs.Vertexs {
+			results = append(results, r.ID+"="+resultName(r))
+		}
+		if !reflect.DeepEqual(results, test.results) {
+			t.Errorf("%s: expect results:%v, but got %v", test.graph, test.results, results)
+		}
+		if r := rs.Get(test.graph, test.id); r.Attempts != test.attempts || r.Fallback != test.fallback {
+			t.Errorf("%s: expect %d attempts & fallback:%v, but got %d & %v", test.graph, test.attempts, test.fallback, r.Attempts, r.Fallback)
+		}
+		for id, value := range test.data {
+			if rs.Data[id] != value {
+				t.Errorf("%s: expect data %s=%v, but got %v", test.graph, id, value, rs.Data[id])
+			}
+		}
+	}
+}
+
+// drainPool takes all pooled contexts of the graph, then puts them back.
+func drainPool(executor *Executor, g *Graph) []*graphContext {
+	pool := executor.pools[g]
+	var contexts []*graphContext
+	for len(pool.contexts) > 0 {
+		contexts = append(contexts, <-pool.contexts)
+	}
+	for _, c := range contexts {
+		pool.contexts <- c
+	}
+	return contexts
+}
+
+func TestExecuteContextPool(t *testing.T) {
+	registry := newTestRegistry(t)
+	script := `
+default_context_pool_size = %d
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "a"
+processor = "nop"
+successor = ["b"]
+[[graph.vertex]]
+id = "b"
+processor = "nop"
+`
+	for _, size := range []int{0, 2} {
+		cfg, err := NewDAGConfigByProviders(writeTestScript(t, fmt.Sprintf(script, size)), registry)
+		if nil != err {
+			t.Fatal(err)
+		}
+		executor := NewExecutor(cfg, &ExecutorOptions{Registry: registry})
+		g := cfg.graph.getGraphByName("g")
+		pooled := drainPool(executor, g)
+		if len(pooled) != size || cap(executor.executions) != size {
+			t.Fatalf("Expect pool size:%d, but got %d contexts & %d executions", size, len(pooled), cap(executor.executions))
+		}
+		for i := 0; i < 3; i++ {
+			data := map[string]interface{}{}
+			if i == 0 {
+				data["x"] = 1
+			}
+			rs, err := executor.Execute(context.Background(), "g", data)
+			if nil != err {
+				t.Fatal(err)
+			}
+			if len(rs.Vertexs) != 2 || len(rs.Completed()) != 2 {
+				t.Errorf("size:%d: unexpected results of execution:%d:%v", size, i, rs.Vertexs)
+			}
+			// data & results of previous executions are never visible
+			if _, exist := rs.Data["x"]; exist != (i == 0) {
+				t.Errorf("size:%d: unexpected data of execution:%d:%v", size, i, rs.Data)
+			}
+		}
+		reused := drainPool(executor, g)
+		if !reflect.DeepEqual(reused, pooled) {
+			t.Errorf("size:%d: expect pooled contexts reused", size)
+		}
+		for _, c := range reused {
+			if nil != c.e || len(c.path) > 0 || len(c.stack) > 0 || len(c.results) > 0 || c.failed {
+				t.Errorf("size:%d: expect pooled context reset, but got %+v", size, c)
+			}
+		}
+	}
+
+	_, err := NewDAGConfigByProviders(writeTestScript(t, fmt.Sprintf(script, -1)), registry)
+	if nil == err || !strings.Contains(err.Error(), "Invalid default_context_pool_size:-1") {
+		t.Errorf("Expect invalid pool size error, but got:%v", err)
+	}
+
+	config := &DAGConfig{}
+	config.graph.DefaultDefaultPoolSize = "3"
+	if err := config.loadTomlScriptContent(fmt.Sprintf(script, 0)); nil != err {
+		t.Fatal(err)
+	}
+	if config.graph.DefaultContextPoolSize != 3 {
+		t.Errorf("Expect pool size from deprecated DefaultDefaultPoolSize, but got %d", config.graph.DefaultContextPoolSize)
+	}
+
+	// scripts written for the string typed setting still decode
+	stringScript := strings.Replace(script, "%d", `"%s"`, 1)
+	cfg, err := NewDAGConfigByProviders(writeTestScript(t, fmt.Sprintf(stringScript, "2")), registry)
+	if nil != err {
+		t.Fatal(err)
+	}
+	executor := NewExecutor(cfg, &ExecutorOptions{Registry: registry})
+	if pooled := drainPool(executor, cfg.graph.getGraphByName("g")); len(pooled) != 2 || cap(executor.executions) != 2 {
+		t.Errorf("Expect pool size:2 from string setting, but got %d contexts & %d executions", len(pooled), cap(executor.executions))
+	}
+	for _, invalid := range []string{`"16x"`, `1.5`, `true`} {
+		_, err := NewDAGConfigByProviders(writeTestScript(t, strings.Replace(script, "%d", invalid, 1)), registry)
+		if nil == err || !strings.Contains(err.Error(), "Invalid default_context_pool_size") {
+			t.Errorf("%s: expect invalid pool size error, but got:%v", invalid, err)
+		}
+	}
+}
+
+func TestExecuteDeadline(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.Register("slow", &testSlowOp{})
+	cfg, err := NewDAGConfigByProviders(writeTestScript(t, `
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "a"
+processor = "nop"
+successor = ["slow", "call"]
+[[graph.vertex]]
+id = "slow"
+processor = "slow"
+successor = ["after"]
+[[graph.vertex]]
+id = "after"
+processor = "nop"
+[[graph.vertex]]
+id = "call"
+graph = "sub"
+successor = ["after_call"]
+[[graph.vertex]]
+id = "after_call"
+processor = "nop"
+[[graph]]
+name = "sub"
+[[graph.vertex]]
+id = "s1"
+processor = "slow"
+successor = ["s2"]
+[[graph.vertex]]
+id = "s2"
+processor = "nop"
+`), registry)
+	if nil != err {
+		t.Fatal(err)
+	}
+	executor := NewExecutor(cfg, &ExecutorOptions{Registry: registry})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	rs, err := executor.Execute(ctx, "main", nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second/2 {
+		t.Errorf("Expect execution stopped at the deadline, but took %v", elapsed)
+	}
+	if rs.Err != context.DeadlineExceeded {
+		t.Errorf("Expect deadline exceeded, but got %v", rs.Err)
+	}
+	var got []string
+	for _, r := range rs.Vertexs {
+		got = append(got, r.Graph+":"+r.ID+"="+resultName(r))
+	}
+	want := []string{"main:a=ok", "main:after=cancelled", "main:after_call=cancelled", "main:call=err", "main:slow=err",
+		"main/call/sub:s1=err", "main/call/sub:s2=cancelled"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expect results:%v, but got %v", want, got)
+	}
+	var completed, skipped []string
+	for _, r := range rs.Completed() {
+		completed = append(completed, r.ID)
+	}
+	for _, r := range rs.Skipped() {
+		skipped = append(skipped, r.ID)
+	}
+	if !reflect.DeepEqual(completed, []string{"a", "call", "slow", "s1"}) || !reflect.DeepEqual(skipped, []string{"after", "after_call", "s2"}) {
+		t.Errorf("Unexpected completed:%v & skipped:%v", completed, skipped)
+	}
+}
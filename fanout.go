@@ -0,0 +1,207 @@
+package didagle
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const DEFAULT_FAN_OUT_PARAM = "i"
+
+// MAX_FAN_OUT is the max number of vertices a fan out vertex expands into.
+const MAX_FAN_OUT = 1024
+
+// FanOut expands a vertex into one vertex per value of 'values' or of the integer range [start, end),
+// '${param}' in the vertex is substituted by the value.
+type FanOut struct {
+	Param  string        `toml:"param"`
+	Values []interface{} `toml:"values"`
+	Range  []int         `toml:"range"`
+}
+
+func (p *FanOut) values() ([]string, error) {
+	if len(p.Values) > 0 && len(p.Range) > 0 {
+		return nil, fmt.Errorf("Can NOT both config 'values' & 'range' in fan_out")
+	}
+	var values []string
+	for _, v := range p.Values {
+		values = append(values, fmt.Sprint(v))
+	}
+	if len(p.Range) > 0 {
+		start, end := 0, p.Range[0]
+		if len(p.Range) == 2 {
+			start, end = p.Range[0], p.Range[1]
+		} else if len(p.Range) > 2 {
+			return nil, fmt.Errorf("Invalid fan_out range:%v, expect [end] or [start, end]", p.Range)
+		}
+		if end-start > MAX_FAN_OUT {
+			return nil, fmt.Errorf("Fan_out range:%v exceeds max fan out:%d", p.Range, MAX_FAN_OUT)
+		}
+		for i := start; i < end; i++ {
+			values = append(values, fmt.Sprint(i))
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("Empty fan_out values")
+	}
+	if len(values) > MAX_FAN_OUT {
+		return nil, fmt.Errorf("Fan_out values:%d exceeds max fan out:%d", len(values), MAX_FAN_OUT)
+	}
+	return values, nil
+}
+
+// fanOutInstance records the generated vertex ids and output data ids of a fan out vertex.
+type fanOutInstance struct {
+	ids     []string
+	outputs map[string][]string
+}
+
+func (p *Vertex) expandFanOut() ([]Vertex, *fanOutInstance, error) {
+	id := templateVertexId(p)
+	if len(id) == 0 {
+		return nil, nil, fmt.Errorf("Fan out vertex must have 'id' or 'processor'")
+	}
+	values, err := p.FanOut.values()
+	if nil != err {
+		return nil, nil, fmt.Errorf("Vertex:%s %v", id, err)
+	}
+	param := p.FanOut.Param
+	if len(param) == 0 {
+		param = DEFAULT_FAN_OUT_PARAM
+	}
+	instance := &fanOutInstance{outputs: make(map[string][]string)}
+	vertexs := make([]Vertex, 0, len(values))
+	suffixes := make(map[string]string)
+	for _, value := range values {
+		v := copySubstitute(reflect.ValueOf(*p), strings.NewReplacer("${"+param+"}", value)).Interface().(Vertex)
+		v.FanOut = nil
+		suffix := sanitizeId(value)
+		if prev, exist := suffixes[suffix]; exist {
+			return nil, nil, fmt.Errorf("Vertex:%s fan_out values:'%s' & '%s' generate the same id:%s_%s", id, prev, value, id, suffix)
+		}
+		suffixes[suffix] = value
+		v.ID = id + "_" + suffix
+		v.expandedFrom = "fan_out:" + id
+		for i := range v.Output {
+			data := &v.Output[i]
+			origin := p.Output[i].ID
+			if len(origin) == 0 {
+				origin = p.Output[i].Field
+			}
+			if len(origin) == 0 {
+				continue
+			}
+			// data ids using the param are already unique
+			if !strings.Contains(origin, "${") {
+				data.ID = origin + "_" + suffix
+			}
+			instance.outputs[origin] = append(instance.outputs[origin], data.ID)
+		}
+		instance.ids = append(instance.ids, v.ID)
+		vertexs = append(vertexs, v)
+	}
+	return vertexs, instance, nil
+}
+
+// sanitizeId replaces characters other than letters, digits and '_' of the fan out value,
+// so generated ids like 'fetch_a.b' are still valid dot ids.
+func sanitizeId(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, value)
+}
+
+func replaceFanOutRefs(ids []string, instances map[string]*fanOutInstance) []string {
+	var replaced []string
+	for _, id := range ids {
+		if instance, exist := instances[id]; exist {
+			replaced = append(replaced, instance.ids...)
+		} else {
+			replaced = append(replaced, id)
+		}
+	}
+	return replaced
+}
+
+// expandFanOuts expands fan out vertices, references to a fan out vertex are replaced by all generated vertices,
+// and inputs consuming an output of a fan out vertex become aggregate inputs of all generated outputs.
+func (p *Graph) expandFanOuts() error {
+	hasFanOut := false
+	for i := range p.Vertex {
+		hasFanOut = hasFanOut || nil != p.Vertex[i].FanOut
+	}
+	if !hasFanOut {
+		return nil
+	}
+	if p.cluster.StrictDsl {
+		// resolve implicit input/output from op meta first, so they are renamed & rewired as explicit ones
+		for i := range p.Vertex {
+			v := &p.Vertex[i]
+			v.g = p
+			if err := v.buildInputOutput(); nil != err {
+				return err
+			}
+		}
+	}
+	instances := make(map[string]*fanOutInstance)
+	outputs := make(map[string][]string)
+	var vertexs []Vertex
+	for i := range p.Vertex {
+		v := &p.Vertex[i]
+		if nil == v.FanOut {
+			vertexs = append(vertexs, *v)
+			continue
+		}
+		expanded, instance, err := v.expandFanOut()
+		if nil != err {
+			return newBuildError(p.Name, templateVertexId(v), "fan_out", "", "Graph:%s %v", p.Name, err)
+		}
+		instances[templateVertexId(v)] = instance
+		for data, ids := range instance.outputs {
+			if _, exist := outputs[data]; exist {
+				err := newBuildError(p.Name, templateVertexId(v), "output", data, "Duplicate fan out output:%s in graph:%s vertex:%s", data, p.Name, templateVertexId(v))
+				err.Duplicate = true
+				return err
+			}
+			outputs[data] = ids
+		}
+		vertexs = append(vertexs, expanded...)
+	}
+	for i := range vertexs {
+		v := &vertexs[i]
+		v.Deps = replaceFanOutRefs(v.Deps, instances)
+		v.DepsOnOk = replaceFanOutRefs(v.DepsOnOk, instances)
+		v.DepsOnErr = replaceFanOutRefs(v.DepsOnErr, instances)
+		v.Successor = replaceFanOutRefs(v.Successor, instances)
+		v.SuccessorOnOk = replaceFanOutRefs(v.SuccessorOnOk, instances)
+		v.SuccessorOnErr = replaceFanOutRefs(v.SuccessorOnErr, instances)
+		for j := range v.Input {
+			data := &v.Input[j]
+			if len(data.Aggregate) == 0 {
+				id := data.ID
+				if len(id) == 0 {
+					id = data.Field
+				}
+				if ids, exist := outputs[id]; exist {
+					data.ID = id
+					data.Aggregate = append([]string{}, ids...)
+				}
+				continue
+			}
+			var aggregate []string
+			for _, id := range data.Aggregate {
+				if ids, exist := outputs[id]; exist {
+					aggregate = append(aggregate, ids...)
+				} else {
+					aggregate = append(aggregate, id)
+				}
+			}
+			data.Aggregate = aggregate
+		}
+	}
+	p.Vertex = vertexs
+	return nil
+}
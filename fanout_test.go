@@ -0,0 +1,140 @@
+package didagle
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testFanOutOps = `[
+{"name":"gen","input":[],"output":[{"name":"x","id":1,"type":"int"}]},
+{"name":"merge","input":[{"name":"x","id":1,"type":"int","flags":{"is_aggregate":1}}],"output":[]}
+]`
+
+func TestFanOutStrictImplicitOutput(t *testing.T) {
+	cfg := mustBuild(t, testFanOutOps, `
+strict_dsl = true
+[[graph]]
+name = "g"
+[[graph.vertex]]
+processor = "gen"
+fan_out = { range = [2] }
+[[graph.vertex]]
+processor = "merge"
+`)
+	g := cfg.graph.getGraphByName("g")
+	for _, id := range []string{"gen_0", "gen_1"} {
+		v := g.getVertexById(id)
+		if nil == v {
+			t.Fatalf("No fan out vertex:%s", id)
+		}
+		if len(v.Output) != 1 || v.Output[0].ID != "x_"+id[len("gen_"):] || v.Output[0].Field != "x" {
+			t.Errorf("Unexpected output of %s:%+v", id, v.Output)
+		}
+	}
+	merge := g.getVertexById("merge")
+	if len(merge.Input) != 1 || !reflect.DeepEqual(merge.Input[0].Aggregate, []string{"x_0", "x_1"}) {
+		t.Errorf("Unexpected aggregate input of merge:%+v", merge.Input)
+	}
+	if !reflect.DeepEqual(visibleIds(boolSet(merge.depsResults)), []string{"gen_0", "gen_1"}) {
+		t.Errorf("Unexpected deps of merge:%v", merge.depsResults)
+	}
+}
+
+func boolSet(m map[string]int) map[string]bool {
+	s := make(map[string]bool)
+	for k := range m {
+		s[k] = true
+	}
+	return s
+}
+
+func TestFanOutSanitizeId(t *testing.T) {
+	cfg := mustBuild(t, "", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "fetch"
+processor = "fetch"
+fan_out = { values = ["a.b", "c d"] }
+output = [{ field = "out" }]
+[[graph.vertex]]
+processor = "merge"
+input = [{ field = "out" }]
+`)
+	g := cfg.graph.getGraphByName("g")
+	for _, id := range []string{"fetch_a_b", "fetch_c_d"} {
+		if nil == g.getVertexById(id) {
+			t.Errorf("No fan out vertex:%s", id)
+		}
+	}
+	dot, err := cfg.Render("dot", nil)
+	if nil != err {
+		t.Fatalf("Failed to render dot with err:%v", err)
+	}
+	if strings.Contains(string(dot), "fetch_a.b") || !strings.Contains(string(dot), "fetch_a_b") {
+		t.Errorf("Unexpected dot ids:%s", dot)
+	}
+
+	_, err = NewDAGConfigByContent("", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+processor = "fetch"
+fan_out = { values = ["a.b", "a b"] }
+`)
+	if nil == err || !strings.Contains(err.Error(), "generate the same id") {
+		t.Errorf("Expect id conflict error, but got:%v", err)
+	}
+}
+
+func TestFanOutErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		errMsg string
+	}{
+		{"max range", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+processor = "fetch"
+fan_out = { range = [1000000000] }
+`, "exceeds max fan out"},
+		{"duplicate output", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "a"
+processor = "fetch"
+fan_out = { range = [2] }
+output = [{ field = "out" }]
+[[graph.vertex]]
+id = "b"
+processor = "fetch"
+fan_out = { values = ["x", "y"] }
+output = [{ field = "out" }]
+`, "Duplicate fan out output:out"},
+	}
+	for _, test := range tests {
+		_, err := NewDAGConfigByContent("", test.script)
+		var buildErr *BuildError
+		if !errors.As(err, &buildErr) || !strings.Contains(err.Error(), test.errMsg) {
+			t.Errorf("%s: expect build error:%s, but got:%v", test.name, test.errMsg, err)
+		}
+	}
+	cfg := mustBuild(t, "", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+processor = "fetch"
+fan_out = { range = [1, 1025] }
+[[graph.vertex]]
+processor = "merge"
+deps = ["fetch"]
+`)
+	if n := len(cfg.graph.getGraphByName("g").getVertexById("merge").depsResults); n != MAX_FAN_OUT {
+		t.Errorf("Expect %d fan out vertices, but got %d", MAX_FAN_OUT, n)
+	}
+}
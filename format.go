@@ -0,0 +1,141 @@
+package didagle
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// scanTomlValue updates the open bracket depth & multi line string state after the text.
+func scanTomlValue(text string, depth int, multiline string) (int, string) {
+	for i := 0; i < len(text); i++ {
+		if len(multiline) > 0 {
+			if strings.HasPrefix(text[i:], multiline) {
+				i += len(multiline) - 1
+				multiline = ""
+			}
+			continue
+		}
+		c := text[i]
+		switch c {
+		case '#':
+			return depth, multiline
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case '"', '\'':
+			quote := text[i : i+1]
+			if strings.HasPrefix(text[i:], quote+quote+quote) {
+				multiline = quote + quote + quote
+				i += 2
+				continue
+			}
+			for i++; i < len(text); i++ {
+				if c == '"' && text[i] == '\\' {
+					i++
+					continue
+				}
+				if text[i] == c {
+					break
+				}
+			}
+		}
+	}
+	return depth, multiline
+}
+
+// splitTomlKey splits a 'key = value' line, returns false if the line is not a key/value line.
+func splitTomlKey(line string) (string, string, bool) {
+	idx := strings.Index(line, "=")
+	if idx <= 0 {
+		return "", "", false
+	}
+	key := strings.TrimSpace(line[:idx])
+	if strings.ContainsAny(key, "[]{}#") {
+		return "", "", false
+	}
+	if strings.ContainsAny(key, "\"'") && !(key[0] == '"' || key[0] == '\'') {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(line[idx+1:]), true
+}
+
+func normalizeTomlHeader(line string) string {
+	var b strings.Builder
+	inQuote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if inQuote != 0 {
+			if c == inQuote {
+				inQuote = 0
+			}
+		} else if c == '"' || c == '\'' {
+			inQuote = c
+		} else if c == ' ' || c == '\t' {
+			continue
+		} else if c == '#' {
+			b.WriteString(" ")
+			b.WriteString(line[i:])
+			break
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// FormatScript rewrites a toml script into the canonical layout: no indent for top level keys & tables,
+// one space around '=', one blank line before each graph and no trailing spaces.
+// Comments & multi line values are kept as is.
+func FormatScript(content string) (string, error) {
+	var expect map[string]interface{}
+	if _, err := toml.Decode(content, &expect); nil != err {
+		return "", err
+	}
+	var lines []string
+	depth := 0
+	multiline := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if len(multiline) > 0 || depth > 0 {
+			lines = append(lines, line)
+			depth, multiline = scanTomlValue(line, depth, multiline)
+			continue
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case len(line) == 0:
+			if len(lines) > 0 && len(lines[len(lines)-1]) > 0 {
+				lines = append(lines, line)
+			}
+		case line[0] == '#':
+			lines = append(lines, line)
+		case line[0] == '[':
+			line = normalizeTomlHeader(line)
+			if strings.HasPrefix(line, "[[graph]]") && len(lines) > 0 {
+				last := lines[len(lines)-1]
+				if len(last) > 0 && last[0] != '#' {
+					lines = append(lines, "")
+				}
+			}
+			lines = append(lines, line)
+		default:
+			if key, value, ok := splitTomlKey(line); ok {
+				line = key + " = " + value
+				depth, multiline = scanTomlValue(value, depth, multiline)
+			}
+			lines = append(lines, line)
+		}
+	}
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	formated := strings.Join(lines, "\n") + "\n"
+	var actual map[string]interface{}
+	if _, err := toml.Decode(formated, &actual); nil != err || !reflect.DeepEqual(expect, actual) {
+		return "", fmt.Errorf("Format script changed the toml content, err:%v", err)
+	}
+	return formated, nil
+}
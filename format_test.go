@@ -0,0 +1,78 @@
+package didagle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"keys", "  strict_dsl=true\n\tdefault_expr_processor  =   \"expr\"   \n", "strict_dsl = true\ndefault_expr_processor = \"expr\"\n"},
+		{"graphs", `# scripts
+[[graph]]
+name = "a"
+  [[ graph.vertex ]]
+  processor="p"   # trailing
+[[graph]]
+
+
+name = "b"
+
+
+`, `# scripts
+[[graph]]
+name = "a"
+[[graph.vertex]]
+processor = "p"   # trailing
+
+[[graph]]
+
+name = "b"
+`},
+		{"comment before graph", "name = \"x\"\n# the graph\n[[graph]]\nname = \"a\"\n", "name = \"x\"\n# the graph\n[[graph]]\nname = \"a\"\n"},
+		{"multi line array", `[[graph]]
+name = "a"
+[[graph.vertex]]
+id = "v"
+deps = [
+      "x",   # first
+  "y" ]
+  successor=["z"]
+`, `[[graph]]
+name = "a"
+[[graph.vertex]]
+id = "v"
+deps = [
+      "x",   # first
+  "y" ]
+successor = ["z"]
+`},
+		{"multi line string", "desc = '''\n  [[graph]]\n  a=1'''\n  strict_dsl=false\n", "desc = '''\n  [[graph]]\n  a=1'''\nstrict_dsl = false\n"},
+		{"quoted", "\"a = b\" = \"[x]\"\n[ graph_args . \"k 1\" ]\nv=\"#\"\n", "\"a = b\" = \"[x]\"\n[graph_args.\"k 1\"]\nv = \"#\"\n"},
+	}
+	for _, test := range tests {
+		got, err := FormatScript(test.content)
+		if nil != err {
+			t.Errorf("%s: failed to format with err:%v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: expect:\n%s\nbut got:\n%s", test.name, test.want, got)
+		}
+		again, err := FormatScript(got)
+		if nil != err || again != got {
+			t.Errorf("%s: format is not idempotent, err:%v\n%s", test.name, err, again)
+		}
+	}
+	if _, err := FormatScript("[[graph]\nname = 1"); nil == err {
+		t.Errorf("Expect error for invalid toml")
+	}
+	// trailing spaces inside a multi line string are content, formatting can not drop them
+	if _, err := FormatScript("desc = \"\"\"x  \ny\"\"\"\n"); nil == err || !strings.Contains(err.Error(), "changed the toml content") {
+		t.Errorf("Expect changed content error, but got:%v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package didagle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GraphInfo identifies a graph execution, Path is the graph path like VertexResult.Graph.
+type GraphInfo struct {
+	Cluster string
+	Graph   string
+	Path    string
+}
+
+// VertexInfo identifies a vertex execution, DotId is the id of the vertex in rendered dot.
+// Cond is the expression of a cond vertex, Processor is then the expr processor evaluating it.
+type VertexInfo struct {
+	Cluster   string
+	Graph     string
+	Path      string
+	Vertex    string
+	DotId     string
+	Processor string
+	Cond      string
+}
+
+// Instrumentation receives execution events of the executor, it's the hook to export metrics and spans,
+// implementations must be safe for concurrent use. The context returned by a Start method is passed to
+// the matching End method and to nested executions, so spans of sub graphs are nested along the calls.
+type Instrumentation interface {
+	StartGraph(ctx context.Context, info *GraphInfo) context.Context
+	EndGraph(ctx context.Context, info *GraphInfo, result int, elapsed time.Duration)
+	StartVertex(ctx context.Context, info *VertexInfo) context.Context
+	EndVertex(ctx context.Context, info *VertexInfo, result *VertexResult, elapsed time.Duration)
+}
+
+type multiInstrumentation struct {
+	instruments []Instrumentation
+}
+
+// MultiInstrumentation combines instrumentations, events are delivered in order.
+func MultiInstrumentation(instruments ...Instrumentation) Instrumentation {
+	return &multiInstrumentation{instruments: instruments}
+}
+
+// multiContextsKey keys the contexts returned by each instrumentation's Start method, so each End method
+// receives its own context instead of the combined one.
+type multiContextsKey struct {
+	owner *multiInstrumentation
+}
+
+// context returns the context returned by the i-th instrumentation's Start method.
+func (p *multiInstrumentation) context(ctx context.Context, i int) context.Context {
+	if contexts, ok := ctx.Value(multiContextsKey{owner: p}).([]context.Context); ok && i < len(contexts) {
+		return contexts[i]
+	}
+	return ctx
+}
+
+func (p *multiInstrumentation) StartGraph(ctx context.Context, info *GraphInfo) context.Context {
+	contexts := make([]context.Context, len(p.instruments))
+	for i, instrument := range p.instruments {
+		ctx = instrument.StartGraph(ctx, info)
+		contexts[i] = ctx
+	}
+	return context.WithValue(ctx, multiContextsKey{owner: p}, contexts)
+}
+
+func (p *multiInstrumentation) EndGraph(ctx context.Context, info *GraphInfo, result int, elapsed time.Duration) {
+	for i, instrument := range p.instruments {
+		instrument.EndGraph(p.context(ctx, i), info, result, elapsed)
+	}
+}
+
+func (p *multiInstrumentation) StartVertex(ctx context.Context, info *VertexInfo) context.Context {
+	contexts := make([]context.Context, len(p.instruments))
+	for i, instrument := range p.instruments {
+		ctx = instrument.StartVertex(ctx, info)
+		contexts[i] = ctx
+	}
+	return context.WithValue(ctx, multiContextsKey{owner: p}, contexts)
+}
+
+func (p *multiInstrumentation) EndVertex(ctx context.Context, info *VertexInfo, result *VertexResult, elapsed time.Duration) {
+	for i, instrument := range p.instruments {
+		instrument.EndVertex(p.context(ctx, i), info, result, elapsed)
+	}
+}
+
+// DefaultLatencyBuckets are upper bounds in seconds of latency histograms.
+var DefaultLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type metricKey struct {
+	name   string
+	labels string
+}
+
+// Metrics is an Instrumentation collecting prometheus style metrics:
+//
+//	didagle_graph_duration_seconds{cluster,graph} histogram
+//	didagle_processor_duration_seconds{cluster,processor} histogram
+//	didagle_cond_duration_seconds{cluster,graph} histogram
+//	didagle_vertex_errors_total{cluster,graph,vertex,processor} counter
+//	didagle_vertex_skipped_total{cluster,graph,vertex} counter
+type Metrics struct {
+	buckets    []float64
+	mutex      sync.Mutex
+	histograms map[metricKey]*histogram
+	counters   map[metricKey]uint64
+}
+
+// NewMetrics creates metrics with the latency buckets, DefaultLatencyBuckets if empty.
+func NewMetrics(buckets []float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		buckets:    buckets,
+		histograms: make(map[metricKey]*histogram),
+		counters:   make(map[metricKey]uint64),
+	}
+}
+
+func metricLabels(kvs ...string) string {
+	pairs := make([]string, 0, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		value := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(kvs[i+1])
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", kvs[i], value))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (p *Metrics) observe(name string, labels string, elapsed time.Duration) {
+	key := metricKey{name: name, labels: labels}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	h, exist := p.histograms[key]
+	if !exist {
+		h = &histogram{counts: make([]uint64, len(p.buckets))}
+		p.histograms[key] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range p.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (p *Metrics) inc(name string, labels string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.counters[metricKey{name: name, labels: labels}]++
+}
+
+func (p *Metrics) StartGraph(ctx context.Context, info *GraphInfo) context.Context {
+	return ctx
+}
+
+func (p *Metrics) EndGraph(ctx context.Context, info *GraphInfo, result int, elapsed time.Duration) {
+	p.observe("didagle_graph_duration_seconds", metricLabels("cluster", info.Cluster, "graph", info.Graph), elapsed)
+}
+
+func (p *Metrics) StartVertex(ctx context.Context, info *VertexInfo) context.Context {
+	return ctx
+}
+
+func (p *Metrics) EndVertex(ctx context.Context, info *VertexInfo, result *VertexResult, elapsed time.Duration) {
+	if result.Skipped {
+		p.inc("didagle_vertex_skipped_total", metricLabels("cluster", info.Cluster, "graph", info.Graph, "vertex", info.Vertex))
+		return
+	}
+	if nil != result.Err {
+		p.inc("didagle_vertex_errors_total", metricLabels("cluster", info.Cluster, "graph", info.Graph, "vertex", info.Vertex, "processor", info.Processor))
+	}
+	if len(info.Cond) > 0 {
+		p.observe("didagle_cond_duration_seconds", metricLabels("cluster", info.Cluster, "graph", info.Graph), elapsed)
+	} else if len(info.Processor) > 0 {
+		p.observe("didagle_processor_duration_seconds", metricLabels("cluster", info.Cluster, "processor", info.Processor), elapsed)
+	}
+}
+
+// Counter returns the value of the counter with labels in the form of 'k1="v1",k2="v2"'.
+func (p *Metrics) Counter(name string, labels string) uint64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.counters[metricKey{name: name, labels: labels}]
+}
+
+// HistogramCount returns the observation count of the histogram with labels in the form of 'k1="v1",k2="v2"'.
+func (p *Metrics) HistogramCount(name string, labels string) uint64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if h, exist := p.histograms[metricKey{name: name, labels: labels}]; exist {
+		return h.count
+	}
+	return 0
+}
+
+func sortMetricKeys(keys []metricKey) []metricKey {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].labels < keys[j].labels
+	})
+	return keys
+}
+
+// WritePrometheus writes all metrics in the prometheus text exposition format.
+func (p *Metrics) WritePrometheus(w io.Writer) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var b strings.Builder
+	lastName := ""
+	var keys []metricKey
+	for key := range p.histograms {
+		keys = append(keys, key)
+	}
+	for _, key := range sortMetricKeys(keys) {
+		if key.name != lastName {
+			b.WriteString(fmt.Sprintf("# TYPE %s histogram\n", key.name))
+			lastName = key.name
+		}
+		h := p.histograms[key]
+		for i, bound := range p.buckets {
+			b.WriteString(fmt.Sprintf("%s_bucket{%s,le=\"%g\"} %d\n", key.name, key.labels, bound, h.counts[i]))
+		}
+		b.WriteString(fmt.Sprintf("%s_bucket{%s,le=\"+Inf\"} %d\n", key.name, key.labels, h.count))
+		b.WriteString(fmt.Sprintf("%s_sum{%s} %g\n", key.name, key.labels, h.sum))
+		b.WriteString(fmt.Sprintf("%s_count{%s} %d\n", key.name, key.labels, h.count))
+	}
+	keys = keys[:0]
+	for key := range p.counters {
+		keys = append(keys, key)
+	}
+	for _, key := range sortMetricKeys(keys) {
+		if key.name != lastName {
+			b.WriteString(fmt.Sprintf("# TYPE %s counter\n", key.name))
+			lastName = key.name
+		}
+		b.WriteString(fmt.Sprintf("%s{%s} %d\n", key.name, key.labels, p.counters[key]))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,217 @@
+package didagle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type testNopOp struct{}
+
+func (p *testNopOp) Execute(ctx context.Context, args map[string]interface{}) error {
+	return nil
+}
+
+type testFailOp struct{}
+
+func (p *testFailOp) Execute(ctx context.Context, args map[string]interface{}) error {
+	return fmt.Errorf("fail")
+}
+
+const testSubGraphScript = `
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "call"
+graph = "sub"
+successor = ["after"]
+[[graph.vertex]]
+id = "after"
+processor = "nop"
+[[graph]]
+name = "sub"
+[[graph.vertex]]
+id = "a"
+processor = "nop"
+successor = ["b"]
+[[graph.vertex]]
+id = "b"
+processor = "fail"
+`
+
+func newTestRegistry(t *testing.T) *OperatorRegistry {
+	t.Helper()
+	registry := NewOperatorRegistry()
+	if err := registry.Register("nop", &testNopOp{}); nil != err {
+		t.Fatal(err)
+	}
+	if err := registry.Register("fail", &testFailOp{}); nil != err {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func spanAttr(span tracetest.SpanStub, key string) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == attribute.Key(key) {
+			return kv.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func TestTracingNestedSubGraphSpans(t *testing.T) {
+	cfg := mustBuild(t, "", testSubGraphScript)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+	executor := NewExecutor(cfg, &ExecutorOptions{
+		Registry:        newTestRegistry(t),
+		Instrumentation: NewTracing(provider.Tracer(TRACER_NAME)),
+	})
+	if _, err := executor.Execute(context.Background(), "main", nil); nil != err {
+		t.Fatal(err)
+	}
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	// span name -> parent span name
+	parents := map[string]string{
+		"DefaultCluster::main": "",
+		"main_call":            "DefaultCluster::main",
+		"main_after":           "DefaultCluster::main",
+		"DefaultCluster::sub":  "main_call",
+		"sub_a":                "DefaultCluster::sub",
+		"sub_b":                "DefaultCluster::sub",
+	}
+	if len(spans) != len(parents) {
+		t.Fatalf("Expect %d spans, but got %v", len(parents), spans)
+	}
+	root := spans["DefaultCluster::main"]
+	for name, parent := range parents {
+		span, exist := spans[name]
+		if !exist {
+			t.Errorf("No span:%s", name)
+			continue
+		}
+		if span.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("Span:%s is not in the trace of the root span", name)
+		}
+		if len(parent) == 0 {
+			if span.Parent.IsValid() {
+				t.Errorf("Expect root span:%s", name)
+			}
+			continue
+		}
+		if span.Parent.SpanID() != spans[parent].SpanContext.SpanID() {
+			t.Errorf("Expect span:%s child of:%s", name, parent)
+		}
+	}
+	if spanAttr(spans["DefaultCluster::sub"], "didagle.path") != "main/call/sub" {
+		t.Errorf("Unexpected sub graph path:%s", spanAttr(spans["DefaultCluster::sub"], "didagle.path"))
+	}
+	for _, name := range []string{"sub_b", "DefaultCluster::sub", "main_call", "DefaultCluster::main"} {
+		if spans[name].Status.Code != codes.Error {
+			t.Errorf("Expect error status of span:%s, but got %v", name, spans[name].Status)
+		}
+	}
+	if spans["sub_a"].Status.Code == codes.Error || spanAttr(spans["sub_a"], "didagle.result") != "ok" {
+		t.Errorf("Unexpected status of span:sub_a:%v", spans["sub_a"].Status)
+	}
+	if spanAttr(spans["sub_b"], "didagle.processor") != "fail" || spanAttr(spans["sub_b"], "didagle.result") != "err" {
+		t.Errorf("Unexpected attributes of span:sub_b:%v", spans["sub_b"].Attributes)
+	}
+	if len(spans["sub_b"].Events) == 0 || spans["sub_b"].Events[0].Name != "exception" {
+		t.Errorf("Expect recorded error of span:sub_b")
+	}
+}
+
+func TestMetricsSubGraph(t *testing.T) {
+	cfg := mustBuild(t, "", testSubGraphScript)
+	metrics := NewMetrics(nil)
+	executor := NewExecutor(cfg, &ExecutorOptions{Registry: newTestRegistry(t), Instrumentation: metrics})
+	for i := 0; i < 2; i++ {
+		if _, err := executor.Execute(context.Background(), "main", nil); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if n := metrics.Counter("didagle_vertex_errors_total", `cluster="DefaultCluster",graph="sub",vertex="b",processor="fail"`); n != 2 {
+		t.Errorf("Expect 2 errors of sub/b, but got %d", n)
+	}
+	if n := metrics.HistogramCount("didagle_graph_duration_seconds", `cluster="DefaultCluster",graph="sub"`); n != 2 {
+		t.Errorf("Expect 2 sub graph observations, but got %d", n)
+	}
+	var b strings.Builder
+	if err := metrics.WritePrometheus(&b); nil != err || !strings.Contains(b.String(), "# TYPE didagle_processor_duration_seconds histogram") {
+		t.Errorf("Unexpected prometheus output:%s, err:%v", b.String(), err)
+	}
+}
+
+func TestMetricsCondVertex(t *testing.T) {
+	cfg := mustBuild(t, "", `
+default_expr_processor = "expr"
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "a"
+processor = "nop"
+expect = "x > 1"
+`)
+	metrics := NewMetrics(nil)
+	executor := NewExecutor(cfg, &ExecutorOptions{
+		Registry:        newTestRegistry(t),
+		Instrumentation: metrics,
+		EvalCond: func(ctx context.Context, expr string, data map[string]interface{}) (bool, error) {
+			return true, nil
+		},
+	})
+	if _, err := executor.Execute(context.Background(), "main", nil); nil != err {
+		t.Fatal(err)
+	}
+	if n := metrics.HistogramCount("didagle_processor_duration_seconds", `cluster="DefaultCluster",processor="expr"`); n != 0 {
+		t.Errorf("Expect no processor observation of cond vertex, but got %d", n)
+	}
+	if n := metrics.HistogramCount("didagle_cond_duration_seconds", `cluster="DefaultCluster",graph="main"`); n != 1 {
+		t.Errorf("Expect 1 cond observation, but got %d", n)
+	}
+	if n := metrics.HistogramCount("didagle_processor_duration_seconds", `cluster="DefaultCluster",processor="nop"`); n != 1 {
+		t.Errorf("Expect 1 nop observation, but got %d", n)
+	}
+}
+
+func TestMultiInstrumentationTracing(t *testing.T) {
+	cfg := mustBuild(t, "", testSubGraphScript)
+	var exporters []*tracetest.InMemoryExporter
+	var instruments []Instrumentation
+	for i := 0; i < 2; i++ {
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		defer provider.Shutdown(context.Background())
+		exporters = append(exporters, exporter)
+		instruments = append(instruments, NewTracing(provider.Tracer(TRACER_NAME)))
+	}
+	executor := NewExecutor(cfg, &ExecutorOptions{
+		Registry:        newTestRegistry(t),
+		Instrumentation: MultiInstrumentation(instruments...),
+	})
+	if _, err := executor.Execute(context.Background(), "main", nil); nil != err {
+		t.Fatal(err)
+	}
+	// each tracing hook ends its own spans: 2 graph spans & 4 vertex spans
+	for i, exporter := range exporters {
+		names := make(map[string]bool)
+		for _, span := range exporter.GetSpans() {
+			names[span.Name] = true
+		}
+		if len(exporter.GetSpans()) != 6 || len(names) != 6 {
+			t.Errorf("Expect 6 ended spans of tracing:%d, but got %v", i, names)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package didagle
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LintIssue is a suspicious but valid construct found in a built graph cluster.
+type LintIssue struct {
+	Rule    string `json:"rule"`
+	Graph   string `json:"graph,omitempty"`
+	Vertex  string `json:"vertex,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p *Graph) lint(issues []LintIssue) []LintIssue {
+	consumed := make(map[string]bool)
+	for _, v := range p.vertexMap {
+		for _, input := range v.Input {
+			consumed[input.ID] = true
+			for _, id := range input.Aggregate {
+				consumed[id] = true
+			}
+		}
+	}
+	for _, v := range p.sortedVertexs() {
+		if v.isGenerated {
+			continue
+		}
+		if len(v.Cond) > 0 && v.isSuccessorsEmpty() {
+			issues = append(issues, LintIssue{
+				Rule:    "cond-without-branch",
+				Graph:   p.Name,
+				Vertex:  v.ID,
+				Message: fmt.Sprintf("cond vertex '%s' has no 'if'/'else'/'successor' vertex", v.getDotLabel()),
+			})
+		}
+		if len(v.Graph) > 0 && v.Cluster == p.cluster.name && nil == p.cluster.getGraphByName(v.Graph) {
+			issues = append(issues, LintIssue{
+				Rule:    "missing-sub-graph",
+				Graph:   p.Name,
+				Vertex:  v.ID,
+				Message: fmt.Sprintf("sub graph '%s' not found in cluster '%s'", v.Graph, v.Cluster),
+			})
+		}
+		for _, output := range v.Output {
+			if !consumed[output.ID] && p.dataMapping[output.ID] == v {
+				issues = append(issues, LintIssue{
+					Rule:    "unused-output",
+					Graph:   p.Name,
+					Vertex:  v.ID,
+					Message: fmt.Sprintf("output data '%s' is not consumed by any vertex", output.ID),
+				})
+			}
+		}
+		for _, id := range p.redundantDeps(v) {
+			issues = append(issues, LintIssue{
+				Rule:    "redundant-dep",
+				Graph:   p.Name,
+				Vertex:  v.ID,
+				Message: fmt.Sprintf("dependency on '%s' is already implied by another dependency", id),
+			})
+		}
+	}
+	return issues
+}
+
+// redundantDeps returns 'all' dependencies which are also transitive dependencies of another dependency.
+func (p *Graph) redundantDeps(v *Vertex) []string {
+	var redundant []string
+	for id, expect := range v.depsResults {
+		if expect != V_RESULT_ALL {
+			continue
+		}
+		for other := range v.depsResults {
+			if other == id {
+				continue
+			}
+			upstream := make(map[string]bool)
+			p.collectUpstream(p.getVertexById(other), upstream)
+			if upstream[id] {
+				redundant = append(redundant, id)
+				break
+			}
+		}
+	}
+	sort.Strings(redundant)
+	return redundant
+}
+
+// Lint returns suspicious constructs in the cluster which are not build errors.
+func (p *DAGConfig) Lint() []LintIssue {
+	var issues []LintIssue
+	for i := range p.graph.Graph {
+		issues = p.graph.Graph[i].lint(issues)
+	}
+	used := make(map[string]bool)
+	for i := range p.graph.Graph {
+		for _, v := range p.graph.Graph[i].vertexMap {
+			if len(v.ExpectConfig) > 0 {
+				if v.ExpectConfig[0] == '!' {
+					used[v.ExpectConfig[1:]] = true
+				} else {
+					used[v.ExpectConfig] = true
+				}
+			}
+			for _, cond := range v.SelectArgs {
+				used[cond.Match] = true
+			}
+		}
+	}
+	for _, c := range p.graph.ConfigSetting {
+		if !used[c.Name] {
+			issues = append(issues, LintIssue{
+				Rule:    "unused-config-setting",
+				Message: fmt.Sprintf("config_setting '%s' is not used by any vertex", c.Name),
+			})
+		}
+	}
+	return issues
+}
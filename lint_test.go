@@ -0,0 +1,112 @@
+package didagle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []LintIssue
+	}{
+		{"clean", `
+[[config_setting]]
+name = "on"
+cond = "x==1"
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "a"
+processor = "p"
+output = [{ field = "x" }]
+[[graph.vertex]]
+id = "b"
+processor = "p"
+input = [{ field = "x" }]
+expect_config = "on"
+`, nil},
+		{"cond-without-branch", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "a"
+processor = "p"
+[[graph.vertex]]
+id = "check"
+cond = "x > 1"
+deps = ["a"]
+`, []LintIssue{{"cond-without-branch", "g", "check", "cond vertex 'x > 1' has no 'if'/'else'/'successor' vertex"}}},
+		{"missing-sub-graph", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "a"
+processor = "p"
+successor = ["call"]
+[[graph.vertex]]
+id = "call"
+graph = "nope"
+`, []LintIssue{{"missing-sub-graph", "g", "call", "sub graph 'nope' not found in cluster 'DefaultCluster'"}}},
+		{"unused-output", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "a"
+processor = "p"
+output = [{ field = "x" }, { field = "y" }]
+[[graph.vertex]]
+id = "b"
+processor = "p"
+input = [{ field = "x" }]
+`, []LintIssue{{"unused-output", "g", "a", "output data 'y' is not consumed by any vertex"}}},
+		{"redundant-dep", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "a"
+processor = "p"
+[[graph.vertex]]
+id = "b"
+processor = "p"
+deps = ["a"]
+[[graph.vertex]]
+id = "c"
+processor = "p"
+deps = ["a", "b"]
+[[graph.vertex]]
+id = "d"
+processor = "p"
+deps_on_ok = ["a"]
+deps = ["b"]
+`, []LintIssue{{"redundant-dep", "g", "c", "dependency on 'a' is already implied by another dependency"}}},
+		{"unused-config-setting", `
+[[config_setting]]
+name = "off"
+cond = "x==0"
+[[config_setting]]
+name = "on"
+cond = "x==1"
+[[config_setting]]
+name = "args"
+cond = "x==2"
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "a"
+processor = "p"
+expect_config = "!on"
+successor = ["b"]
+[[graph.vertex]]
+id = "b"
+processor = "p"
+select_args = [{ match = "args", args = { a = 1 } }]
+`, []LintIssue{{"unused-config-setting", "", "", "config_setting 'off' is not used by any vertex"}}},
+	}
+	for _, test := range tests {
+		if got := mustBuild(t, "", test.script).Lint(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expect issues:%v, but got %v", test.name, test.want, got)
+		}
+	}
+}
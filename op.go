@@ -1,5 +1,18 @@
 package didagle
 
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 type FieldFlags struct {
 	Extern    int `json:"is_extern"`
 	InOut     int `json:"is_in_out"`
@@ -14,7 +27,264 @@ type FieldMeta struct {
 }
 
 type OperatorMeta struct {
-	Name   string      `json:"name"`
-	Input  []FieldMeta `json:"input"`
-	Output []FieldMeta `json:"output"`
+	Name string `json:"name"`
+	// Version is optional, a versioned operator is referred as 'name@version' in scripts,
+	// while 'name' refers the unversioned one or the latest version.
+	Version string      `json:"version,omitempty"`
+	Input   []FieldMeta `json:"input"`
+	Output  []FieldMeta `json:"output"`
+}
+
+const OP_VERSION_SEPARATOR = "@"
+
+// OpMetaJSONSchema is the json schema of op meta files.
+//
+//go:embed op_meta.schema.json
+var OpMetaJSONSchema []byte
+
+func verifyFlag(op string, field string, flag string, v int) error {
+	if v != 0 && v != 1 {
+		return fmt.Errorf("Operator:%s field:%s flag '%s' must be 0 or 1, but got %d", op, field, flag, v)
+	}
+	return nil
+}
+
+func (p *OperatorMeta) verifyFields(kind string, fields []FieldMeta, types map[int64]string) error {
+	names := make(map[string]bool)
+	for _, field := range fields {
+		if len(field.Name) == 0 {
+			return fmt.Errorf("Operator:%s has %s field with empty name", p.FullName(), kind)
+		}
+		if names[field.Name] {
+			return fmt.Errorf("Operator:%s has duplicate %s field:%s", p.FullName(), kind, field.Name)
+		}
+		names[field.Name] = true
+		if err := verifyFlag(p.FullName(), field.Name, "is_extern", field.Flags.Extern); nil != err {
+			return err
+		}
+		if err := verifyFlag(p.FullName(), field.Name, "is_in_out", field.Flags.InOut); nil != err {
+			return err
+		}
+		if err := verifyFlag(p.FullName(), field.Name, "is_aggregate", field.Flags.Agrregate); nil != err {
+			return err
+		}
+		if field.ID == 0 || len(field.Type) == 0 {
+			continue
+		}
+		if typ, exist := types[field.ID]; exist && typ != field.Type {
+			return fmt.Errorf("Operator:%s field:%s has id:%d with type '%s', but the id is used by type '%s'", p.FullName(), field.Name, field.ID, field.Type, typ)
+		}
+		types[field.ID] = field.Type
+	}
+	return nil
+}
+
+// verifyOpMetas checks operator names, flags and duplicate operators/fields/field ids in op metas of one source.
+func verifyOpMetas(metas []OperatorMeta) error {
+	ops := make(map[string]bool)
+	types := make(map[int64]string)
+	for i := range metas {
+		op := &metas[i]
+		if len(op.Name) == 0 {
+			return fmt.Errorf("Operator at index:%d has empty name", i)
+		}
+		if strings.Contains(op.Name, OP_VERSION_SEPARATOR) || strings.Contains(op.Version, OP_VERSION_SEPARATOR) {
+			return fmt.Errorf("Operator:%s name or version can NOT contain '%s'", op.FullName(), OP_VERSION_SEPARATOR)
+		}
+		if ops[op.FullName()] {
+			return fmt.Errorf("Duplicate operator:%s", op.FullName())
+		}
+		ops[op.FullName()] = true
+		if err := op.verifyFields("input", op.Input, types); nil != err {
+			return err
+		}
+		if err := op.verifyFields("output", op.Output, types); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeOpMeta strictly decodes op meta json: unknown keys are rejected and
+// operators are verified by verifyOpMetas, errors name the operator.
+func DecodeOpMeta(r io.Reader) ([]OperatorMeta, error) {
+	var raws []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raws); nil != err {
+		return nil, err
+	}
+	metas := make([]OperatorMeta, 0, len(raws))
+	for i, raw := range raws {
+		var named struct {
+			Name string `json:"name"`
+		}
+		json.Unmarshal(raw, &named)
+		decoder := json.NewDecoder(strings.NewReader(string(raw)))
+		decoder.DisallowUnknownFields()
+		var meta OperatorMeta
+		if err := decoder.Decode(&meta); nil != err {
+			return nil, fmt.Errorf("Operator:%s at index:%d is invalid:%v", named.Name, i, err)
+		}
+		metas = append(metas, meta)
+	}
+	if err := verifyOpMetas(metas); nil != err {
+		return nil, err
+	}
+	return metas, nil
+}
+
+// FullName returns 'name@version' for versioned operator, else the name.
+func (p *OperatorMeta) FullName() string {
+	if len(p.Version) == 0 {
+		return p.Name
+	}
+	return p.Name + OP_VERSION_SEPARATOR + p.Version
+}
+
+func sortedFields(fields []FieldMeta) []FieldMeta {
+	sorted := append([]FieldMeta{}, fields...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// sameFields compares the fields by name, types & ids are skipped if untyped since registered operators
+// derive them from go types.
+func (p *OperatorMeta) sameFields(other *OperatorMeta, untyped bool) bool {
+	normalize := func(fields []FieldMeta) []FieldMeta {
+		sorted := sortedFields(fields)
+		if untyped {
+			for i := range sorted {
+				sorted[i].ID, sorted[i].Type = 0, ""
+			}
+		}
+		return sorted
+	}
+	return reflect.DeepEqual(normalize(p.Input), normalize(other.Input)) &&
+		reflect.DeepEqual(normalize(p.Output), normalize(other.Output))
+}
+
+// compareOpVersion compares dot separated versions like 'v1.2', numeric parts are compared as numbers.
+func compareOpVersion(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		if nil == aerr && nil == berr {
+			if an != bn {
+				return an - bn
+			}
+			continue
+		}
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// OpMetaProvider provides operator metas from a source like json files, a go registry or generated code.
+type OpMetaProvider interface {
+	// Source names the provider in conflict errors.
+	Source() string
+	OpMetas() ([]OperatorMeta, error)
+}
+
+// OpMetaList is a static list of operator metas, e.g. from a generated go file.
+type OpMetaList []OperatorMeta
+
+func (p OpMetaList) Source() string {
+	return "list"
+}
+
+func (p OpMetaList) OpMetas() ([]OperatorMeta, error) {
+	return p, nil
+}
+
+// OpMetaFiles loads operator metas from json files, a directory refers all '*.json' files in it.
+type OpMetaFiles []string
+
+func (p OpMetaFiles) Source() string {
+	return strings.Join(p, ",")
+}
+
+func (p OpMetaFiles) files() ([]string, error) {
+	var files []string
+	for _, path := range p {
+		info, err := os.Stat(path)
+		if nil != err {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if nil != err {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func (p OpMetaFiles) OpMetas() ([]OperatorMeta, error) {
+	files, err := p.files()
+	if nil != err {
+		return nil, err
+	}
+	var providers []OpMetaProvider
+	for _, file := range files {
+		providers = append(providers, opMetaFile(file))
+	}
+	return MergeOpMetas(providers...)
+}
+
+type opMetaFile string
+
+func (p opMetaFile) Source() string {
+	return string(p)
+}
+
+func (p opMetaFile) OpMetas() ([]OperatorMeta, error) {
+	return loadOpMetaFile(string(p))
+}
+
+// MergeOpMetas merges operator metas from providers, the same operator(name & version) defined with
+// different input/output fields is reported as conflict. The meta of an operator registered by an
+// OperatorRegistry wins over other sources in any order, only field names & flags are compared with it
+// since its field types & ids are derived from go types.
+func MergeOpMetas(providers ...OpMetaProvider) ([]OperatorMeta, error) {
+	var merged []OperatorMeta
+	sources := make(map[string]string)
+	indexes := make(map[string]int)
+	registered := make(map[string]bool)
+	for _, provider := range providers {
+		metas, err := provider.OpMetas()
+		if nil != err {
+			return nil, err
+		}
+		_, isRegistry := provider.(*OperatorRegistry)
+		for _, meta := range metas {
+			name := meta.FullName()
+			if idx, exist := indexes[name]; exist {
+				if !merged[idx].sameFields(&meta, isRegistry || registered[name]) {
+					return nil, fmt.Errorf("Conflict operator:%s defined in %s and %s with different input/output", name, sources[name], provider.Source())
+				}
+				if isRegistry && !registered[name] {
+					merged[idx] = meta
+					sources[name] = provider.Source()
+					registered[name] = true
+				}
+				continue
+			}
+			indexes[name] = len(merged)
+			sources[name] = provider.Source()
+			registered[name] = isRegistry
+			merged = append(merged, meta)
+		}
+	}
+	return merged, nil
 }
@@ -0,0 +1,70 @@
+package didagle
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeOpMeta(t *testing.T) {
+	metas, err := DecodeOpMeta(strings.NewReader(`[
+{"name":"recall","version":"v1","input":[{"name":"query","id":1,"type":"string","flags":{"is_extern":1}}],"output":[{"name":"items","id":2,"type":"[]string"}]},
+{"name":"recall","input":[{"name":"query","id":1,"type":"string"}],"output":[]}
+]`))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(metas) != 2 || metas[0].FullName() != "recall@v1" || metas[1].FullName() != "recall" {
+		t.Fatalf("Unexpected metas:%+v", metas)
+	}
+	if metas[0].Input[0].Flags.Extern != 1 {
+		t.Errorf("Unexpected flags:%+v", metas[0].Input[0].Flags)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(OpMetaJSONSchema, &schema); nil != err {
+		t.Errorf("Invalid op meta json schema:%v", err)
+	}
+}
+
+func TestDecodeOpMetaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		metas  string
+		errMsg string
+	}{
+		{"operator key", `[{"name":"a","input":[],"output":[],"inputs":[]}]`,
+			`Operator:a at index:0 is invalid:json: unknown field "inputs"`},
+		{"field key", `[{"name":"a","input":[{"name":"x","typ":"int"}],"output":[]}]`,
+			`Operator:a at index:0 is invalid:json: unknown field "typ"`},
+		{"flag key", `[{"name":"a","input":[],"output":[]},{"name":"b","input":[{"name":"x","flags":{"is_optional":1}}],"output":[]}]`,
+			`Operator:b at index:1 is invalid:json: unknown field "is_optional"`},
+		{"flag value", `[{"name":"a","input":[{"name":"x","flags":{"is_aggregate":2}}],"output":[]}]`,
+			"Operator:a field:x flag 'is_aggregate' must be 0 or 1, but got 2"},
+		{"negative flag", `[{"name":"a","input":[],"output":[{"name":"y","flags":{"is_in_out":-1}}]}]`,
+			"Operator:a field:y flag 'is_in_out' must be 0 or 1, but got -1"},
+		{"empty name", `[{"name":"a","input":[],"output":[]},{"name":"","input":[],"output":[]}]`,
+			"Operator at index:1 has empty name"},
+		{"separator", `[{"name":"a@v1","input":[],"output":[]}]`,
+			"Operator:a@v1 name or version can NOT contain '@'"},
+		{"duplicate operator", `[{"name":"a","version":"v1","input":[],"output":[]},{"name":"a","version":"v1","input":[],"output":[]}]`,
+			"Duplicate operator:a@v1"},
+		{"empty field", `[{"name":"a","input":[{"name":""}],"output":[]}]`,
+			"Operator:a has input field with empty name"},
+		{"duplicate field", `[{"name":"a","input":[],"output":[{"name":"x"},{"name":"x"}]}]`,
+			"Operator:a has duplicate output field:x"},
+		{"field id", `[{"name":"a","input":[{"name":"x","id":7,"type":"int"}],"output":[]},{"name":"b","input":[],"output":[{"name":"y","id":7,"type":"string"}]}]`,
+			"Operator:b field:y has id:7 with type 'string', but the id is used by type 'int'"},
+		{"not array", `{"name":"a"}`, "cannot unmarshal object"},
+	}
+	for _, test := range tests {
+		_, err := DecodeOpMeta(strings.NewReader(test.metas))
+		if nil == err || !strings.Contains(err.Error(), test.errMsg) {
+			t.Errorf("%s: expect err:%s, but got:%v", test.name, test.errMsg, err)
+		}
+	}
+	// same field ids with the same type, and versions of the same operator are valid
+	if _, err := DecodeOpMeta(strings.NewReader(`[{"name":"a","input":[{"name":"x","id":7,"type":"int"}],"output":[]},
+{"name":"a","version":"v2","input":[],"output":[{"name":"x","id":7,"type":"int"}]}]`)); nil != err {
+		t.Errorf("Unexpected err:%v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package didagle
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TRACER_NAME is the instrumentation scope of spans created by Tracing.
+const TRACER_NAME = "github.com/yinqiwen/go-didagle"
+
+// Tracing is an Instrumentation creating OpenTelemetry spans, graph spans are parents of their vertex spans,
+// and sub graph spans are children of the calling vertex span. Operators get the vertex span in the context.
+type Tracing struct {
+	tracer trace.Tracer
+}
+
+// NewTracing creates spans by the tracer, or by the tracer of the global provider if nil.
+func NewTracing(tracer trace.Tracer) *Tracing {
+	if nil == tracer {
+		tracer = otel.Tracer(TRACER_NAME)
+	}
+	return &Tracing{tracer: tracer}
+}
+
+func (p *Tracing) StartGraph(ctx context.Context, info *GraphInfo) context.Context {
+	ctx, _ = p.tracer.Start(ctx, info.Cluster+"::"+info.Graph, trace.WithAttributes(
+		attribute.String("didagle.cluster", info.Cluster),
+		attribute.String("didagle.graph", info.Graph),
+		attribute.String("didagle.path", info.Path),
+	))
+	return ctx
+}
+
+func (p *Tracing) EndGraph(ctx context.Context, info *GraphInfo, result int, elapsed time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	if result != V_RESULT_OK {
+		span.SetStatus(codes.Error, "vertex failed")
+	}
+	span.End()
+}
+
+func (p *Tracing) StartVertex(ctx context.Context, info *VertexInfo) context.Context {
+	ctx, _ = p.tracer.Start(ctx, info.DotId, trace.WithAttributes(
+		attribute.String("didagle.cluster", info.Cluster),
+		attribute.String("didagle.graph", info.Graph),
+		attribute.String("didagle.path", info.Path),
+		attribute.String("didagle.vertex", info.Vertex),
+		attribute.String("didagle.processor", info.Processor),
+	))
+	return ctx
+}
+
+func (p *Tracing) EndVertex(ctx context.Context, info *VertexInfo, result *VertexResult, elapsed time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	// ok/err/skipped/cancelled
+	span.SetAttributes(attribute.String("didagle.result", resultName(result)))
+	if nil != result.Err {
+		span.RecordError(result.Err)
+		span.SetStatus(codes.Error, result.Err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,57 @@
+package didagle
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GraphPlan is the topological execution order of a graph, vertices in the same stage have no dependency between each other.
+type GraphPlan struct {
+	Graph  string     `json:"graph"`
+	Stages [][]string `json:"stages"`
+}
+
+func (p *Graph) plan() (*GraphPlan, error) {
+	plan := &GraphPlan{Graph: p.Name}
+	pending := make(map[string]int)
+	var ready []string
+	for _, v := range p.vertexMap {
+		pending[v.ID] = len(v.depsResults)
+		if len(v.depsResults) == 0 {
+			ready = append(ready, v.ID)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		plan.Stages = append(plan.Stages, ready)
+		visited += len(ready)
+		var next []string
+		for _, id := range ready {
+			for _, successor := range p.vertexMap[id].successorVertex {
+				pending[successor.ID]--
+				if pending[successor.ID] == 0 {
+					next = append(next, successor.ID)
+				}
+			}
+		}
+		ready = next
+	}
+	if visited != len(p.vertexMap) {
+		return nil, fmt.Errorf("Graph:%s has a circle, only %d/%d vertex planned", p.Name, visited, len(p.vertexMap))
+	}
+	return plan, nil
+}
+
+// Plan returns the topological execution order of every graph in the cluster.
+func (p *DAGConfig) Plan() ([]*GraphPlan, error) {
+	var plans []*GraphPlan
+	for i := range p.graph.Graph {
+		plan, err := p.graph.Graph[i].plan()
+		if nil != err {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
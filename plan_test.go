@@ -0,0 +1,75 @@
+package didagle
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	cfg := mustBuild(t, "", `
+[[graph]]
+name = "diamond"
+[[graph.vertex]]
+id = "a"
+processor = "p"
+output = [{ field = "x" }]
+successor = ["c", "b"]
+[[graph.vertex]]
+id = "b"
+processor = "p"
+input = [{ field = "x" }]
+[[graph.vertex]]
+id = "c"
+processor = "p"
+successor = ["d"]
+[[graph.vertex]]
+id = "d"
+processor = "p"
+deps_on_ok = ["b"]
+[[graph]]
+name = "forest"
+[[graph.vertex]]
+id = "z"
+processor = "p"
+successor = ["y"]
+[[graph.vertex]]
+id = "y"
+processor = "p"
+[[graph.vertex]]
+id = "x"
+processor = "p"
+if = ["w"]
+[[graph.vertex]]
+id = "w"
+processor = "p"
+`)
+	plans, err := cfg.Plan()
+	if nil != err {
+		t.Fatal(err)
+	}
+	want := []*GraphPlan{
+		{Graph: "diamond", Stages: [][]string{{"a"}, {"b", "c"}, {"d"}}},
+		{Graph: "forest", Stages: [][]string{{"x", "z"}, {"w", "y"}}},
+	}
+	if !reflect.DeepEqual(plans, want) {
+		t.Errorf("Unexpected plans:%+v %+v", plans[0], plans[1])
+	}
+
+	// circles never reach the plan
+	_, err = NewDAGConfigByContent("", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "a"
+processor = "p"
+successor = ["b"]
+[[graph.vertex]]
+id = "b"
+processor = "p"
+successor = ["a"]
+`)
+	if nil == err || !strings.Contains(err.Error(), "Circle Exist") {
+		t.Errorf("Expect circle error, but got:%v", err)
+	}
+}
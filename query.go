@@ -0,0 +1,291 @@
+package didagle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MAX_QUERY_PATHS limits the paths returned by a 'paths' query per graph, the count grows exponentially
+// with the diamonds between the vertices.
+const MAX_QUERY_PATHS = 1000
+
+// QueryResult is a vertex matched by a query.
+type QueryResult struct {
+	Cluster string `json:"cluster"`
+	Graph   string `json:"graph"`
+	Vertex  string `json:"vertex"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+func (p QueryResult) String() string {
+	if len(p.Detail) == 0 {
+		return fmt.Sprintf("%s/%s/%s", p.Cluster, p.Graph, p.Vertex)
+	}
+	return fmt.Sprintf("%s/%s/%s %s", p.Cluster, p.Graph, p.Vertex, p.Detail)
+}
+
+func (p *DAGConfig) newQueryResult(g *Graph, v *Vertex, detail string) QueryResult {
+	return QueryResult{Cluster: p.graph.name, Graph: g.Name, Vertex: v.ID, Detail: detail}
+}
+
+// QueryConsumers returns vertices consuming the data.
+func (p *DAGConfig) QueryConsumers(data string) []QueryResult {
+	var rs []QueryResult
+	for i := range p.graph.Graph {
+		g := &p.graph.Graph[i]
+		consumers := g.getConsumersByData(data)
+		sort.Slice(consumers, func(i, j int) bool {
+			return consumers[i].ID < consumers[j].ID
+		})
+		for _, v := range consumers {
+			rs = append(rs, p.newQueryResult(g, v, ""))
+		}
+	}
+	return rs
+}
+
+// QueryProducers returns vertices producing the data.
+func (p *DAGConfig) QueryProducers(data string) []QueryResult {
+	var rs []QueryResult
+	for i := range p.graph.Graph {
+		g := &p.graph.Graph[i]
+		if v := g.getVertexByData(data); nil != v {
+			rs = append(rs, p.newQueryResult(g, v, ""))
+		}
+	}
+	return rs
+}
+
+// QueryProcessor returns vertices using the processor.
+func (p *DAGConfig) QueryProcessor(processor string) []QueryResult {
+	var rs []QueryResult
+	for i := range p.graph.Graph {
+		g := &p.graph.Graph[i]
+		for _, v := range g.sortedVertexs() {
+			if v.Processor == processor {
+				rs = append(rs, p.newQueryResult(g, v, ""))
+			}
+		}
+	}
+	return rs
+}
+
+// QueryDeps returns vertices the vertex transitively depends on.
+func (p *DAGConfig) QueryDeps(vertex string) []QueryResult {
+	var rs []QueryResult
+	for i := range p.graph.Graph {
+		g := &p.graph.Graph[i]
+		v := g.getVertexById(vertex)
+		if nil == v {
+			continue
+		}
+		upstream := make(map[string]bool)
+		g.collectUpstream(v, upstream)
+		for _, dep := range g.sortedVertexs() {
+			if dep == v || !upstream[dep.ID] {
+				continue
+			}
+			detail := ""
+			if expect, direct := v.depsResults[dep.ID]; direct {
+				detail = "direct " + expectString(expect)
+			}
+			rs = append(rs, p.newQueryResult(g, dep, detail))
+		}
+	}
+	return rs
+}
+
+// QueryDependents returns vertices transitively depending on the vertex.
+func (p *DAGConfig) QueryDependents(vertex string) []QueryResult {
+	var rs []QueryResult
+	for i := range p.graph.Graph {
+		g := &p.graph.Graph[i]
+		v := g.getVertexById(vertex)
+		if nil == v {
+			continue
+		}
+		downstream := make(map[string]bool)
+		g.collectDownstream(v, downstream)
+		for _, successor := range g.sortedVertexs() {
+			if successor == v || !downstream[successor.ID] {
+				continue
+			}
+			detail := ""
+			if expect, direct := successor.depsResults[v.ID]; direct {
+				detail = "direct " + expectString(expect)
+			}
+			rs = append(rs, p.newQueryResult(g, successor, detail))
+		}
+	}
+	return rs
+}
+
+// QueryPaths returns all paths from the vertex to the other, the path is in Detail like 'a -ok-> b -> c'.
+func (p *DAGConfig) QueryPaths(from string, to string) []QueryResult {
+	var rs []QueryResult
+	for i := range p.graph.Graph {
+		g := &p.graph.Graph[i]
+		src := g.getVertexById(from)
+		dst := g.getVertexById(to)
+		if nil == src || nil == dst {
+			continue
+		}
+		for _, path := range g.collectPaths(src, dst) {
+			rs = append(rs, p.newQueryResult(g, src, path))
+		}
+	}
+	return rs
+}
+
+// collectPaths walks successors upstream of the target only, so every branch of the walk reaches the target.
+func (p *Graph) collectPaths(from *Vertex, to *Vertex) []string {
+	upstream := make(map[string]bool)
+	p.collectUpstream(to, upstream)
+	if !upstream[from.ID] {
+		return nil
+	}
+	var paths []string
+	onPath := make(map[string]bool)
+	var walk func(v *Vertex, path string)
+	walk = func(v *Vertex, path string) {
+		if len(paths) >= MAX_QUERY_PATHS {
+			return
+		}
+		if v == to {
+			paths = append(paths, path)
+			return
+		}
+		onPath[v.ID] = true
+		ids := make([]string, 0, len(v.successorVertex))
+		for id := range v.successorVertex {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			successor := v.successorVertex[id]
+			if onPath[id] || !upstream[id] {
+				continue
+			}
+			edge := " -> "
+			if expect := successor.depsResults[v.ID]; expect != V_RESULT_ALL {
+				edge = " -" + expectString(expect) + "-> "
+			}
+			walk(successor, path+edge+id)
+		}
+		onPath[v.ID] = false
+	}
+	walk(from, from.ID)
+	return paths
+}
+
+// QueryConfig returns vertices using the config_setting by 'expect_config' or 'select_args'.
+func (p *DAGConfig) QueryConfig(config string) []QueryResult {
+	var rs []QueryResult
+	for i := range p.graph.Graph {
+		g := &p.graph.Graph[i]
+		for _, v := range g.sortedVertexs() {
+			if len(v.ExpectConfig) > 0 && configName(v.ExpectConfig) == config {
+				rs = append(rs, p.newQueryResult(g, v, "expect_config="+v.ExpectConfig))
+				continue
+			}
+			for _, cond := range v.SelectArgs {
+				if configName(cond.Match) == config {
+					rs = append(rs, p.newQueryResult(g, v, "select_args="+cond.Match))
+					break
+				}
+			}
+		}
+	}
+	return rs
+}
+
+var queryArgs = map[string]int{
+	"consumers":  1,
+	"producers":  1,
+	"processor":  1,
+	"deps":       1,
+	"dependents": 1,
+	"paths":      2,
+	"config":     1,
+}
+
+// QueryExpr is a parsed query expression, see DAGConfig.Query for the syntax.
+type QueryExpr struct {
+	Kind string
+	Args []string
+	// Graph is the only graph to query if not empty.
+	Graph string
+}
+
+// ParseQuery parses the query expression, so it could be validated before scripts are loaded.
+func ParseQuery(expr string) (*QueryExpr, error) {
+	fields := strings.Fields(expr)
+	q := &QueryExpr{}
+	if n := len(fields); n >= 2 && fields[n-2] == "in" {
+		q.Graph = fields[n-1]
+		fields = fields[:n-2]
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Empty query")
+	}
+	q.Kind, q.Args = fields[0], fields[1:]
+	expected, exist := queryArgs[q.Kind]
+	if !exist {
+		return nil, fmt.Errorf("Unknown query:%s", q.Kind)
+	}
+	if len(q.Args) != expected {
+		return nil, fmt.Errorf("Query:%s expects %d arguments, but got %d", q.Kind, expected, len(q.Args))
+	}
+	return q, nil
+}
+
+// Query evaluates the query expression, one of:
+//
+//	consumers <data>     vertices consuming the data
+//	producers <data>     vertices producing the data
+//	processor <name>     vertices using the processor
+//	deps <vertex>        vertices the vertex transitively depends on
+//	dependents <vertex>  vertices transitively depending on the vertex
+//	paths <from> <to>    all paths from the vertex to the other
+//	config <name>        vertices using the config_setting
+//
+// optionally followed by 'in <graph>' to query the graph only.
+func (p *DAGConfig) Query(expr string) ([]QueryResult, error) {
+	q, err := ParseQuery(expr)
+	if nil != err {
+		return nil, err
+	}
+	return p.EvalQuery(q), nil
+}
+
+// EvalQuery evaluates the parsed query expression.
+func (p *DAGConfig) EvalQuery(q *QueryExpr) []QueryResult {
+	var rs []QueryResult
+	switch q.Kind {
+	case "consumers":
+		rs = p.QueryConsumers(q.Args[0])
+	case "producers":
+		rs = p.QueryProducers(q.Args[0])
+	case "processor":
+		rs = p.QueryProcessor(q.Args[0])
+	case "deps":
+		rs = p.QueryDeps(q.Args[0])
+	case "dependents":
+		rs = p.QueryDependents(q.Args[0])
+	case "paths":
+		rs = p.QueryPaths(q.Args[0], q.Args[1])
+	case "config":
+		rs = p.QueryConfig(q.Args[0])
+	}
+	if len(q.Graph) == 0 {
+		return rs
+	}
+	var filtered []QueryResult
+	for _, r := range rs {
+		if r.Graph == q.Graph {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
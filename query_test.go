@@ -0,0 +1,148 @@
+package didagle
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testQueryScript = `
+[[config_setting]]
+name = "on"
+cond = "x==1"
+[[graph]]
+name = "main"
+[[graph.vertex]]
+processor = "gen"
+output = [{ field = "x" }]
+[[graph.vertex]]
+processor = "use"
+input = [{ field = "x" }]
+output = [{ field = "y" }]
+expect_config = "on"
+[[graph.vertex]]
+processor = "sink"
+input = [{ field = "y" }]
+select_args = [{ match = "!on", args = { a = 1 } }]
+[[graph.vertex]]
+processor = "other"
+deps_on_ok = ["gen"]
+successor = ["sink"]
+[[graph]]
+name = "side"
+[[graph.vertex]]
+processor = "gen"
+output = [{ field = "x" }]
+[[graph.vertex]]
+processor = "use"
+input = [{ field = "x" }]
+`
+
+func TestQuery(t *testing.T) {
+	cfg := mustBuild(t, "", testQueryScript)
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"consumers x", []string{"DefaultCluster/main/use", "DefaultCluster/side/use"}},
+		{"consumers x in side", []string{"DefaultCluster/side/use"}},
+		{"producers y", []string{"DefaultCluster/main/use"}},
+		{"processor gen in main", []string{"DefaultCluster/main/gen"}},
+		{"deps sink in main", []string{"DefaultCluster/main/gen", "DefaultCluster/main/other direct all", "DefaultCluster/main/use direct all"}},
+		{"dependents gen in main", []string{"DefaultCluster/main/other direct ok", "DefaultCluster/main/sink", "DefaultCluster/main/use direct all"}},
+		{"paths gen sink in main", []string{"DefaultCluster/main/gen gen -ok-> other -> sink", "DefaultCluster/main/gen gen -> use -> sink"}},
+		{"paths sink gen", nil},
+		{"config on", []string{"DefaultCluster/main/sink select_args=!on", "DefaultCluster/main/use expect_config=on"}},
+		{"  consumers   x  in  nope ", nil},
+	}
+	for _, test := range tests {
+		rs, err := cfg.Query(test.expr)
+		if nil != err {
+			t.Errorf("%s: unexpected err:%v", test.expr, err)
+			continue
+		}
+		var got []string
+		for _, r := range rs {
+			got = append(got, r.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: results:%q, want:%q", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestQueryInvalid(t *testing.T) {
+	cfg := mustBuild(t, "", testQueryScript)
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"", "Empty query"},
+		{"in main", "Empty query"},
+		{"consumers", "expects 1 arguments, but got 0"},
+		{"paths gen", "expects 2 arguments, but got 1"},
+		{"deps a b in main", "expects 1 arguments, but got 2"},
+		{"unknown x", "Unknown query:unknown"},
+	}
+	for _, test := range tests {
+		if _, err := cfg.Query(test.expr); nil == err || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expect err:%s, but got:%v", test.expr, test.err, err)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		expr string
+		want QueryExpr
+	}{
+		{"deps a", QueryExpr{Kind: "deps", Args: []string{"a"}}},
+		{" paths a  b in g ", QueryExpr{Kind: "paths", Args: []string{"a", "b"}, Graph: "g"}},
+		{"consumers in", QueryExpr{Kind: "consumers", Args: []string{"in"}}},
+		{"consumers in in g", QueryExpr{Kind: "consumers", Args: []string{"in"}, Graph: "g"}},
+	}
+	for _, test := range tests {
+		q, err := ParseQuery(test.expr)
+		if nil != err || !reflect.DeepEqual(*q, test.want) {
+			t.Errorf("%q: query:%+v err:%v, want:%+v", test.expr, q, err, test.want)
+		}
+	}
+}
+
+func TestQueryPathsUnreachable(t *testing.T) {
+	// 40 diamonds in a row have 2^40 paths, the walk must not enter them when the target is unreachable
+	var b strings.Builder
+	b.WriteString("[[graph]]\nname = \"g\"\n")
+	vertex := func(id string, successor ...string) {
+		fmt.Fprintf(&b, "[[graph.vertex]]\nid = %q\nprocessor = \"p\"\nsuccessor = [%s]\n", id, quoteJoin(successor))
+	}
+	const diamonds = 40
+	for i := 0; i < diamonds; i++ {
+		next := fmt.Sprintf("s%d", i+1)
+		vertex(fmt.Sprintf("s%d", i), fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i))
+		vertex(fmt.Sprintf("a%d", i), next)
+		vertex(fmt.Sprintf("b%d", i), next)
+	}
+	fmt.Fprintf(&b, "[[graph.vertex]]\nid = \"s%d\"\nprocessor = \"p\"\n", diamonds)
+	vertex("x", "y")
+	b.WriteString("[[graph.vertex]]\nid = \"y\"\nprocessor = \"p\"\n")
+	cfg := mustBuild(t, "", b.String())
+	if rs := cfg.QueryPaths("s0", "y"); len(rs) != 0 {
+		t.Errorf("Unexpected paths:%v", rs)
+	}
+	if rs := cfg.QueryPaths("s0", fmt.Sprintf("s%d", diamonds)); len(rs) != MAX_QUERY_PATHS {
+		t.Errorf("Expect %d paths, but got %d", MAX_QUERY_PATHS, len(rs))
+	}
+	if rs := cfg.QueryPaths(fmt.Sprintf("s%d", diamonds-1), fmt.Sprintf("s%d", diamonds)); len(rs) != 2 {
+		t.Errorf("Expect 2 paths, but got %v", rs)
+	}
+}
+
+func quoteJoin(ids []string) string {
+	quoted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		quoted = append(quoted, fmt.Sprintf("%q", id))
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,202 @@
+package didagle
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Operator is implemented by go operators, tagged input fields are injected before Execute
+// and tagged output fields are collected after Execute.
+type Operator interface {
+	Execute(ctx context.Context, args map[string]interface{}) error
+}
+
+// OP_FIELD_TAG is the struct tag declaring an operator field, the format is
+// `didagle:"input|output[,name=<data name>][,extern][,in_out][,aggregate]"`, name defaults to the go field name.
+const OP_FIELD_TAG = "didagle"
+
+type opFieldBinding struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+type registeredOperator struct {
+	meta    OperatorMeta
+	typ     reflect.Type
+	inputs  []opFieldBinding
+	outputs []opFieldBinding
+}
+
+// OperatorRegistry holds go operators declared as structs with tagged input/output fields,
+// it's also an OpMetaProvider so scripts could be built without a hand written op meta json.
+type OperatorRegistry struct {
+	mutex sync.RWMutex
+	ops   map[string]*registeredOperator
+}
+
+func NewOperatorRegistry() *OperatorRegistry {
+	return &OperatorRegistry{ops: make(map[string]*registeredOperator)}
+}
+
+// DefaultRegistry is the registry used by RegisterOperator.
+var DefaultRegistry = NewOperatorRegistry()
+
+func RegisterOperator(name string, op interface{}) error {
+	return DefaultRegistry.Register(name, op)
+}
+
+func MustRegisterOperator(name string, op interface{}) {
+	if err := DefaultRegistry.Register(name, op); nil != err {
+		panic(err)
+	}
+}
+
+// fieldTypeId returns a stable id of the go type, used as FieldMeta.ID.
+func fieldTypeId(typ reflect.Type) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(typ.String()))
+	return int64(h.Sum64() & 0x7fffffffffffffff)
+}
+
+func parseOpField(name string, field reflect.StructField, tag string) (*FieldMeta, bool, error) {
+	parts := strings.Split(tag, ",")
+	isInput := false
+	switch strings.TrimSpace(parts[0]) {
+	case "input":
+		isInput = true
+	case "output":
+	default:
+		return nil, false, fmt.Errorf("Operator:%s field:%s tag must start with 'input' or 'output'", name, field.Name)
+	}
+	meta := &FieldMeta{
+		Name: field.Name,
+		ID:   fieldTypeId(field.Type),
+		Type: field.Type.String(),
+	}
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		switch {
+		case strings.HasPrefix(part, "name="):
+			meta.Name = strings.TrimPrefix(part, "name=")
+		case part == "extern":
+			meta.Flags.Extern = 1
+		case part == "in_out":
+			meta.Flags.InOut = 1
+		case part == "aggregate":
+			meta.Flags.Agrregate = 1
+		default:
+			return nil, false, fmt.Errorf("Operator:%s field:%s has unknown tag option:%s", name, field.Name, part)
+		}
+	}
+	if len(meta.Name) == 0 {
+		return nil, false, fmt.Errorf("Operator:%s field:%s has empty name", name, field.Name)
+	}
+	if !isInput && (meta.Flags.Extern > 0 || meta.Flags.Agrregate > 0) {
+		return nil, false, fmt.Errorf("Operator:%s output field:%s can NOT be 'extern' or 'aggregate'", name, field.Name)
+	}
+	if meta.Flags.Agrregate > 0 && field.Type.Kind() != reflect.Map && field.Type.Kind() != reflect.Slice {
+		return nil, false, fmt.Errorf("Operator:%s aggregate field:%s must be a map or slice, but got %s", name, field.Name, field.Type)
+	}
+	return meta, isInput, nil
+}
+
+// newRegisteredOperator extracts the operator meta from the struct(or pointer to struct) type of op.
+func newRegisteredOperator(name string, op interface{}) (*registeredOperator, error) {
+	typ := reflect.TypeOf(op)
+	if nil != typ && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if nil == typ || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Operator:%s must be a struct or pointer to struct, but got %v", name, typ)
+	}
+	r := &registeredOperator{typ: typ}
+	r.meta.Name = name
+	if idx := strings.Index(name, OP_VERSION_SEPARATOR); idx > 0 {
+		r.meta.Name = name[:idx]
+		r.meta.Version = name[idx+1:]
+	}
+	inputs := make(map[string]bool)
+	outputs := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, exist := field.Tag.Lookup(OP_FIELD_TAG)
+		if !exist {
+			continue
+		}
+		if !field.IsExported() {
+			return nil, fmt.Errorf("Operator:%s field:%s must be exported", name, field.Name)
+		}
+		meta, isInput, err := parseOpField(name, field, tag)
+		if nil != err {
+			return nil, err
+		}
+		binding := opFieldBinding{name: meta.Name, index: field.Index, typ: field.Type}
+		if isInput || meta.Flags.InOut > 0 {
+			if inputs[meta.Name] {
+				return nil, fmt.Errorf("Operator:%s has duplicate input:%s", name, meta.Name)
+			}
+			inputs[meta.Name] = true
+			r.meta.Input = append(r.meta.Input, *meta)
+			r.inputs = append(r.inputs, binding)
+		}
+		if !isInput || meta.Flags.InOut > 0 {
+			if outputs[meta.Name] {
+				return nil, fmt.Errorf("Operator:%s has duplicate output:%s", name, meta.Name)
+			}
+			outputs[meta.Name] = true
+			output := *meta
+			output.Flags = FieldFlags{InOut: meta.Flags.InOut}
+			r.meta.Output = append(r.meta.Output, output)
+			r.outputs = append(r.outputs, binding)
+		}
+	}
+	return r, nil
+}
+
+// Register registers the go operator by name('name' or 'name@version'), op is a prototype value of the operator struct.
+func (p *OperatorRegistry) Register(name string, op interface{}) error {
+	if len(name) == 0 {
+		return fmt.Errorf("Empty operator name")
+	}
+	r, err := newRegisteredOperator(name, op)
+	if nil != err {
+		return err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, exist := p.ops[name]; exist {
+		return fmt.Errorf("Duplicate operator:%s registered", name)
+	}
+	p.ops[name] = r
+	return nil
+}
+
+func (p *OperatorRegistry) get(name string) *registeredOperator {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.ops[name]
+}
+
+func (p *OperatorRegistry) Source() string {
+	return "registry"
+}
+
+// OpMetas returns metas of all registered operators sorted by name.
+func (p *OperatorRegistry) OpMetas() ([]OperatorMeta, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	metas := make([]OperatorMeta, 0, len(p.ops))
+	for _, op := range p.ops {
+		metas = append(metas, op.meta)
+	}
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].FullName() < metas[j].FullName()
+	})
+	return metas, nil
+}
@@ -0,0 +1,185 @@
+package didagle
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	Name string
+}
+
+type testTaggedOp struct {
+	Query   string               `didagle:"input,name=q"`
+	Env     map[string]string    `didagle:"input,extern"`
+	Users   []*testUser          `didagle:"input,aggregate"`
+	Scores  map[string][]float64 `didagle:"input, name=scores , aggregate"`
+	Counter *int64               `didagle:"input,in_out"`
+	Result  testUser             `didagle:"output"`
+	Ch      chan<- error         `didagle:"output,name=errs"`
+	Ignored int
+}
+
+func (p *testTaggedOp) Execute(ctx context.Context, args map[string]interface{}) error {
+	return nil
+}
+
+func TestRegistryTagParsing(t *testing.T) {
+	registry := NewOperatorRegistry()
+	if err := registry.Register("tagged@v1", &testTaggedOp{}); nil != err {
+		t.Fatal(err)
+	}
+	metas, err := registry.OpMetas()
+	if nil != err || len(metas) != 1 {
+		t.Fatalf("Unexpected metas:%v with err:%v", metas, err)
+	}
+	meta := metas[0]
+	if meta.Name != "tagged" || meta.Version != "v1" || meta.FullName() != "tagged@v1" {
+		t.Errorf("Unexpected operator name:%s version:%s", meta.Name, meta.Version)
+	}
+	field := func(name string, typ string, goType interface{}, flags FieldFlags) FieldMeta {
+		// ids are stable hashes of the go type
+		return FieldMeta{Name: name, ID: fieldTypeId(reflect.TypeOf(goType).Elem()), Type: typ, Flags: flags}
+	}
+	wantInput := []FieldMeta{
+		field("q", "string", new(string), FieldFlags{}),
+		field("Env", "map[string]string", new(map[string]string), FieldFlags{Extern: 1}),
+		field("Users", "[]*didagle.testUser", new([]*testUser), FieldFlags{Agrregate: 1}),
+		field("scores", "map[string][]float64", new(map[string][]float64), FieldFlags{Agrregate: 1}),
+		field("Counter", "*int64", new(*int64), FieldFlags{InOut: 1}),
+	}
+	wantOutput := []FieldMeta{
+		field("Counter", "*int64", new(*int64), FieldFlags{InOut: 1}),
+		field("Result", "didagle.testUser", new(testUser), FieldFlags{}),
+		field("errs", "chan<- error", new(chan<- error), FieldFlags{}),
+	}
+	if !reflect.DeepEqual(meta.Input, wantInput) {
+		t.Errorf("Expect input:%+v, but got %+v", wantInput, meta.Input)
+	}
+	if !reflect.DeepEqual(meta.Output, wantOutput) {
+		t.Errorf("Expect output:%+v, but got %+v", wantOutput, meta.Output)
+	}
+	if r := registry.get("tagged@v1"); len(r.inputs) != 5 || len(r.outputs) != 3 {
+		t.Errorf("Unexpected bindings, inputs:%v outputs:%v", r.inputs, r.outputs)
+	}
+}
+
+func TestRegistryInvalidTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     interface{}
+		errMsg string
+	}{
+		{"not struct", 1, "must be a struct or pointer to struct"},
+		{"nil", nil, "must be a struct or pointer to struct"},
+		{"kind", struct {
+			A int `didagle:"param"`
+		}{}, "tag must start with 'input' or 'output'"},
+		{"option", struct {
+			A int `didagle:"input,optional"`
+		}{}, "unknown tag option:optional"},
+		{"empty name", struct {
+			A int `didagle:"input,name="`
+		}{}, "has empty name"},
+		{"extern output", struct {
+			A int `didagle:"output,extern"`
+		}{}, "can NOT be 'extern' or 'aggregate'"},
+		{"aggregate output", struct {
+			A []int `didagle:"output,aggregate"`
+		}{}, "can NOT be 'extern' or 'aggregate'"},
+		{"aggregate type", struct {
+			A int `didagle:"input,aggregate"`
+		}{}, "must be a map or slice"},
+		{"unexported", struct {
+			a int `didagle:"input"`
+		}{}, "must be exported"},
+		{"duplicate input", struct {
+			A int `didagle:"input,name=x"`
+			B int `didagle:"input,in_out,name=x"`
+		}{}, "duplicate input:x"},
+		{"duplicate output", struct {
+			A int `didagle:"output,name=x"`
+			B int `didagle:"input,in_out,name=x"`
+		}{}, "duplicate output:x"},
+	}
+	for _, test := range tests {
+		err := NewOperatorRegistry().Register("op", test.op)
+		if nil == err || !strings.Contains(err.Error(), test.errMsg) {
+			t.Errorf("%s: expect err:%s, but got:%v", test.name, test.errMsg, err)
+		}
+	}
+	registry := NewOperatorRegistry()
+	registry.Register("op", &testNopOp{})
+	if err := registry.Register("op", &testNopOp{}); nil == err || !strings.Contains(err.Error(), "Duplicate operator:op") {
+		t.Errorf("Expect duplicate operator error, but got:%v", err)
+	}
+}
+
+type testSearchOp struct {
+	Query string   `didagle:"input,name=query"`
+	Env   string   `didagle:"input,extern"`
+	Items []string `didagle:"output,name=items"`
+}
+
+func (p *testSearchOp) Execute(ctx context.Context, args map[string]interface{}) error {
+	return nil
+}
+
+func TestMergeRegisteredOpMetas(t *testing.T) {
+	registry := NewOperatorRegistry()
+	if err := registry.Register("search@v1", &testSearchOp{}); nil != err {
+		t.Fatal(err)
+	}
+	registered, _ := registry.OpMetas()
+	// the hand written meta of the same operator, with c++ types & ids
+	written := OpMetaList{{
+		Name:    "search",
+		Version: "v1",
+		Input: []FieldMeta{
+			{Name: "Env", ID: 3, Type: "const Env&", Flags: FieldFlags{Extern: 1}},
+			{Name: "query", ID: 1, Type: "std::string"},
+		},
+		Output: []FieldMeta{{Name: "items", ID: 2, Type: "std::vector<std::string>"}},
+	}, {Name: "other", Input: []FieldMeta{}, Output: []FieldMeta{}}}
+	for _, providers := range [][]OpMetaProvider{{registry, written}, {written, registry}} {
+		merged, err := MergeOpMetas(providers...)
+		if nil != err {
+			t.Fatalf("%s first: failed to merge with err:%v", providers[0].Source(), err)
+		}
+		if len(merged) != 2 {
+			t.Fatalf("Unexpected merged metas:%v", merged)
+		}
+		for _, meta := range merged {
+			if meta.Name == "search" && !reflect.DeepEqual(meta, registered[0]) {
+				t.Errorf("%s first: expect the registered meta wins, but got %+v", providers[0].Source(), meta)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(meta *OperatorMeta)
+	}{
+		{"renamed field", func(meta *OperatorMeta) { meta.Input[1].Name = "q" }},
+		{"missing field", func(meta *OperatorMeta) { meta.Output = nil }},
+		{"flags", func(meta *OperatorMeta) { meta.Input[0].Flags.Extern = 0 }},
+	}
+	for _, test := range tests {
+		conflict := OpMetaList{written[0]}
+		conflict[0].Input = append([]FieldMeta{}, written[0].Input...)
+		test.modify(&conflict[0])
+		for _, providers := range [][]OpMetaProvider{{registry, conflict}, {conflict, registry}} {
+			if _, err := MergeOpMetas(providers...); nil == err || !strings.Contains(err.Error(), "Conflict operator:search@v1") {
+				t.Errorf("%s: expect conflict, but got:%v", test.name, err)
+			}
+		}
+	}
+	// types & ids still conflict between other sources
+	typed := OpMetaList{written[0]}
+	typed[0].Output = []FieldMeta{{Name: "items", ID: 2, Type: "std::list<std::string>"}}
+	if _, err := MergeOpMetas(written, typed); nil == err || !strings.Contains(err.Error(), "Conflict operator:search@v1 defined in list and list") {
+		t.Errorf("Expect typed conflict, but got:%v", err)
+	}
+}
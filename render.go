@@ -0,0 +1,511 @@
+package didagle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+const FOCUS_BOTH int = 0
+const FOCUS_UPSTREAM int = 1
+const FOCUS_DOWNSTREAM int = 2
+
+// RenderOptions controls how a graph cluster is rendered into dot.
+type RenderOptions struct {
+	// SubGraphDepth is the max depth to inline the graph referenced by a sub graph vertex,
+	// 0 keeps sub graph vertices as a single 'cluster::graph' node.
+	SubGraphDepth int
+	// Clusters are other loaded clusters used to resolve cross cluster sub graph references.
+	Clusters []*DAGConfig
+
+	// Graph renders only the graph with this name if not empty.
+	Graph string
+	// FocusVertex/FocusData renders only the closure of the vertex id or data id,
+	// graphs not containing the focus are skipped, elided vertices are collapsed into one summary node.
+	FocusVertex string
+	FocusData   string
+	// FocusDirection is one of FOCUS_BOTH/FOCUS_UPSTREAM/FOCUS_DOWNSTREAM.
+	FocusDirection int
+}
+
+func (p *RenderOptions) hasFocus() bool {
+	return len(p.FocusVertex) > 0 || len(p.FocusData) > 0
+}
+
+func (p *RenderOptions) verify(cluster *GraphCluster) error {
+	if p.FocusDirection < FOCUS_BOTH || p.FocusDirection > FOCUS_DOWNSTREAM {
+		return fmt.Errorf("Invalid focus direction:%d", p.FocusDirection)
+	}
+	if len(p.FocusVertex) > 0 && len(p.FocusData) > 0 {
+		return fmt.Errorf("Can NOT both focus on vertex:%s & data:%s", p.FocusVertex, p.FocusData)
+	}
+	if len(p.Graph) > 0 && nil == cluster.getGraphByName(p.Graph) {
+		return fmt.Errorf("No graph with name:%s in cluster:%s", p.Graph, cluster.name)
+	}
+	if !p.hasFocus() {
+		return nil
+	}
+	for i := range cluster.Graph {
+		g := &cluster.Graph[i]
+		if len(p.Graph) > 0 && g.Name != p.Graph {
+			continue
+		}
+		if nil != p.focus(g) {
+			return nil
+		}
+	}
+	if len(p.FocusVertex) > 0 {
+		return fmt.Errorf("No vertex:%s found in cluster:%s", p.FocusVertex, cluster.name)
+	}
+	return fmt.Errorf("No data:%s found in cluster:%s", p.FocusData, cluster.name)
+}
+
+// focus returns the visible vertex ids of the graph, nil if the graph does not contain the focus.
+func (p *RenderOptions) focus(g *Graph) map[string]bool {
+	var roots []*Vertex
+	var producers, consumers []*Vertex
+	if len(p.FocusVertex) > 0 {
+		if v := g.getVertexById(p.FocusVertex); nil != v {
+			roots = append(roots, v)
+			producers = roots
+			consumers = roots
+		}
+	} else {
+		if v := g.getVertexByData(p.FocusData); nil != v {
+			producers = append(producers, v)
+		}
+		consumers = g.getConsumersByData(p.FocusData)
+		roots = append(roots, producers...)
+		roots = append(roots, consumers...)
+	}
+	if len(roots) == 0 {
+		return nil
+	}
+	// the walks mark the start vertex first, so upstream & downstream need separate visited sets
+	upstream := make(map[string]bool)
+	if p.FocusDirection != FOCUS_DOWNSTREAM {
+		for _, v := range producers {
+			g.collectUpstream(v, upstream)
+		}
+	}
+	downstream := make(map[string]bool)
+	if p.FocusDirection != FOCUS_UPSTREAM {
+		for _, v := range consumers {
+			g.collectDownstream(v, downstream)
+		}
+	}
+	visible := upstream
+	for id := range downstream {
+		visible[id] = true
+	}
+	for _, v := range roots {
+		visible[v.ID] = true
+	}
+	return visible
+}
+
+type dotRender struct {
+	s        *strings.Builder
+	opt      RenderOptions
+	clusters map[string]*GraphCluster
+	// stack of 'cluster::graph' being inlined, used to stop recursive sub graph references
+	stack []string
+	// trace annotates vertices with execution results if not nil, paths maps dot scopes to graph paths of the trace
+	trace map[string]map[string]*VertexResult
+	paths map[string]string
+}
+
+func (r *dotRender) traced(scope string) map[string]*VertexResult {
+	if nil == r.trace {
+		return nil
+	}
+	return r.trace[r.paths[scope]]
+}
+
+func newDotRender(s *strings.Builder, cluster *GraphCluster, opt *RenderOptions) *dotRender {
+	r := &dotRender{
+		s:        s,
+		clusters: make(map[string]*GraphCluster),
+		paths:    make(map[string]string),
+	}
+	if nil != opt {
+		r.opt = *opt
+	}
+	for _, c := range r.opt.Clusters {
+		if nil != c {
+			r.clusters[c.graph.name] = &c.graph
+		}
+	}
+	if nil != cluster {
+		r.clusters[cluster.name] = cluster
+	}
+	return r
+}
+
+func (r *dotRender) resolveGraph(v *Vertex) *Graph {
+	cluster, exist := r.clusters[v.Cluster]
+	if !exist {
+		return nil
+	}
+	return cluster.getGraphByName(v.Graph)
+}
+
+func (r *dotRender) canExpand(v *Vertex) (*Graph, bool) {
+	if len(v.Graph) == 0 || len(r.stack) >= r.opt.SubGraphDepth {
+		return nil, false
+	}
+	g := r.resolveGraph(v)
+	if nil == g {
+		return nil, false
+	}
+	key := fmt.Sprintf("%s::%s", v.Cluster, v.Graph)
+	for _, name := range r.stack {
+		if name == key {
+			return nil, false
+		}
+	}
+	return g, true
+}
+
+func (r *dotRender) dumpRootGraph(g *Graph) {
+	if len(r.opt.Graph) > 0 && g.Name != r.opt.Graph {
+		return
+	}
+	r.paths[g.Name] = g.Name
+	if !r.opt.hasFocus() {
+		r.dumpGraph(g, g.Name, g.Name, nil)
+		return
+	}
+	visible := r.opt.focus(g)
+	if nil == visible {
+		return
+	}
+	r.dumpGraph(g, g.Name, g.Name, visible)
+}
+
+func (r *dotRender) dumpGraph(g *Graph, scope string, label string, visible map[string]bool) {
+	buffer := r.s
+	buffer.WriteString("  subgraph ")
+	buffer.WriteString(dotQuote("cluster_" + scope))
+	buffer.WriteString("{\n")
+	buffer.WriteString("    style = rounded;\n")
+	buffer.WriteString(fmt.Sprintf("    label = \"%s\";\n", label))
+	buffer.WriteString("    ")
+	buffer.WriteString(dotQuote(scope + "__START__"))
+	buffer.WriteString("[color=black fillcolor=deepskyblue style=filled shape=Msquare label=\"START\"];\n")
+	buffer.WriteString("    ")
+	buffer.WriteString(dotQuote(scope + "__STOP__"))
+	buffer.WriteString("[color=black fillcolor=deepskyblue style=filled shape=Msquare label=\"STOP\"];\n")
+
+	for _, v := range g.sortedVertexs() {
+		if nil != visible && !visible[v.ID] {
+			continue
+		}
+		v.dumpDotDefine(buffer, scope)
+	}
+	if traced := r.traced(scope); nil != traced {
+		dumpTraceAnnotations(buffer, g, scope, traced)
+	}
+
+	for _, c := range g.cluster.ConfigSetting {
+		buffer.WriteString("    ")
+		buffer.WriteString(dotQuote(scope + "_" + c.Name))
+		buffer.WriteString(" [label=\"")
+		buffer.WriteString(c.Name)
+		buffer.WriteString("\"")
+		buffer.WriteString(" shape=diamond color=black fillcolor=aquamarine style=filled];\n")
+	}
+
+	for _, v := range g.sortedVertexs() {
+		if v.isGenerated {
+			continue
+		}
+		if nil != visible && !visible[v.ID] {
+			continue
+		}
+		v.dumpDotEdge(buffer, scope, visible, r.traced(scope))
+	}
+	if nil != visible {
+		r.dumpElided(g, scope, visible)
+	}
+	for _, v := range g.sortedVertexs() {
+		if nil != visible && !visible[v.ID] {
+			continue
+		}
+		if sub, ok := r.canExpand(v); ok {
+			r.dumpSubGraph(v, scope, sub)
+		}
+	}
+	buffer.WriteString("};\n")
+}
+
+// dumpElided collapses all invisible vertices into one summary node linked with the visible ones.
+func (r *dotRender) dumpElided(g *Graph, scope string, visible map[string]bool) {
+	elided := 0
+	for _, v := range g.sortedVertexs() {
+		if !visible[v.ID] && !v.isGenerated {
+			elided++
+		}
+	}
+	if elided == 0 {
+		return
+	}
+	elidedId := dotQuote(scope + "__ELIDED__")
+	r.s.WriteString("    " + elidedId)
+	r.s.WriteString(fmt.Sprintf(" [label=\"%d vertices elided\" shape=note color=gray fontcolor=gray style=dashed];\n", elided))
+	for _, v := range g.sortedVertexs() {
+		if !visible[v.ID] {
+			continue
+		}
+		hasElidedDep := false
+		for id := range v.depsResults {
+			if !visible[id] {
+				hasElidedDep = true
+			}
+		}
+		if hasElidedDep {
+			r.s.WriteString("    " + elidedId + " -> " + v.dotId(scope) + " [style=dotted color=gray];\n")
+		}
+		hasElidedSuccessor := false
+		for id := range v.successorVertex {
+			if !visible[id] {
+				hasElidedSuccessor = true
+			}
+		}
+		if hasElidedSuccessor {
+			r.s.WriteString("    " + v.dotId(scope) + " -> " + elidedId + " [style=dotted color=gray];\n")
+		}
+	}
+}
+
+func (r *dotRender) dumpSubGraph(v *Vertex, scope string, sub *Graph) {
+	callerId := v.dotId(scope)
+	subScope := v.dotName(scope) + "__" + sub.Name
+	r.paths[subScope] = r.paths[scope] + "/" + v.ID + "/" + sub.Name
+	r.stack = append(r.stack, fmt.Sprintf("%s::%s", v.Cluster, v.Graph))
+	r.dumpGraph(sub, subScope, fmt.Sprintf("%s::%s", v.Cluster, v.Graph), nil)
+	r.stack = r.stack[:len(r.stack)-1]
+	r.s.WriteString("    " + callerId + " -> " + dotQuote(subScope+"__START__") + " [style=dotted color=blue label=\"call\"];\n")
+	r.s.WriteString("    " + dotQuote(subScope+"__STOP__") + " -> " + callerId + " [style=dotted color=blue label=\"return\" constraint=false];\n")
+}
+
+func mermaidId(scope string, id string) string {
+	return strings.NewReplacer("-", "_", ".", "_", ":", "_", OP_VERSION_SEPARATOR, "_").Replace(scope + "_" + id)
+}
+
+func mermaidLabel(label string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(label, "\\\"", "#quot;"), "\"", "#quot;")
+}
+
+func (p *GraphCluster) dumpMermaid(buffer *strings.Builder, opt *RenderOptions) {
+	r := newDotRender(buffer, p, opt)
+	buffer.WriteString("flowchart LR\n")
+	for i := range p.Graph {
+		g := &p.Graph[i]
+		if len(r.opt.Graph) > 0 && g.Name != r.opt.Graph {
+			continue
+		}
+		var visible map[string]bool
+		if r.opt.hasFocus() {
+			if visible = r.opt.focus(g); nil == visible {
+				continue
+			}
+		}
+		start := mermaidId(g.Name, "_START_")
+		stop := mermaidId(g.Name, "_STOP_")
+		buffer.WriteString(fmt.Sprintf("  subgraph %s [\"%s\"]\n", mermaidId(g.Name, ""), g.Name))
+		buffer.WriteString(fmt.Sprintf("    %s[/START/]\n    %s[/STOP/]\n", start, stop))
+		for _, v := range g.sortedVertexs() {
+			if nil != visible && !visible[v.ID] {
+				continue
+			}
+			id := mermaidId(g.Name, v.ID)
+			label := mermaidLabel(v.getDotLabel())
+			if len(v.Cond) > 0 {
+				buffer.WriteString(fmt.Sprintf("    %s{\"%s\"}\n", id, label))
+			} else if len(v.Graph) > 0 {
+				buffer.WriteString(fmt.Sprintf("    %s[[\"%s\"]]\n", id, label))
+			} else {
+				buffer.WriteString(fmt.Sprintf("    %s[\"%s\"]\n", id, label))
+			}
+		}
+		for _, v := range g.sortedVertexs() {
+			if nil != visible && !visible[v.ID] {
+				continue
+			}
+			id := mermaidId(g.Name, v.ID)
+			if v.isDepsEmpty() {
+				buffer.WriteString(fmt.Sprintf("    %s --> %s\n", start, id))
+			}
+			if v.isSuccessorsEmpty() {
+				buffer.WriteString(fmt.Sprintf("    %s --> %s\n", id, stop))
+			}
+			for _, dep := range v.sortedDeps() {
+				if nil != visible && !visible[dep.ID] {
+					continue
+				}
+				if dep.Expect == "all" {
+					buffer.WriteString(fmt.Sprintf("    %s --> %s\n", mermaidId(g.Name, dep.ID), id))
+				} else {
+					buffer.WriteString(fmt.Sprintf("    %s -.->|%s| %s\n", mermaidId(g.Name, dep.ID), dep.Expect, id))
+				}
+			}
+		}
+		buffer.WriteString("  end\n")
+	}
+}
+
+// DepModel is a dependency of a vertex with the expected result('ok'/'err'/'all').
+type DepModel struct {
+	ID     string `json:"id"`
+	Expect string `json:"expect"`
+}
+
+// VertexModel is the json view of a built vertex.
+type VertexModel struct {
+	ID        string      `json:"id"`
+	Processor string      `json:"processor,omitempty"`
+	Cond      string      `json:"cond,omitempty"`
+	Cluster   string      `json:"cluster,omitempty"`
+	Graph     string      `json:"graph,omitempty"`
+	Generated bool        `json:"generated,omitempty"`
+	Deps      []DepModel  `json:"deps,omitempty"`
+	Input     []GraphData `json:"input,omitempty"`
+	Output    []GraphData `json:"output,omitempty"`
+}
+
+// GraphModel is the json view of a built graph.
+type GraphModel struct {
+	Name    string        `json:"name"`
+	Vertexs []VertexModel `json:"vertexs"`
+	// Elided is the number of vertices out of the focus.
+	Elided int `json:"elided,omitempty"`
+}
+
+// ClusterModel is the json view of a built graph cluster.
+type ClusterModel struct {
+	Name          string          `json:"name"`
+	Desc          string          `json:"desc,omitempty"`
+	StrictDsl     bool            `json:"strict_dsl"`
+	ConfigSetting []ConfigSetting `json:"config_setting,omitempty"`
+	Graphs        []GraphModel    `json:"graphs"`
+}
+
+func (p *Vertex) model() VertexModel {
+	m := VertexModel{
+		ID:        p.ID,
+		Processor: p.Processor,
+		Cond:      p.Cond,
+		Cluster:   p.Cluster,
+		Graph:     p.Graph,
+		Generated: p.isGenerated,
+		Deps:      p.sortedDeps(),
+		Input:     p.Input,
+		Output:    p.Output,
+	}
+	return m
+}
+
+func (p *Vertex) sortedDeps() []DepModel {
+	deps := make([]DepModel, 0, len(p.depsResults))
+	for id, expect := range p.depsResults {
+		deps = append(deps, DepModel{ID: id, Expect: expectString(expect)})
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].ID < deps[j].ID
+	})
+	return deps
+}
+
+// Model returns the json view of the built cluster.
+func (p *DAGConfig) Model() *ClusterModel {
+	return p.modelWithOptions(nil)
+}
+
+// modelWithOptions returns the json view of the graphs & focus selected by the options, like rendered dot.
+func (p *DAGConfig) modelWithOptions(opt *RenderOptions) *ClusterModel {
+	m := &ClusterModel{
+		Name:          p.graph.name,
+		Desc:          p.graph.Desc,
+		StrictDsl:     p.graph.StrictDsl,
+		ConfigSetting: p.graph.ConfigSetting,
+	}
+	for i := range p.graph.Graph {
+		g := &p.graph.Graph[i]
+		if nil != opt && len(opt.Graph) > 0 && g.Name != opt.Graph {
+			continue
+		}
+		var visible map[string]bool
+		if nil != opt && opt.hasFocus() {
+			if visible = opt.focus(g); nil == visible {
+				continue
+			}
+		}
+		gm := GraphModel{Name: g.Name}
+		for _, v := range g.sortedVertexs() {
+			if nil != visible && !visible[v.ID] {
+				if !v.isGenerated {
+					gm.Elided++
+				}
+				continue
+			}
+			vm := v.model()
+			if nil != visible {
+				deps := vm.Deps[:0]
+				for _, dep := range vm.Deps {
+					if visible[dep.ID] {
+						deps = append(deps, dep)
+					}
+				}
+				vm.Deps = deps
+			}
+			gm.Vertexs = append(gm.Vertexs, vm)
+		}
+		m.Graphs = append(m.Graphs, gm)
+	}
+	return m
+}
+
+func (p *DAGConfig) DumpMermaid(opt *RenderOptions) string {
+	builder := &strings.Builder{}
+	p.graph.dumpMermaid(builder, opt)
+	return builder.String()
+}
+
+// VerifyRenderOptions checks the graph & focus of the options exist in the cluster, nil options are valid.
+func (p *DAGConfig) VerifyRenderOptions(opt *RenderOptions) error {
+	if nil == opt {
+		return nil
+	}
+	return opt.verify(&p.graph)
+}
+
+// Render renders the cluster into format 'dot'/'svg'/'png'/'mermaid'/'json', 'svg' & 'png' need graphviz 'dot' installed.
+func (p *DAGConfig) Render(format string, opt *RenderOptions) ([]byte, error) {
+	if err := p.VerifyRenderOptions(opt); nil != err {
+		return nil, err
+	}
+	switch format {
+	case "dot":
+		return []byte(p.DumpDotWithOptions(opt)), nil
+	case "mermaid":
+		return []byte(p.DumpMermaid(opt)), nil
+	case "json":
+		return json.MarshalIndent(p.modelWithOptions(opt), "", "  ")
+	case "svg", "png":
+		cmd := exec.Command("dot", "-T"+format)
+		cmd.Stdin = strings.NewReader(p.DumpDotWithOptions(opt))
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if nil != err {
+			return nil, fmt.Errorf("Failed to exec graphviz dot with err:%v %s", err, stderr.String())
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("Unsupported render format:%s", format)
+	}
+}
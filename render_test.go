@@ -0,0 +1,213 @@
+package didagle
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testChainOps = `[
+{"name":"gen","input":[],"output":[{"name":"x","id":1,"type":"int"}]},
+{"name":"use","input":[{"name":"x","id":1,"type":"int"}],"output":[{"name":"y","id":2,"type":"int"}]},
+{"name":"sink","input":[{"name":"y","id":2,"type":"int"}],"output":[]},
+{"name":"other","input":[],"output":[]}
+]`
+
+const testChainScript = `
+strict_dsl = true
+[[graph]]
+name = "main"
+[[graph.vertex]]
+processor = "gen"
+[[graph.vertex]]
+processor = "use"
+[[graph.vertex]]
+processor = "sink"
+[[graph.vertex]]
+processor = "other"
+deps = ["gen"]
+`
+
+func mustBuild(t *testing.T, ops string, script string) *DAGConfig {
+	t.Helper()
+	cfg, err := NewDAGConfigByContent(ops, script)
+	if nil != err {
+		t.Fatalf("Failed to build script with err:%v", err)
+	}
+	return cfg
+}
+
+func visibleIds(visible map[string]bool) []string {
+	ids := []string{}
+	for id := range visible {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestRenderFocus(t *testing.T) {
+	cfg := mustBuild(t, testChainOps, testChainScript)
+	g := cfg.graph.getGraphByName("main")
+	tests := []struct {
+		name string
+		opt  RenderOptions
+		want []string
+	}{
+		{"vertex both", RenderOptions{FocusVertex: "use"}, []string{"gen", "sink", "use"}},
+		{"vertex upstream", RenderOptions{FocusVertex: "use", FocusDirection: FOCUS_UPSTREAM}, []string{"gen", "use"}},
+		{"vertex downstream", RenderOptions{FocusVertex: "use", FocusDirection: FOCUS_DOWNSTREAM}, []string{"sink", "use"}},
+		{"data both", RenderOptions{FocusData: "y"}, []string{"gen", "sink", "use"}},
+		{"data upstream", RenderOptions{FocusData: "y", FocusDirection: FOCUS_UPSTREAM}, []string{"gen", "sink", "use"}},
+		{"data downstream", RenderOptions{FocusData: "x", FocusDirection: FOCUS_DOWNSTREAM}, []string{"gen", "sink", "use"}},
+		{"leaf downstream", RenderOptions{FocusVertex: "sink", FocusDirection: FOCUS_DOWNSTREAM}, []string{"sink"}},
+	}
+	for _, test := range tests {
+		got := visibleIds(test.opt.focus(g))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: visible:%v, want:%v", test.name, got, test.want)
+		}
+	}
+	opt := RenderOptions{FocusVertex: "nope"}
+	if nil != opt.focus(g) {
+		t.Errorf("Expect nil visible set for missing vertex")
+	}
+}
+
+func TestRenderSubGraphs(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.toml": `
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "call"
+cluster = "b.toml"
+graph = "outer"
+successor = ["after", "other"]
+[[graph.vertex]]
+id = "after"
+processor = "nop"
+[[graph.vertex]]
+id = "other"
+processor = "nop"
+deps_on_ok = ["after"]
+`,
+		"b.toml": `
+[[graph]]
+name = "outer"
+[[graph.vertex]]
+id = "nested"
+graph = "inner"
+successor = ["last"]
+[[graph.vertex]]
+id = "last"
+processor = "nop"
+[[graph]]
+name = "inner"
+[[graph.vertex]]
+id = "x"
+processor = "nop"
+successor = ["y"]
+[[graph.vertex]]
+id = "y"
+processor = "nop"
+`,
+	})
+	a, err := NewDAGConfigByProviders(filepath.Join(dir, "a.toml"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	b, err := NewDAGConfigByProviders(filepath.Join(dir, "b.toml"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		depth    int
+		clusters []*DAGConfig
+		labels   []string
+	}{
+		{0, []*DAGConfig{b}, nil},
+		{1, []*DAGConfig{b}, []string{"b.toml::outer"}},
+		{2, []*DAGConfig{b}, []string{"b.toml::outer", "b.toml::inner"}},
+		{8, []*DAGConfig{b}, []string{"b.toml::outer", "b.toml::inner"}},
+		// sub graphs of unknown clusters are not inlined
+		{2, nil, nil},
+	}
+	for _, test := range tests {
+		opt := &RenderOptions{SubGraphDepth: test.depth, Clusters: test.clusters}
+		dot, err := a.Render("dot", opt)
+		if nil != err {
+			t.Fatal(err)
+		}
+		var labels []string
+		for _, line := range strings.Split(string(dot), "\n") {
+			if strings.HasPrefix(line, "    label = \"b.toml::") {
+				labels = append(labels, strings.Trim(strings.TrimPrefix(line, "    label = "), "\";"))
+			}
+		}
+		if !reflect.DeepEqual(labels, test.labels) {
+			t.Errorf("depth:%d: expect inlined sub graphs:%v, but got %v", test.depth, test.labels, labels)
+		}
+		// the output is reproducible
+		for i := 0; i < 5; i++ {
+			if again, _ := a.Render("dot", opt); string(again) != string(dot) {
+				t.Fatalf("depth:%d: unstable dot output:\n%s\n%s", test.depth, dot, again)
+			}
+		}
+	}
+}
+
+func TestRenderFocusJSON(t *testing.T) {
+	cfg := mustBuild(t, testChainOps, testChainScript+`
+[[graph]]
+name = "second"
+[[graph.vertex]]
+processor = "gen"
+[[graph.vertex]]
+processor = "use"
+`)
+	tests := []struct {
+		opt    RenderOptions
+		graphs []string
+		ids    []string
+		elided int
+	}{
+		{RenderOptions{}, []string{"main", "second"}, []string{"gen", "other", "sink", "use"}, 0},
+		{RenderOptions{Graph: "second"}, []string{"second"}, []string{"gen", "use"}, 0},
+		{RenderOptions{FocusVertex: "use", FocusDirection: FOCUS_UPSTREAM}, []string{"main", "second"}, []string{"gen", "use"}, 2},
+	}
+	for _, test := range tests {
+		out, err := cfg.Render("json", &test.opt)
+		if nil != err {
+			t.Fatal(err)
+		}
+		var m ClusterModel
+		if err := json.Unmarshal(out, &m); nil != err {
+			t.Fatal(err)
+		}
+		var graphs []string
+		for _, g := range m.Graphs {
+			graphs = append(graphs, g.Name)
+		}
+		if !reflect.DeepEqual(graphs, test.graphs) {
+			t.Errorf("%+v: expect graphs:%v, but got %v", test.opt, test.graphs, graphs)
+			continue
+		}
+		var ids []string
+		for _, v := range m.Graphs[0].Vertexs {
+			ids = append(ids, v.ID)
+			for _, dep := range v.Deps {
+				if !strings.Contains(strings.Join(test.ids, ","), dep.ID) {
+					t.Errorf("%+v: unexpected dep:%s of vertex:%s out of focus", test.opt, dep.ID, v.ID)
+				}
+			}
+		}
+		if !reflect.DeepEqual(ids, test.ids) || m.Graphs[0].Elided != test.elided {
+			t.Errorf("%+v: expect vertexs:%v & %d elided, but got %v & %d", test.opt, test.ids, test.elided, ids, m.Graphs[0].Elided)
+		}
+	}
+}
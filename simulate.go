@@ -0,0 +1,170 @@
+package didagle
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MAX_SIMULATION_DECISIONS limits the decisions enumerated by the simulator, 2^n runs are simulated.
+const MAX_SIMULATION_DECISIONS = 16
+
+// SimulationRun is the simulated execution under one combination of outcomes, generated vertices are not listed.
+type SimulationRun struct {
+	Outcomes map[string]bool `json:"outcomes"`
+	Executed []string        `json:"executed"`
+	Skipped  []string        `json:"skipped"`
+	// Stopped are executed vertices reaching '__STOP__'.
+	Stopped []string `json:"stopped"`
+	// MissingInputs are inputs like 'vertex:data' of executed vertices whose producers are skipped,
+	// the data is absent in execution, aggregate inputs only miss the entries of skipped producers.
+	MissingInputs []string `json:"missing_inputs,omitempty"`
+}
+
+type SimulationReport struct {
+	Graph string `json:"graph"`
+	// Decisions are the outcome keys enumerated since not supplied.
+	Decisions []string         `json:"decisions"`
+	Runs      []*SimulationRun `json:"runs"`
+	// Unreachable are vertices skipped in every run.
+	Unreachable []string `json:"unreachable"`
+}
+
+// decisions returns the outcome keys of the graph: cond/expect expressions, expect_config names, and ids of
+// vertices whose ok/err result is expected by 'if'/'else'/'deps_on_ok'/'deps_on_err'.
+func (p *Graph) decisions() []string {
+	keys := make(map[string]bool)
+	for _, v := range p.vertexMap {
+		if len(v.Cond) > 0 {
+			keys[v.Cond] = true
+			continue
+		}
+		if len(v.ExpectConfig) > 0 {
+			keys[configName(v.ExpectConfig)] = true
+		}
+		for _, successor := range v.successorVertex {
+			if successor.depsResults[v.ID] != V_RESULT_ALL {
+				keys[v.ID] = true
+			}
+		}
+	}
+	var decisions []string
+	for key := range keys {
+		decisions = append(decisions, key)
+	}
+	sort.Strings(decisions)
+	return decisions
+}
+
+func configName(name string) string {
+	if len(name) > 0 && name[0] == '!' {
+		return name[1:]
+	}
+	return name
+}
+
+// simulate runs the graph with the outcomes, vertices without outcome succeed.
+func (p *Graph) simulate(plan *GraphPlan, outcomes map[string]bool) *SimulationRun {
+	run := &SimulationRun{Outcomes: outcomes, Executed: []string{}, Skipped: []string{}, Stopped: []string{}}
+	results := make(map[string]*VertexResult)
+	for _, stage := range plan.Stages {
+		for _, id := range stage {
+			v := p.vertexMap[id]
+			r := &VertexResult{Graph: p.Name, ID: id, Result: V_RESULT_OK}
+			results[id] = r
+			if !depsMatched(v, results) {
+				r.skip()
+			} else if len(v.ExpectConfig) > 0 && outcomes[configName(v.ExpectConfig)] == (v.ExpectConfig[0] == '!') {
+				r.skip()
+			} else if len(v.Cond) > 0 && !outcomes[v.Cond] {
+				r.Result = V_RESULT_ERR
+			} else if ok, exist := outcomes[v.ID]; exist && len(v.Cond) == 0 && !ok {
+				r.Result = V_RESULT_ERR
+			}
+			if v.isGenerated {
+				continue
+			}
+			if r.Skipped {
+				run.Skipped = append(run.Skipped, id)
+				continue
+			}
+			run.Executed = append(run.Executed, id)
+			if v.isSuccessorsEmpty() {
+				run.Stopped = append(run.Stopped, id)
+			}
+			run.MissingInputs = append(run.MissingInputs, p.missingInputs(v, results)...)
+		}
+	}
+	sort.Strings(run.Executed)
+	sort.Strings(run.Skipped)
+	sort.Strings(run.Stopped)
+	sort.Strings(run.MissingInputs)
+	return run
+}
+
+// Simulate dry runs the graph without operators, outcomes are keyed by cond/expect expression, config_setting
+// name or vertex id(false means the vertex fails). Decisions without supplied outcome are enumerated.
+func (p *DAGConfig) Simulate(graph string, outcomes map[string]bool) (*SimulationReport, error) {
+	g := p.graph.getGraphByName(graph)
+	if nil == g {
+		return nil, fmt.Errorf("No graph:%s found in cluster:%s", graph, p.graph.name)
+	}
+	plan, err := g.plan()
+	if nil != err {
+		return nil, err
+	}
+	decisions := g.decisions()
+	known := make(map[string]bool)
+	report := &SimulationReport{Graph: graph, Decisions: []string{}, Unreachable: []string{}}
+	for _, key := range decisions {
+		known[key] = true
+		if _, exist := outcomes[key]; !exist {
+			report.Decisions = append(report.Decisions, key)
+		}
+	}
+	for key := range outcomes {
+		if !known[key] && nil == g.getVertexById(key) {
+			return nil, fmt.Errorf("Unknown outcome:%s in graph:%s", key, graph)
+		}
+	}
+	if len(report.Decisions) > MAX_SIMULATION_DECISIONS {
+		return nil, fmt.Errorf("Too many decisions:%d to enumerate in graph:%s, max %d", len(report.Decisions), graph, MAX_SIMULATION_DECISIONS)
+	}
+	reached := make(map[string]bool)
+	for mask := 0; mask < 1<<uint(len(report.Decisions)); mask++ {
+		combination := make(map[string]bool)
+		for key, ok := range outcomes {
+			combination[key] = ok
+		}
+		for i, key := range report.Decisions {
+			combination[key] = mask&(1<<uint(i)) == 0
+		}
+		run := g.simulate(plan, combination)
+		for _, id := range run.Executed {
+			reached[id] = true
+		}
+		report.Runs = append(report.Runs, run)
+	}
+	for _, v := range g.sortedVertexs() {
+		if !v.isGenerated && !reached[v.ID] {
+			report.Unreachable = append(report.Unreachable, v.ID)
+		}
+	}
+	return report, nil
+}
+
+func (p *Graph) missingInputs(v *Vertex, results map[string]*VertexResult) []string {
+	var missing []string
+	for _, input := range v.Input {
+		ids := input.Aggregate
+		if len(ids) == 0 {
+			ids = []string{input.ID}
+		}
+		for _, id := range ids {
+			producer := p.getVertexByData(id)
+			if nil != producer && producer != v && results[producer.ID].Skipped {
+				missing = append(missing, v.ID+":"+id)
+			}
+		}
+	}
+	return missing
+}
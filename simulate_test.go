@@ -0,0 +1,123 @@
+package didagle
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSimulateScript = `
+[[config_setting]]
+name = "on"
+cond = "x==1"
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "check"
+cond = "score > 1"
+if = ["a"]
+else = ["b"]
+[[graph.vertex]]
+id = "a"
+processor = "p"
+output = [{ field = "out", id = "out" }]
+[[graph.vertex]]
+id = "b"
+processor = "p"
+[[graph.vertex]]
+id = "c"
+processor = "p"
+expect_config = "on"
+deps = ["check"]
+[[graph.vertex]]
+id = "use"
+processor = "p"
+input = [{ field = "out", id = "out" }]
+[[graph.vertex]]
+id = "dead"
+processor = "p"
+deps_on_ok = ["a", "b"]
+`
+
+func TestSimulateEnumeration(t *testing.T) {
+	cfg := mustBuild(t, "", testSimulateScript)
+	report, err := cfg.Simulate("g", nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "on", "score > 1"}; !reflect.DeepEqual(report.Decisions, want) {
+		t.Fatalf("Expect decisions:%v, but got %v", want, report.Decisions)
+	}
+	if len(report.Runs) != 16 {
+		t.Fatalf("Expect 16 runs, but got %d", len(report.Runs))
+	}
+	combinations := make(map[string]bool)
+	for _, run := range report.Runs {
+		combinations[fmt.Sprint(run.Outcomes)] = true
+		executed := make(map[string]bool)
+		for _, id := range run.Executed {
+			executed[id] = true
+		}
+		if executed["a"] == executed["b"] {
+			t.Errorf("Expect exactly one of a/b executed with outcomes:%v, but executed:%v", run.Outcomes, run.Executed)
+		}
+		if executed["c"] != run.Outcomes["on"] {
+			t.Errorf("Expect c executed:%v with outcomes:%v, but executed:%v", run.Outcomes["on"], run.Outcomes, run.Executed)
+		}
+	}
+	if len(combinations) != 16 {
+		t.Errorf("Expect 16 distinct combinations, but got %d", len(combinations))
+	}
+	if want := []string{"dead"}; !reflect.DeepEqual(report.Unreachable, want) {
+		t.Errorf("Expect unreachable:%v, but got %v", want, report.Unreachable)
+	}
+
+	// supplied outcomes are not enumerated
+	report, err = cfg.Simulate("g", map[string]bool{"score > 1": false, "on": false, "a": true, "b": true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(report.Decisions) != 0 || len(report.Runs) != 1 {
+		t.Fatalf("Expect 1 run without decisions, but got decisions:%v runs:%d", report.Decisions, len(report.Runs))
+	}
+	run := report.Runs[0]
+	expect := &SimulationRun{
+		Outcomes:      run.Outcomes,
+		Executed:      []string{"b", "check", "use"},
+		Skipped:       []string{"a", "c", "dead"},
+		Stopped:       []string{"use"},
+		MissingInputs: []string{"use:out"},
+	}
+	if !reflect.DeepEqual(run, expect) {
+		t.Errorf("Expect run:%+v, but got %+v", expect, run)
+	}
+	if want := []string{"a", "c", "dead"}; !reflect.DeepEqual(report.Unreachable, want) {
+		t.Errorf("Expect unreachable:%v, but got %v", want, report.Unreachable)
+	}
+}
+
+func TestSimulateErrors(t *testing.T) {
+	cfg := mustBuild(t, "", testSimulateScript)
+	if _, err := cfg.Simulate("nope", nil); nil == err || !strings.Contains(err.Error(), "No graph:nope found") {
+		t.Errorf("Expect no graph error, but got:%v", err)
+	}
+	if _, err := cfg.Simulate("g", map[string]bool{"typo": true}); nil == err || !strings.Contains(err.Error(), "Unknown outcome:typo in graph:g") {
+		t.Errorf("Expect unknown outcome error, but got:%v", err)
+	}
+	// vertex ids are valid outcomes even if no successor expects their result
+	if _, err := cfg.Simulate("g", map[string]bool{"use": false}); nil != err {
+		t.Errorf("Unexpected err:%v", err)
+	}
+
+	var script strings.Builder
+	script.WriteString("[[graph]]\nname = \"g\"\n[[graph.vertex]]\nid = \"sink\"\nprocessor = \"p\"\n")
+	for i := 0; i <= MAX_SIMULATION_DECISIONS; i++ {
+		fmt.Fprintf(&script, "[[graph.vertex]]\nid = \"c%d\"\ncond = \"x == %d\"\nsuccessor = [\"sink\"]\n", i, i)
+	}
+	cfg = mustBuild(t, "", script.String())
+	_, err := cfg.Simulate("g", nil)
+	if want := fmt.Sprintf("Too many decisions:%d", MAX_SIMULATION_DECISIONS+1); nil == err || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expect too many decisions error, but got:%v", err)
+	}
+}
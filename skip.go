@@ -0,0 +1,34 @@
+package didagle
+
+// Skip propagation model shared by the executor and the simulator.
+//
+// Every vertex finishes with one of V_RESULT_OK, V_RESULT_ERR or V_RESULT_SKIP. A vertex is skipped(not
+// executed) if any of its deps does not match the expectation of the dependency edge:
+//
+//	dep result \ expect | ok('if'/'deps_on_ok'/required input) | err('else'/'deps_on_err') | all('deps'/'successor'/input)
+//	V_RESULT_OK         | run                                  | skip                      | run
+//	V_RESULT_ERR        | skip                                 | run                       | run
+//	V_RESULT_SKIP       | skip                                 | skip                      | run
+//
+// So a skip propagates along 'ok'/'err' edges only, 'all' edges just wait for the dep to finish. A dep
+// depended multiple times keeps the 'ok'/'err' expectation over 'all', expecting both 'ok' & 'err' on the same
+// dep is a build error. A vertex with 'expect'/'expect_config' is also skipped if the cond is false, and all
+// vertices not started are skipped once the execution is cancelled. Skipped vertices never produce data: a
+// plain input produced by a skipped vertex is absent(the zero value for go operators), an aggregate input only
+// collects the data of producers not skipped. Sub graph vertices fail if any vertex of the sub graph fails,
+// skipped ones don't count.
+
+func (p *VertexResult) skip() {
+	p.Skipped = true
+	p.Result = V_RESULT_SKIP
+}
+
+// depsMatched returns true if the vertex should run according to the results of its deps.
+func depsMatched(v *Vertex, results map[string]*VertexResult) bool {
+	for id, expected := range v.depsResults {
+		if expected != V_RESULT_ALL && expected&results[id].Result == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package didagle
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var testSkipResults = []struct {
+	name     string
+	result   int
+	outcomes map[string]bool
+}{
+	{"ok", V_RESULT_OK, map[string]bool{"x": true, "d": true}},
+	{"err", V_RESULT_ERR, map[string]bool{"x": true, "d": false}},
+	{"skip", V_RESULT_SKIP, map[string]bool{"x": false, "d": true}},
+}
+
+var testSkipExpects = []struct {
+	key    string
+	expect int
+	// run per dep result ok/err/skip
+	run [3]bool
+}{
+	{"deps_on_ok", V_RESULT_OK, [3]bool{true, false, false}},
+	{"deps_on_err", V_RESULT_ERR, [3]bool{false, true, false}},
+	{"deps", V_RESULT_ALL, [3]bool{true, true, true}},
+}
+
+func TestDepsMatchedMatrix(t *testing.T) {
+	for _, expect := range testSkipExpects {
+		for i, result := range testSkipResults {
+			v := &Vertex{ID: "v", depsResults: map[string]int{"d": expect.expect}}
+			results := map[string]*VertexResult{"d": {ID: "d", Result: result.result}}
+			if got := depsMatched(v, results); got != expect.run[i] {
+				t.Errorf("dep %s, expect %s: run:%v, want:%v", result.name, expectString(expect.expect), got, expect.run[i])
+			}
+		}
+	}
+}
+
+func TestSimulateSkipMatrix(t *testing.T) {
+	for _, expect := range testSkipExpects {
+		script := fmt.Sprintf(`
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "x"
+processor = "p"
+[[graph.vertex]]
+id = "d"
+processor = "p"
+deps_on_ok = ["x"]
+[[graph.vertex]]
+id = "v"
+processor = "p"
+%s = ["d"]
+`, expect.key)
+		cfg := mustBuild(t, "", script)
+		for i, result := range testSkipResults {
+			report, err := cfg.Simulate("g", result.outcomes)
+			if nil != err {
+				t.Fatalf("Failed to simulate with err:%v", err)
+			}
+			if len(report.Runs) != 1 {
+				t.Fatalf("Expect 1 run, but got %d", len(report.Runs))
+			}
+			run := report.Runs[0]
+			got := strings.Contains(strings.Join(run.Executed, ","), "v")
+			if got != expect.run[i] {
+				t.Errorf("dep %s, %s: run:%v, want:%v, executed:%v", result.name, expect.key, got, expect.run[i], run.Executed)
+			}
+		}
+	}
+}
+
+func TestDependExpectations(t *testing.T) {
+	tests := []struct {
+		deps     string
+		expected int
+		conflict bool
+	}{
+		{`deps = ["d"]
+deps_on_ok = ["d"]`, V_RESULT_OK, false},
+		{`deps_on_err = ["d"]
+deps = ["d"]`, V_RESULT_ERR, false},
+		{`deps_on_ok = ["d"]
+deps_on_err = ["d"]`, 0, true},
+		{`input = [{ field = "out", required = true }]
+deps_on_err = ["d"]`, 0, true},
+		{`input = [{ field = "out", required = true }]
+deps_on_ok = ["d"]`, V_RESULT_OK, false},
+	}
+	for _, test := range tests {
+		script := `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "d"
+processor = "p"
+output = [{ field = "out" }]
+[[graph.vertex]]
+id = "v"
+processor = "p"
+` + test.deps
+		cfg, err := NewDAGConfigByContent("", script)
+		if test.conflict {
+			if nil == err || !strings.Contains(err.Error(), "Conflict expectations") {
+				t.Errorf("%s: expect conflict error, but got:%v", test.deps, err)
+			}
+			continue
+		}
+		if nil != err {
+			t.Fatalf("%s: failed to build with err:%v", test.deps, err)
+		}
+		v := cfg.graph.getGraphByName("g").getVertexById("v")
+		if v.depsResults["d"] != test.expected {
+			t.Errorf("%s: expect:%d, but got:%d", test.deps, test.expected, v.depsResults["d"])
+		}
+	}
+	// 'if' & 'else' of the dep to the same successor
+	_, err := NewDAGConfigByContent("", `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "d"
+processor = "p"
+if = ["v"]
+else = ["v"]
+[[graph.vertex]]
+id = "v"
+processor = "p"
+`)
+	if nil == err || !strings.Contains(err.Error(), "Conflict expectations") {
+		t.Errorf("Expect conflict error, but got:%v", err)
+	}
+}
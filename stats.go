@@ -0,0 +1,60 @@
+package didagle
+
+// GraphStats holds the size metrics of one graph.
+type GraphStats struct {
+	Name            string `json:"name"`
+	Vertexs         int    `json:"vertexs"`
+	Edges           int    `json:"edges"`
+	CondVertexs     int    `json:"cond_vertexs"`
+	SubGraphVertexs int    `json:"sub_graph_vertexs"`
+	Datas           int    `json:"datas"`
+	Stages          int    `json:"stages"`
+}
+
+// ClusterStats holds the size metrics of a graph cluster.
+type ClusterStats struct {
+	Name           string         `json:"name"`
+	ConfigSettings int            `json:"config_settings"`
+	Graphs         []GraphStats   `json:"graphs"`
+	Processors     map[string]int `json:"processors"`
+}
+
+func (p *Graph) stats() GraphStats {
+	stats := GraphStats{
+		Name:    p.Name,
+		Vertexs: len(p.vertexMap),
+		Datas:   len(p.dataMapping),
+	}
+	for _, v := range p.vertexMap {
+		stats.Edges += len(v.depsResults)
+		if len(v.Cond) > 0 {
+			stats.CondVertexs++
+		}
+		if len(v.Graph) > 0 {
+			stats.SubGraphVertexs++
+		}
+	}
+	if plan, err := p.plan(); nil == err {
+		stats.Stages = len(plan.Stages)
+	}
+	return stats
+}
+
+// Stats returns the size metrics of the cluster and the usage count of every processor.
+func (p *DAGConfig) Stats() *ClusterStats {
+	stats := &ClusterStats{
+		Name:           p.graph.name,
+		ConfigSettings: len(p.graph.ConfigSetting),
+		Processors:     make(map[string]int),
+	}
+	for i := range p.graph.Graph {
+		g := &p.graph.Graph[i]
+		stats.Graphs = append(stats.Graphs, g.stats())
+		for _, v := range g.vertexMap {
+			if len(v.Processor) > 0 {
+				stats.Processors[v.Processor]++
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,60 @@
+package didagle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	cfg := mustBuild(t, "", `
+[[config_setting]]
+name = "on"
+cond = "x==1"
+[[config_setting]]
+name = "off"
+cond = "x==0"
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "gen"
+processor = "gen"
+output = [{ field = "x" }, { field = "y" }]
+successor = ["check"]
+[[graph.vertex]]
+id = "check"
+cond = "x > 1"
+if = ["use"]
+else = ["call"]
+[[graph.vertex]]
+id = "use"
+processor = "use"
+input = [{ field = "x" }]
+[[graph.vertex]]
+id = "call"
+graph = "side"
+[[graph.vertex]]
+id = "sink"
+processor = "use"
+deps = ["use", "call", "gen"]
+[[graph]]
+name = "side"
+[[graph.vertex]]
+processor = "gen"
+successor = ["use"]
+[[graph.vertex]]
+processor = "use"
+`)
+	// the input 'x' of use is an edge from gen besides the 'if' from check
+	want := &ClusterStats{
+		Name:           "DefaultCluster",
+		ConfigSettings: 2,
+		Graphs: []GraphStats{
+			{Name: "main", Vertexs: 5, Edges: 7, CondVertexs: 1, SubGraphVertexs: 1, Datas: 2, Stages: 4},
+			{Name: "side", Vertexs: 2, Edges: 1, Stages: 2},
+		},
+		Processors: map[string]int{"gen": 2, "use": 3},
+	}
+	if got := cfg.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expect stats:%+v, but got %+v", want, got)
+	}
+}
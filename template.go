@@ -0,0 +1,460 @@
+package didagle
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const MAX_TEMPLATE_DEPTH = 8
+
+// GraphTemplate is a parameterized list of vertices, '${param}' in any string of the vertices is substituted
+// by the params of the instantiating vertex or graph.
+type GraphTemplate struct {
+	Name   string   `toml:"name"`
+	Params []string `toml:"params"`
+	Vertex []Vertex `toml:"vertex"`
+}
+
+// includeFiles includes the files relative to dir, every included file must be under the root script directory.
+func (p *GraphCluster) includeFiles(root string, dir string, files []string, visited map[string]bool) error {
+	for _, file := range files {
+		if len(root) == 0 {
+			return newBuildError("", "", "include", file, "Can NOT include script:%s in script loaded without directory", file)
+		}
+		if filepath.IsAbs(file) {
+			return newBuildError("", "", "include", file, "Can NOT include script:%s by absolute path", file)
+		}
+		path := filepath.Join(dir, file)
+		if rel, err := filepath.Rel(root, path); nil != err || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return newBuildError("", "", "include", file, "Can NOT include script:%s outside the script directory", file)
+		}
+		abs, err := filepath.Abs(path)
+		if nil != err {
+			return err
+		}
+		if visited[abs] {
+			continue
+		}
+		visited[abs] = true
+		content, err := ioutil.ReadFile(path)
+		if nil != err {
+			return newBuildError("", "", "include", file, "Failed to include script:%s with err:%v", file, err)
+		}
+		var included GraphCluster
+		md, err := toml.Decode(string(content), &included)
+		if nil != err {
+			return newBuildError("", "", "include", file, "Failed to parse included script:%s with err:%v", file, err)
+		}
+		severity := DIAG_WARNING
+		if p.StrictDsl {
+			severity = DIAG_ERROR
+		}
+		if diags := checkUnknownKeys(string(content), md, &included, severity); len(diags) > 0 {
+			if p.StrictDsl {
+				return newBuildError("", "", "include", file, "Invalid included script:%s:%v", file, &ScriptError{Diagnostics: diags})
+			}
+			for _, diag := range diags {
+				diag.Message = file + ": " + diag.Message
+				p.includeWarnings = append(p.includeWarnings, diag)
+			}
+		}
+		p.ConfigSetting = append(p.ConfigSetting, included.ConfigSetting...)
+		p.Graph = append(p.Graph, included.Graph...)
+		p.Template = append(p.Template, included.Template...)
+		if err := p.includeFiles(root, filepath.Dir(path), included.Include, visited); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadIncludes imports config_setting/graph/template from included scripts recursively, each script is included once.
+func (p *GraphCluster) loadIncludes() error {
+	if err := p.includeFiles(p.dir, p.dir, p.Include, make(map[string]bool)); nil != err {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, c := range p.ConfigSetting {
+		if names[c.Name] {
+			err := newBuildError("", "", "config_setting.name", c.Name, "Duplicate config_setting name:%s", c.Name)
+			err.Duplicate = true
+			return err
+		}
+		names[c.Name] = true
+	}
+	return nil
+}
+
+// copySubstitute deep copies the exported fields of the value with the replacer applied on every string.
+func copySubstitute(src reflect.Value, r *strings.Replacer) reflect.Value {
+	dst := reflect.New(src.Type()).Elem()
+	switch src.Kind() {
+	case reflect.String:
+		dst.SetString(r.Replace(src.String()))
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).IsExported() {
+				dst.Field(i).Set(copySubstitute(src.Field(i), r))
+			}
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return dst
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			dst.Index(i).Set(copySubstitute(src.Index(i), r))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return dst
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), copySubstitute(iter.Value(), r))
+		}
+	case reflect.Interface:
+		if src.IsNil() {
+			return dst
+		}
+		dst.Set(copySubstitute(src.Elem(), r))
+	case reflect.Ptr:
+		if src.IsNil() {
+			return dst
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		dst.Elem().Set(copySubstitute(src.Elem(), r))
+	default:
+		dst.Set(src)
+	}
+	return dst
+}
+
+func findUnresolvedParam(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		if idx := strings.Index(v.String(), "${"); idx >= 0 {
+			return v.String()
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				if s := findUnresolvedParam(v.Field(i)); len(s) > 0 {
+					return s
+				}
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if s := findUnresolvedParam(v.Index(i)); len(s) > 0 {
+				return s
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if s := findUnresolvedParam(iter.Value()); len(s) > 0 {
+				return s
+			}
+		}
+	case reflect.Interface, reflect.Ptr:
+		if !v.IsNil() {
+			return findUnresolvedParam(v.Elem())
+		}
+	}
+	return ""
+}
+
+func (p *GraphTemplate) newReplacer(params map[string]interface{}) (*strings.Replacer, error) {
+	if len(p.Params) > 0 {
+		declared := make(map[string]bool)
+		for _, name := range p.Params {
+			declared[name] = true
+			if _, exist := params[name]; !exist {
+				return nil, fmt.Errorf("Template:%s missing param:%s", p.Name, name)
+			}
+		}
+		for name := range params {
+			if !declared[name] {
+				return nil, fmt.Errorf("Template:%s has no param:%s", p.Name, name)
+			}
+		}
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		pairs = append(pairs, "${"+name+"}", fmt.Sprint(params[name]))
+	}
+	return strings.NewReplacer(pairs...), nil
+}
+
+func templateVertexId(v *Vertex) string {
+	if len(v.ID) > 0 {
+		return v.ID
+	}
+	return v.Processor
+}
+
+// instantiate returns the template vertices with params substituted, vertex ids are prefixed with 'prefix_'
+// if prefix is not empty, references between the template vertices are renamed accordingly.
+func (p *GraphTemplate) instantiate(params map[string]interface{}, prefix string, from string) ([]Vertex, error) {
+	r, err := p.newReplacer(params)
+	if nil != err {
+		return nil, err
+	}
+	vertexs := make([]Vertex, 0, len(p.Vertex))
+	for i := range p.Vertex {
+		v := copySubstitute(reflect.ValueOf(p.Vertex[i]), r).Interface().(Vertex)
+		if s := findUnresolvedParam(reflect.ValueOf(v)); len(s) > 0 {
+			return nil, fmt.Errorf("Template:%s has unresolved param in '%s'", p.Name, s)
+		}
+		if len(v.expandedFrom) == 0 {
+			v.expandedFrom = from
+		}
+		vertexs = append(vertexs, v)
+	}
+	if len(prefix) == 0 {
+		return vertexs, nil
+	}
+	renames := make(map[string]string)
+	for i := range vertexs {
+		v := &vertexs[i]
+		id := templateVertexId(v)
+		if len(id) == 0 {
+			return nil, fmt.Errorf("Template:%s has vertex without 'id' or 'processor'", p.Name)
+		}
+		renames[id] = prefix + "_" + id
+		v.ID = renames[id]
+	}
+	rename := func(ids []string) []string {
+		renamed := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if newId, exist := renames[id]; exist {
+				id = newId
+			}
+			renamed = append(renamed, id)
+		}
+		return renamed
+	}
+	for i := range vertexs {
+		v := &vertexs[i]
+		v.Deps, v.DepsOnOk, v.DepsOnErr = rename(v.Deps), rename(v.DepsOnOk), rename(v.DepsOnErr)
+		v.Successor, v.SuccessorOnOk, v.SuccessorOnErr = rename(v.Successor), rename(v.SuccessorOnOk), rename(v.SuccessorOnErr)
+	}
+	return vertexs, nil
+}
+
+func containsId(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// templateInstance records entry & exit vertices of an expanded template vertex to rewrite references to it.
+type templateInstance struct {
+	entries []string
+	exits   []string
+}
+
+// wireInstance applies deps & successors of the template vertex onto the entry & exit vertices of its expansion.
+func wireInstance(instance *Vertex, vertexs []Vertex) *templateInstance {
+	inner := make(map[string]bool)
+	for i := range vertexs {
+		inner[vertexs[i].ID] = true
+	}
+	hasInner := func(ids ...[]string) bool {
+		for _, list := range ids {
+			for _, id := range list {
+				if inner[id] {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	hasPredecessor := make(map[string]bool)
+	hasSuccessor := make(map[string]bool)
+	for i := range vertexs {
+		v := &vertexs[i]
+		for _, list := range [][]string{v.Successor, v.SuccessorOnOk, v.SuccessorOnErr} {
+			for _, id := range list {
+				if inner[id] {
+					hasPredecessor[id] = true
+					hasSuccessor[v.ID] = true
+				}
+			}
+		}
+		for _, list := range [][]string{v.Deps, v.DepsOnOk, v.DepsOnErr} {
+			for _, id := range list {
+				if inner[id] {
+					hasPredecessor[v.ID] = true
+					hasSuccessor[id] = true
+				}
+			}
+		}
+		if hasInner(v.Deps, v.DepsOnOk, v.DepsOnErr) {
+			hasPredecessor[v.ID] = true
+		}
+	}
+	wired := &templateInstance{}
+	for i := range vertexs {
+		v := &vertexs[i]
+		if !hasPredecessor[v.ID] {
+			wired.entries = append(wired.entries, v.ID)
+			v.Deps = append(v.Deps, instance.Deps...)
+			v.DepsOnOk = append(v.DepsOnOk, instance.DepsOnOk...)
+			v.DepsOnErr = append(v.DepsOnErr, instance.DepsOnErr...)
+			if len(v.Expect) == 0 && len(v.ExpectConfig) == 0 {
+				v.Expect = instance.Expect
+				v.ExpectConfig = instance.ExpectConfig
+			}
+		}
+		if !hasSuccessor[v.ID] {
+			wired.exits = append(wired.exits, v.ID)
+			v.Successor = append(v.Successor, instance.Successor...)
+			v.SuccessorOnOk = append(v.SuccessorOnOk, instance.SuccessorOnOk...)
+			v.SuccessorOnErr = append(v.SuccessorOnErr, instance.SuccessorOnErr...)
+		}
+	}
+	return wired
+}
+
+// instanceKey returns the first key set on the template vertex which is not applied by wireInstance.
+func instanceKey(v *Vertex) string {
+	keys := []struct {
+		name string
+		set  bool
+	}{
+		{"processor", len(v.Processor) > 0},
+		{"input", len(v.Input) > 0},
+		{"output", len(v.Output) > 0},
+		{"args", len(v.Args) > 0},
+		{"select_args", len(v.SelectArgs) > 0},
+		{"cond", len(v.Cond) > 0},
+		{"cluster", len(v.Cluster) > 0},
+		{"graph", len(v.Graph) > 0},
+		{"start", v.Start},
+		{"fan_out", nil != v.FanOut},
+		{"timeout", len(v.Timeout) > 0},
+		{"retry", v.Retry != 0},
+		{"retry_backoff", len(v.RetryBackoff) > 0},
+		{"fallback", len(v.Fallback) > 0},
+		{"default_output", len(v.DefaultOutput) > 0},
+	}
+	for _, key := range keys {
+		if key.set {
+			return key.name
+		}
+	}
+	return ""
+}
+
+func replaceInstanceRefs(ids []string, instances map[string]*templateInstance, entry bool) []string {
+	var replaced []string
+	for _, id := range ids {
+		instance, exist := instances[id]
+		if !exist {
+			replaced = append(replaced, id)
+		} else if entry {
+			replaced = append(replaced, instance.entries...)
+		} else {
+			replaced = append(replaced, instance.exits...)
+		}
+	}
+	return replaced
+}
+
+func (p *GraphCluster) expandVertexTemplates(g *Graph, templates map[string]*GraphTemplate) error {
+	for depth := 0; ; depth++ {
+		expanded := false
+		instances := make(map[string]*templateInstance)
+		var vertexs []Vertex
+		for i := range g.Vertex {
+			v := &g.Vertex[i]
+			if len(v.Template) == 0 {
+				vertexs = append(vertexs, *v)
+				continue
+			}
+			if depth >= MAX_TEMPLATE_DEPTH {
+				return newBuildError(g.Name, v.ID, "template", "", "Graph:%s template expansion exceeds max depth:%d, recursive template:%s?", g.Name, MAX_TEMPLATE_DEPTH, v.Template)
+			}
+			tpl, exist := templates[v.Template]
+			if !exist {
+				return newBuildError(g.Name, v.ID, "template", "", "No template:%s found for vertex:%s in graph:%s", v.Template, v.ID, g.Name)
+			}
+			if len(v.ID) == 0 {
+				return newBuildError(g.Name, "", "vertex.template", v.Template, "Vertex using template:%s in graph:%s must have 'id'", v.Template, g.Name)
+			}
+			if key := instanceKey(v); len(key) > 0 {
+				return newBuildError(g.Name, v.ID, key, "", "Vertex:%s using template:%s in graph:%s can NOT config '%s'", v.ID, v.Template, g.Name, key)
+			}
+			expandedVertexs, err := tpl.instantiate(v.Params, v.ID, tpl.Name+":"+v.ID)
+			if nil != err {
+				return newBuildError(g.Name, v.ID, "params", "", "Graph:%s vertex:%s %v", g.Name, v.ID, err)
+			}
+			instances[v.ID] = wireInstance(v, expandedVertexs)
+			vertexs = append(vertexs, expandedVertexs...)
+			expanded = true
+		}
+		if !expanded {
+			return nil
+		}
+		for i := range vertexs {
+			v := &vertexs[i]
+			v.Deps = replaceInstanceRefs(v.Deps, instances, false)
+			v.DepsOnOk = replaceInstanceRefs(v.DepsOnOk, instances, false)
+			v.DepsOnErr = replaceInstanceRefs(v.DepsOnErr, instances, false)
+			v.Successor = replaceInstanceRefs(v.Successor, instances, true)
+			v.SuccessorOnOk = replaceInstanceRefs(v.SuccessorOnOk, instances, true)
+			v.SuccessorOnErr = replaceInstanceRefs(v.SuccessorOnErr, instances, true)
+		}
+		g.Vertex = vertexs
+	}
+}
+
+// expandTemplates expands graph & vertex templates into concrete vertices before graphs are built.
+func (p *GraphCluster) expandTemplates() error {
+	templates := make(map[string]*GraphTemplate)
+	for i := range p.Template {
+		tpl := &p.Template[i]
+		if _, exist := templates[tpl.Name]; exist {
+			err := newBuildError("", "", "template.name", tpl.Name, "Duplicate template name:%s", tpl.Name)
+			err.Duplicate = true
+			return err
+		}
+		templates[tpl.Name] = tpl
+	}
+	for i := range p.Graph {
+		g := &p.Graph[i]
+		if len(g.Template) > 0 {
+			tpl, exist := templates[g.Template]
+			if !exist {
+				return newBuildError(g.Name, "", "template", "", "No template:%s found for graph:%s", g.Template, g.Name)
+			}
+			vertexs, err := tpl.instantiate(g.Params, "", tpl.Name)
+			if nil != err {
+				return newBuildError(g.Name, "", "params", "", "Graph:%s %v", g.Name, err)
+			}
+			g.Vertex = append(vertexs, g.Vertex...)
+			g.Template = ""
+		}
+		if err := p.expandVertexTemplates(g, templates); nil != err {
+			return err
+		}
+	}
+	return nil
+}
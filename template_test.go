@@ -0,0 +1,179 @@
+package didagle
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testTemplateScript = `
+[[template]]
+name = "fetch"
+params = ["src"]
+[[template.vertex]]
+id = "get"
+processor = "get_${src}"
+successor = ["parse"]
+[[template.vertex]]
+id = "parse"
+processor = "parse"
+
+[[graph]]
+name = "main"
+[[graph.vertex]]
+processor = "begin"
+successor = ["user"]
+[[graph.vertex]]
+id = "user"
+template = "fetch"
+params = { src = "user" }
+if = ["end"]
+[[graph.vertex]]
+processor = "end"
+`
+
+func vertexEdges(g *Graph) []string {
+	var edges []string
+	for _, v := range g.sortedVertexs() {
+		for id := range v.successorVertex {
+			edges = append(edges, v.ID+"->"+id)
+		}
+	}
+	sort.Strings(edges)
+	return edges
+}
+
+func TestVertexTemplateExpand(t *testing.T) {
+	cfg := mustBuild(t, "", testTemplateScript)
+	g := cfg.graph.getGraphByName("main")
+	if v := g.getVertexById("user_get"); nil == v || v.Processor != "get_user" {
+		t.Fatalf("Unexpected expanded vertex:%+v", v)
+	}
+	want := []string{"begin->user_get", "user_get->user_parse", "user_parse->end"}
+	if got := vertexEdges(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected edges:%v, want:%v", got, want)
+	}
+	if expect := g.getVertexById("end").depsResults["user_parse"]; expect != V_RESULT_OK {
+		t.Errorf("Expect 'if' of the template vertex applied on the exit vertex, but got %s", expectString(expect))
+	}
+}
+
+func TestTemplateInstanceKeys(t *testing.T) {
+	for _, key := range []string{`processor = "x"`, `input = [{ field = "a" }]`, `output = [{ field = "a" }]`, `timeout = "1s"`} {
+		script := strings.Replace(testTemplateScript, `params = { src = "user" }`, `params = { src = "user" }`+"\n"+key, 1)
+		name := key[:strings.Index(key, " ")]
+		_, err := NewDAGConfigByContent("", script)
+		var buildErr *BuildError
+		if !errors.As(err, &buildErr) || buildErr.Key != name || !strings.Contains(err.Error(), "can NOT config '"+name+"'") {
+			t.Errorf("%s: unexpected err:%v", name, err)
+		}
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		old    string
+		new    string
+		errMsg string
+	}{
+		{"missing param", `params = { src = "user" }`, `params = {}`, "Template:fetch missing param:src"},
+		{"unknown param", `params = { src = "user" }`, `params = { src = "user", dst = 1 }`, "Template:fetch has no param:dst"},
+		{"no template", `template = "fetch"`, `template = "nope"`, "No template:nope found"},
+		{"recursive", `processor = "parse"`, `template = "fetch"` + "\n" + `params = { src = "x" }`, "exceeds max depth"},
+	}
+	for _, test := range tests {
+		_, err := NewDAGConfigByContent("", strings.Replace(testTemplateScript, test.old, test.new, 1))
+		if nil == err || !strings.Contains(err.Error(), test.errMsg) {
+			t.Errorf("%s: expect err:%s, but got:%v", test.name, test.errMsg, err)
+		}
+	}
+}
+
+func TestIncludeNestedAndCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"main.toml": `
+include = ["lib/templates.toml"]
+[[graph]]
+name = "main"
+[[graph.vertex]]
+id = "user"
+template = "fetch"
+params = { src = "user" }
+`,
+		// includes are relative to the including script, and each script is included once
+		"lib/templates.toml": `
+include = ["../common/config.toml"]
+[[template]]
+name = "fetch"
+params = ["src"]
+[[template.vertex]]
+id = "get"
+processor = "get_${src}"
+expect_config = "on"
+successor = ["parse"]
+[[template.vertex]]
+id = "parse"
+processor = "parse"
+`,
+		"common/config.toml": `
+include = ["../lib/templates.toml", "config.toml"]
+[[config_setting]]
+name = "on"
+cond = "x==1"
+`,
+	})
+	config := &DAGConfig{}
+	if err := config.loadTomlScriptFile(filepath.Join(dir, "main.toml")); nil != err {
+		t.Fatal(err)
+	}
+	if len(config.graph.ConfigSetting) != 1 || len(config.graph.Template) != 1 {
+		t.Errorf("Expect each script included once, but got %d config_setting & %d template", len(config.graph.ConfigSetting), len(config.graph.Template))
+	}
+	g := config.graph.getGraphByName("main")
+	if want := []string{"user_get->user_parse"}; !reflect.DeepEqual(vertexEdges(g), want) {
+		t.Errorf("Unexpected edges:%v", vertexEdges(g))
+	}
+
+	writeTestFiles(t, dir, map[string]string{"bad.toml": `include = ["nope.toml"]`})
+	err := (&DAGConfig{}).loadTomlScriptFile(filepath.Join(dir, "bad.toml"))
+	if nil == err || !strings.Contains(err.Error(), "Failed to include script:nope.toml") {
+		t.Errorf("Expect include error, but got:%v", err)
+	}
+}
+
+func TestIncludeConfined(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"secret.toml":      "",
+		"scripts/lib.toml": "",
+	})
+	if _, err := NewDAGConfigByContent("", `include = ["scripts/lib.toml"]`); nil == err || !strings.Contains(err.Error(), "loaded without directory") {
+		t.Errorf("Expect include rejected for script content, but got:%v", err)
+	}
+	tests := []struct {
+		include string
+		errMsg  string
+	}{
+		{filepath.Join(dir, "secret.toml"), "by absolute path"},
+		{"../secret.toml", "outside the script directory"},
+		{"lib/../../secret.toml", "outside the script directory"},
+		{"lib.toml", ""},
+	}
+	for _, test := range tests {
+		writeTestFiles(t, dir, map[string]string{"scripts/main.toml": fmt.Sprintf("include = [%q]", test.include)})
+		err := (&DAGConfig{}).loadTomlScriptFile(filepath.Join(dir, "scripts", "main.toml"))
+		if len(test.errMsg) == 0 {
+			if nil != err {
+				t.Errorf("%s: unexpected err:%v", test.include, err)
+			}
+		} else if nil == err || !strings.Contains(err.Error(), test.errMsg) {
+			t.Errorf("%s: expect err:%s, but got:%v", test.include, test.errMsg, err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package didagle
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const DIAG_ERROR = "error"
+const DIAG_WARNING = "warning"
+
+// Diagnostic is an error or warning located in a toml script.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Path     string `json:"path,omitempty"`
+	Position
+	Message string `json:"message"`
+}
+
+func (p Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", p.Line, p.Column, p.Severity, p.Message)
+}
+
+// ScriptError is returned when a script has located errors.
+type ScriptError struct {
+	Diagnostics []Diagnostic
+}
+
+func (p *ScriptError) Error() string {
+	msgs := make([]string, 0, len(p.Diagnostics))
+	for _, diag := range p.Diagnostics {
+		msgs = append(msgs, diag.String())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// BuildError is an error building the script, it's located at the value of the key in the vertex of the graph.
+// Key is relative to the vertex, the graph, or the root if Vertex/Graph is empty, without array indexes like
+// 'input.id'. Value is the offending value under the key, the key itself is located if empty, the last
+// occurrence of the value is located if Duplicate.
+type BuildError struct {
+	Graph     string
+	Vertex    string
+	Key       string
+	Value     string
+	Duplicate bool
+	msg       string
+}
+
+func newBuildError(graph string, vertex string, key string, value string, format string, args ...interface{}) *BuildError {
+	return &BuildError{Graph: graph, Vertex: vertex, Key: key, Value: value, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *BuildError) Error() string {
+	return p.msg
+}
+
+func (p *Vertex) buildError(key string, value string, format string, args ...interface{}) *BuildError {
+	return newBuildError(p.g.Name, p.ID, key, value, format, args...)
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = cur[j-1] + 1
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// suggestKey returns the closest candidate of the unknown key, or empty if none is close enough.
+func suggestKey(key string, candidates []string) string {
+	best := ""
+	bestDist := len(key)/3 + 2
+	for _, candidate := range candidates {
+		dist := levenshtein(key, candidate)
+		if dist < bestDist || (dist == bestDist && len(best) > 0 && candidate < best) {
+			best = candidate
+			bestDist = dist
+		}
+	}
+	return best
+}
+
+// tomlFieldName returns the key name of a struct field like the toml decoder, empty if the field is not decoded.
+func tomlFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	tag := strings.Split(field.Tag.Get("toml"), ",")[0]
+	if tag == "-" {
+		return ""
+	}
+	if len(tag) > 0 {
+		return tag
+	}
+	return field.Name
+}
+
+func tomlFieldByKey(typ reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if name := tomlFieldName(field); len(name) > 0 && name == key {
+			return field, true
+		}
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if name := tomlFieldName(field); len(name) > 0 && strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func tomlKeyCandidates(typ reflect.Type) []string {
+	var keys []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if _, tagged := field.Tag.Lookup("toml"); tagged {
+			if name := tomlFieldName(field); len(name) > 0 {
+				keys = append(keys, name)
+			}
+		}
+	}
+	return keys
+}
+
+var tomlLastIndexRegex = regexp.MustCompile(`\[\d+\]$`)
+
+// unknownKeyParent returns the struct type containing the last piece of the undecoded key, false if the key
+// is under an unknown key or a free-form map like 'args'.
+func unknownKeyParent(typ reflect.Type, key toml.Key) (reflect.Type, bool) {
+	for i, piece := range key {
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return nil, false
+		}
+		if i == len(key)-1 {
+			return typ, true
+		}
+		field, exist := tomlFieldByKey(typ, piece)
+		if !exist {
+			return nil, false
+		}
+		typ = field.Type
+	}
+	return nil, false
+}
+
+// checkUnknownKeys reports the undecoded keys of the toml script which are not decoded into the value,
+// with their positions in the script and the closest valid key as suggestion.
+func checkUnknownKeys(content string, md toml.MetaData, value interface{}, severity string) []Diagnostic {
+	var diags []Diagnostic
+	var idx *tomlIndex
+	// paths with array indexes of each key, in script order
+	var paths map[string][]string
+	for _, key := range md.Undecoded() {
+		typ, exist := unknownKeyParent(reflect.TypeOf(value), key)
+		if !exist {
+			continue
+		}
+		if nil == idx {
+			idx = newTomlIndex(content)
+			paths = make(map[string][]string)
+			for path := range idx.keys {
+				// an array of tables is keyed by its elements like 'graph[0].vertex[1]'
+				if idx.tables[path] > 0 {
+					continue
+				}
+				k := tomlIndexRegex.ReplaceAllString(path, "")
+				paths[k] = append(paths[k], path)
+			}
+			for _, ps := range paths {
+				sort.Slice(ps, func(i, j int) bool {
+					return idx.keys[ps[i]].before(idx.keys[ps[j]])
+				})
+			}
+		}
+		name := strings.Join(key, ".")
+		keyPath := name
+		// undecoded keys are in script order, so the n-th occurrence is the n-th indexed path
+		if ps := paths[name]; len(ps) > 0 {
+			keyPath = ps[0]
+			paths[name] = ps[1:]
+		}
+		parent := strings.TrimSuffix(tomlLastIndexRegex.ReplaceAllString(keyPath, ""), key[len(key)-1])
+		parent = strings.TrimSuffix(parent, ".")
+		msg := fmt.Sprintf("Unknown key '%s' in '%s'", key[len(key)-1], parent)
+		if len(parent) == 0 {
+			msg = fmt.Sprintf("Unknown key '%s'", key[len(key)-1])
+		}
+		if suggestion := suggestKey(key[len(key)-1], tomlKeyCandidates(typ)); len(suggestion) > 0 {
+			msg += fmt.Sprintf(", did you mean '%s'?", suggestion)
+		}
+		diags = append(diags, Diagnostic{
+			Severity: severity,
+			Path:     keyPath,
+			Position: idx.keyPosition(keyPath),
+			Message:  msg,
+		})
+	}
+	return diags
+}
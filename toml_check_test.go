@@ -0,0 +1,100 @@
+package didagle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testUnknownKeyScript = `
+strict = true
+[[graph]]
+name = "g"
+[[graph.vertex]]
+processor = "a"
+output = [{ field = "x" }, { field = "y" }]
+args = { x = { y = 1 } }
+[[graph.vertex]]
+processor = "b"
+input = [{ field = "x" }, { field = "y", feild = "y" }]
+[[graph.vertx]]
+id = "c"
+[[graph]]
+name = "h"
+[[graph.vertex]]
+processor = "a"
+successor = ["b"]
+[[graph.vertex]]
+procesor = "b"
+id = "b"
+`
+
+func TestUnknownKeys(t *testing.T) {
+	cfg := mustBuild(t, "", testUnknownKeyScript)
+	var got []string
+	for _, diag := range cfg.Warnings() {
+		got = append(got, diag.String())
+	}
+	want := []string{
+		"2:1: warning: Unknown key 'strict'",
+		"11:42: warning: Unknown key 'feild' in 'graph[0].vertex[1].input[1]', did you mean 'field'?",
+		"12:1: warning: Unknown key 'vertx' in 'graph[0]', did you mean 'vertex'?",
+		"20:1: warning: Unknown key 'procesor' in 'graph[1].vertex[1]', did you mean 'processor'?",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected warnings:\n%q\nwant:\n%q", got, want)
+	}
+
+	_, err := NewDAGConfigByContent("", "strict_dsl = true\n"+testUnknownKeyScript)
+	var scriptErr *ScriptError
+	if !errors.As(err, &scriptErr) || len(scriptErr.Diagnostics) != 4 || scriptErr.Diagnostics[0].Severity != DIAG_ERROR {
+		t.Errorf("Expect script error with 4 diagnostics, but got:%v", err)
+	}
+}
+
+func TestUnknownKeysInclude(t *testing.T) {
+	dir := t.TempDir()
+	included := "[[graph]]\nname = \"sub\"\n[[graph.vertex]]\nprocessor = \"a\"\nsuccessor = [\"b\"]\nretyr = 1\n[[graph.vertex]]\nprocessor = \"b\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "sub.toml"), []byte(included), 0644); nil != err {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.toml")
+	if err := os.WriteFile(main, []byte("include = [\"sub.toml\"]\n"), 0644); nil != err {
+		t.Fatal(err)
+	}
+	config := &DAGConfig{}
+	if err := config.loadTomlScriptFile(main); nil != err {
+		t.Fatal(err)
+	}
+	want := "6:1: warning: sub.toml: Unknown key 'retyr' in 'graph[0].vertex[0]', did you mean 'retry'?"
+	if warnings := config.Warnings(); len(warnings) != 1 || warnings[0].String() != want {
+		t.Errorf("Unexpected warnings:%v", warnings)
+	}
+}
+
+func TestShippedExamples(t *testing.T) {
+	scripts, _ := filepath.Glob(filepath.Join("cmd", "example*.toml"))
+	if len(scripts) == 0 {
+		t.Fatalf("No shipped examples found")
+	}
+	for _, script := range scripts {
+		cfg, err := NewDAGConfigByFile(filepath.Join("cmd", "all_processors.json"), script)
+		if nil != err {
+			t.Errorf("%s: failed to build with err:%v", script, err)
+			continue
+		}
+		if warnings := cfg.Warnings(); len(warnings) > 0 {
+			t.Errorf("%s: unexpected warnings:%v", script, warnings)
+		}
+	}
+	// unknown keys are errors in strict mode, the display name is not one of them
+	cfg, err := NewDAGConfigByContent(testChainOps, "name = \"Example\"\n"+testChainScript)
+	if nil != err {
+		t.Fatalf("Expect strict script with name built, but got err:%v", err)
+	}
+	if cfg.graph.Name != "Example" || cfg.Name() != "DefaultCluster" || len(cfg.Warnings()) > 0 {
+		t.Errorf("Unexpected name:%s cluster:%s warnings:%v", cfg.graph.Name, cfg.Name(), cfg.Warnings())
+	}
+}
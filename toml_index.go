@@ -0,0 +1,324 @@
+package didagle
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is a 1-based line & column in a toml script.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// tomlIndex maps key paths like 'graph[1].vertex[0].deps[2]' to their positions in the script,
+// it's built by a tolerant scanner so an index is available even for scripts with decode errors.
+type tomlIndex struct {
+	keys   map[string]Position
+	values map[string]Position
+	// ends of values, exclusive
+	ends map[string]Position
+	// value text of scalar values, strings unquoted
+	texts map[string]string
+	// counters of array of tables, keyed by the path without index
+	tables map[string]int
+}
+
+type tomlScanner struct {
+	src  string
+	pos  int
+	line int
+	col  int
+	idx  *tomlIndex
+}
+
+func newTomlIndex(src string) *tomlIndex {
+	idx := &tomlIndex{
+		keys:   make(map[string]Position),
+		values: make(map[string]Position),
+		ends:   make(map[string]Position),
+		texts:  make(map[string]string),
+		tables: make(map[string]int),
+	}
+	s := &tomlScanner{src: src, line: 1, col: 1, idx: idx}
+	s.scan()
+	return idx
+}
+
+func joinTomlPath(parent string, key string) string {
+	if len(parent) == 0 {
+		return key
+	}
+	return parent + "." + key
+}
+
+// keyPosition returns the position of the key path, or the position of its nearest parent.
+func (p *tomlIndex) keyPosition(path string) Position {
+	for len(path) > 0 {
+		if pos, exist := p.keys[path]; exist {
+			return pos
+		}
+		if pos, exist := p.values[path]; exist {
+			return pos
+		}
+		idx := strings.LastIndexAny(path, ".[")
+		if idx <= 0 {
+			break
+		}
+		path = path[:idx]
+	}
+	return Position{Line: 1, Column: 1}
+}
+
+func (p Position) before(other Position) bool {
+	return p.Line < other.Line || (p.Line == other.Line && p.Column < other.Column)
+}
+
+// valueAt returns the path of the innermost value containing the position, a position right after
+// the value is contained too, so the value being typed is found.
+func (p *tomlIndex) valueAt(pos Position) string {
+	found := ""
+	var foundStart Position
+	for path, start := range p.values {
+		end, exist := p.ends[path]
+		if !exist || pos.before(start) || end.before(pos) {
+			continue
+		}
+		if len(found) == 0 || foundStart.before(start) || (foundStart == start && len(path) > len(found)) {
+			found = path
+			foundStart = start
+		}
+	}
+	return found
+}
+
+func (s *tomlScanner) eof() bool {
+	return s.pos >= len(s.src)
+}
+
+func (s *tomlScanner) peek() byte {
+	if s.eof() {
+		return 0
+	}
+	return s.src[s.pos]
+}
+
+func (s *tomlScanner) next() byte {
+	c := s.src[s.pos]
+	s.pos++
+	if c == '\n' {
+		s.line++
+		s.col = 1
+	} else {
+		s.col++
+	}
+	return c
+}
+
+func (s *tomlScanner) position() Position {
+	return Position{Line: s.line, Column: s.col}
+}
+
+func (s *tomlScanner) skipSpaces(newline bool) {
+	for !s.eof() {
+		c := s.peek()
+		if c == ' ' || c == '\t' || c == '\r' || (newline && c == '\n') {
+			s.next()
+		} else if c == '#' {
+			for !s.eof() && s.peek() != '\n' {
+				s.next()
+			}
+		} else {
+			return
+		}
+	}
+}
+
+func (s *tomlScanner) skipLine() {
+	for !s.eof() && s.next() != '\n' {
+	}
+}
+
+// readString reads a basic/literal/multi line string starting at the quote, returns the unquoted content.
+func (s *tomlScanner) readString() string {
+	quote := s.next()
+	multi := strings.HasPrefix(s.src[s.pos:], string([]byte{quote, quote}))
+	if multi {
+		s.next()
+		s.next()
+	}
+	var b strings.Builder
+	for !s.eof() {
+		c := s.next()
+		if c == '\\' && quote == '"' && !s.eof() {
+			b.WriteByte(c)
+			b.WriteByte(s.next())
+			continue
+		}
+		if c == quote {
+			if !multi {
+				return b.String()
+			}
+			if strings.HasPrefix(s.src[s.pos:], string([]byte{quote, quote})) {
+				s.next()
+				s.next()
+				return b.String()
+			}
+		}
+		if c == '\n' && !multi {
+			return b.String()
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// readKey reads a dotted key, returns the key segments.
+func (s *tomlScanner) readKey(stop string) []string {
+	var segments []string
+	for {
+		s.skipSpaces(false)
+		if s.eof() {
+			return segments
+		}
+		c := s.peek()
+		if c == '"' || c == '\'' {
+			segments = append(segments, s.readString())
+		} else {
+			start := s.pos
+			for !s.eof() && !strings.ContainsRune(stop+". \t\n#", rune(s.peek())) {
+				s.next()
+			}
+			segments = append(segments, s.src[start:s.pos])
+		}
+		s.skipSpaces(false)
+		if s.peek() != '.' {
+			return segments
+		}
+		s.next()
+	}
+}
+
+func (s *tomlScanner) scan() {
+	table := ""
+	for {
+		s.skipSpaces(true)
+		if s.eof() {
+			return
+		}
+		if s.peek() == '[' {
+			table = s.scanHeader()
+			continue
+		}
+		pos := s.position()
+		segments := s.readKey("=")
+		if s.peek() != '=' || len(segments) == 0 {
+			s.skipLine()
+			continue
+		}
+		s.next()
+		path := table
+		for _, segment := range segments {
+			path = joinTomlPath(path, segment)
+		}
+		s.idx.keys[path] = pos
+		s.scanValue(path)
+		s.skipLine()
+	}
+}
+
+// scanHeader scans '[a.b]' or '[[a.b]]', returns the table path with array indexes.
+func (s *tomlScanner) scanHeader() string {
+	pos := s.position()
+	s.next()
+	isArray := s.peek() == '['
+	if isArray {
+		s.next()
+	}
+	segments := s.readKey("]")
+	s.skipLine()
+	path := ""
+	for i, segment := range segments {
+		path = joinTomlPath(path, segment)
+		last := i == len(segments)-1
+		if last && isArray {
+			n := s.idx.tables[path]
+			s.idx.tables[path] = n + 1
+			path = fmt.Sprintf("%s[%d]", path, n)
+		} else if n, exist := s.idx.tables[path]; exist {
+			path = fmt.Sprintf("%s[%d]", path, n-1)
+		}
+	}
+	s.idx.keys[path] = pos
+	return path
+}
+
+func (s *tomlScanner) scanValue(path string) {
+	s.skipSpaces(false)
+	if s.eof() {
+		return
+	}
+	s.idx.values[path] = s.position()
+	defer func() {
+		s.idx.ends[path] = s.position()
+	}()
+	switch c := s.peek(); c {
+	case '[':
+		s.next()
+		for i := 0; ; i++ {
+			s.skipSpaces(true)
+			if s.eof() {
+				return
+			}
+			if s.peek() == ']' {
+				s.next()
+				return
+			}
+			start := s.pos
+			s.scanValue(fmt.Sprintf("%s[%d]", path, i))
+			s.skipSpaces(true)
+			if s.peek() == ',' {
+				s.next()
+			} else if s.pos == start {
+				// skip a stray byte like '}' which starts no value, or the scan never ends
+				s.next()
+			}
+		}
+	case '{':
+		s.next()
+		for {
+			s.skipSpaces(false)
+			if s.eof() || s.peek() == '\n' {
+				return
+			}
+			if s.peek() == '}' {
+				s.next()
+				return
+			}
+			if s.peek() == ',' {
+				s.next()
+				continue
+			}
+			pos := s.position()
+			segments := s.readKey("=}")
+			if s.peek() != '=' {
+				return
+			}
+			s.next()
+			key := path
+			for _, segment := range segments {
+				key = joinTomlPath(key, segment)
+			}
+			s.idx.keys[key] = pos
+			s.scanValue(key)
+		}
+	case '"', '\'':
+		s.idx.texts[path] = s.readString()
+	default:
+		start := s.pos
+		for !s.eof() && !strings.ContainsRune(",]}\n#", rune(s.peek())) {
+			s.next()
+		}
+		s.idx.texts[path] = strings.TrimSpace(s.src[start:s.pos])
+	}
+}
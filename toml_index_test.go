@@ -0,0 +1,52 @@
+package didagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTomlIndexTolerant(t *testing.T) {
+	tests := []struct {
+		content string
+		path    string
+		text    string
+	}{
+		{"a = [}]\nb = 1", "b", "1"},
+		{"a = {x = [}]}\nb = 2", "b", "2"},
+		{"a = [1,}\nb = 3", "a[0]", "1"},
+		{"[[graph]]\n[[graph.vertex]]\ndeps = [}", "graph[0].vertex[0].deps", ""},
+		{"a = [1, {x = }, 'c']", "a[2]", "c"},
+	}
+	for _, test := range tests {
+		done := make(chan *tomlIndex)
+		go func(content string) {
+			done <- newTomlIndex(content)
+		}(test.content)
+		select {
+		case idx := <-done:
+			if _, exist := idx.values[test.path]; !exist {
+				t.Errorf("%q: no value:%s indexed", test.content, test.path)
+			}
+			if text := idx.texts[test.path]; text != test.text {
+				t.Errorf("%q: expect %s='%s', but got '%s'", test.content, test.path, test.text, text)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q: scan never ends", test.content)
+		}
+	}
+}
+
+func TestAnalyzeScriptTolerant(t *testing.T) {
+	done := make(chan *ScriptAnalysis)
+	go func() {
+		done <- AnalyzeScript(nil, "[[graph]]\nname = \"main\"\n[[graph.vertex]]\nprocessor = \"a\"\ndeps = [}")
+	}()
+	select {
+	case analysis := <-done:
+		if len(analysis.Diagnostics()) == 0 {
+			t.Errorf("Expect decode error diagnostics")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AnalyzeScript never ends")
+	}
+}
@@ -3,23 +3,29 @@ package didagle
 import (
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const V_RESULT_OK int = 1
 const V_RESULT_ERR int = 2
 const V_RESULT_ALL int = 3
 
+// V_RESULT_SKIP is the result of a vertex not executed, it matches neither V_RESULT_OK nor V_RESULT_ERR.
+const V_RESULT_SKIP int = 4
+
 type GraphData struct {
-	ID         string   `toml:"id"`
-	Field      string   `toml:"field"`
-	Aggregate  []string `toml:"aggregate"`
-	Cond       string   `toml:"cond"`
-	Required   bool     `toml:"required"`
-	Move       bool     `toml:"move"`
-	IsExtern   bool     `toml:"extern"`
-	IsInOut    bool
-	IsMapInput bool
+	ID         string   `toml:"id" json:"id"`
+	Field      string   `toml:"field" json:"field"`
+	Aggregate  []string `toml:"aggregate" json:"aggregate,omitempty"`
+	Cond       string   `toml:"cond" json:"cond,omitempty"`
+	Required   bool     `toml:"required" json:"required,omitempty"`
+	Move       bool     `toml:"move" json:"move,omitempty"`
+	IsExtern   bool     `toml:"extern" json:"extern,omitempty"`
+	IsInOut    bool     `json:"in_out,omitempty"`
+	IsMapInput bool     `json:"map_input,omitempty"`
 }
 
 type CondParams struct {
@@ -44,22 +50,53 @@ type Vertex struct {
 	DepsOnOk       []string `toml:"deps_on_ok"`
 	DepsOnErr      []string `toml:"deps_on_err"`
 
-	Input  []GraphData `toml:"input"`
-	Output []GraphData `toml:"output"`
-	Start  bool        `toml:"start"`
+	// Args are passed to the operator by the executor unless one of 'select_args' matches.
+	Args   map[string]interface{} `toml:"args"`
+	Input  []GraphData            `toml:"input"`
+	Output []GraphData            `toml:"output"`
+	Start  bool                   `toml:"start"`
+
+	// Template expands this vertex into the vertices of the template with Params substituted.
+	Template string                 `toml:"template"`
+	Params   map[string]interface{} `toml:"params"`
+	// FanOut expands this vertex into one vertex per value.
+	FanOut *FanOut `toml:"fan_out"`
+
+	// Timeout of each execution attempt like '50ms', the attempt fails with V_RESULT_ERR on timeout.
+	Timeout string `toml:"timeout"`
+	// Retry is the max retry count after a failed attempt, the n-th retry waits RetryBackoff*2^(n-1).
+	Retry        int    `toml:"retry"`
+	RetryBackoff string `toml:"retry_backoff"`
+	// Fallback processor executed with the same input/output when all attempts failed.
+	Fallback string `toml:"fallback"`
+	// DefaultOutput sets output data(by data id) not produced when all attempts failed.
+	// The vertex result is still V_RESULT_ERR, so 'else'/'deps_on_err' branches fire.
+	DefaultOutput map[string]interface{} `toml:"default_output"`
 
 	successorVertex map[string]*Vertex
 	depsResults     map[string]int
-	isIdGenerated   bool
-	isGenerated     bool
-	g               *Graph
+	// opName & fallbackOpName are the processor names resolved to 'name@version', see GraphCluster.getOpMeta.
+	opName         string
+	fallbackOpName string
+	isIdGenerated  bool
+	isGenerated    bool
+	expandedFrom   string
+	timeout        time.Duration
+	retryBackoff   time.Duration
+	g              *Graph
 }
 
-func (p *Vertex) dumpDotDefine(s *strings.Builder) {
+func (p *Vertex) dumpDotDefine(s *strings.Builder, scope string) {
 	s.WriteString("    ")
-	s.WriteString(p.getDotId())
+	s.WriteString(p.dotId(scope))
 	s.WriteString(" [label=\"")
 	s.WriteString(p.getDotLabel())
+	if len(p.expandedFrom) > 0 {
+		s.WriteString("\\n<")
+		s.WriteString(p.expandedFrom)
+		s.WriteString(">\" tooltip=\"expanded from ")
+		s.WriteString(p.expandedFrom)
+	}
 	s.WriteString("\"")
 	if len(p.Cond) > 0 {
 		s.WriteString(" shape=diamond color=black fillcolor=aquamarine style=filled")
@@ -71,15 +108,15 @@ func (p *Vertex) dumpDotDefine(s *strings.Builder) {
 	s.WriteString("];\n")
 }
 
-func (p *Vertex) dumpDotEdge(s *strings.Builder) {
+// dumpDotEdge writes edges of the vertex, edges between vertices both executed in traced are highlighted.
+func (p *Vertex) dumpDotEdge(s *strings.Builder, scope string, visible map[string]bool, traced map[string]*VertexResult) {
 	//log.Printf("Dump edge for %s/%s with deps:%d", p.g.Name, p.getDotLabel(), len(p.depsResults))
 	if len(p.ExpectConfig) > 0 {
-		expectConfigId := p.g.Name + "_" + p.ExpectConfig
-		expectConfigId = strings.ReplaceAll(expectConfigId, "!", "")
+		expectConfigId := dotQuote(scope + "_" + strings.ReplaceAll(p.ExpectConfig, "!", ""))
 		s.WriteString("    ")
 		s.WriteString(expectConfigId)
 		s.WriteString(" -> ")
-		s.WriteString(p.getDotId())
+		s.WriteString(p.dotId(scope))
 		if p.ExpectConfig[0] == '!' {
 			s.WriteString(" [style=dashed color=red label=\"err\"];\n")
 		} else {
@@ -87,29 +124,37 @@ func (p *Vertex) dumpDotEdge(s *strings.Builder) {
 		}
 
 		s.WriteString("    ")
-		s.WriteString(p.g.Name + "__START__")
+		s.WriteString(dotQuote(scope + "__START__"))
 		s.WriteString(" -> ")
 		s.WriteString(expectConfigId + ";\n")
 	}
-	if nil == p.successorVertex || len(p.successorVertex) == 0 {
-		s.WriteString("    " + p.getDotId() + " -> " + p.g.Name + "__STOP__;\n")
+	if p.isSuccessorsEmpty() {
+		s.WriteString("    " + p.dotId(scope) + " -> " + dotQuote(scope+"__STOP__") + ";\n")
 	}
-	if nil == p.depsResults || len(p.depsResults) == 0 {
-		s.WriteString("    " + p.g.Name + "__START__ -> " + p.getDotId() + ";\n")
+	if p.isDepsEmpty() {
+		s.WriteString("    " + dotQuote(scope+"__START__") + " -> " + p.dotId(scope) + ";\n")
 	}
 
-	if nil != p.depsResults && len(p.depsResults) > 0 {
-		for id, expect := range p.depsResults {
-			dep := p.g.getVertexById(id)
-			s.WriteString("    " + dep.getDotId() + " -> " + p.getDotId())
+	if !p.isDepsEmpty() {
+		for _, d := range p.sortedDeps() {
+			if nil != visible && !visible[d.ID] {
+				continue
+			}
+			expect := p.depsResults[d.ID]
+			dep := p.g.getVertexById(d.ID)
+			s.WriteString("    " + dep.dotId(scope) + " -> " + p.dotId(scope))
 			switch expect {
 			case V_RESULT_OK:
-				s.WriteString(" [style=dashed label=\"ok\"];\n")
+				s.WriteString(" [style=dashed label=\"ok\"")
 			case V_RESULT_ERR:
-				s.WriteString(" [style=dashed color=red label=\"err\"];\n")
+				s.WriteString(" [style=dashed color=red label=\"err\"")
 			default:
-				s.WriteString(" [style=bold label=\"all\"];\n")
+				s.WriteString(" [style=bold label=\"all\"")
+			}
+			if isExecuted(traced, dep.ID) && isExecuted(traced, p.ID) {
+				s.WriteString(" color=darkgreen penwidth=2")
 			}
+			s.WriteString("];\n")
 		}
 	}
 }
@@ -142,19 +187,40 @@ func (p *Vertex) isDepsEmpty() bool {
 func (p *Vertex) verify() error {
 	if !p.Start {
 		if p.isDepsEmpty() && p.isSuccessorsEmpty() {
-			return fmt.Errorf("Vertex:%s/%s has no deps and successors", p.g.Name, p.getDotLabel())
+			return p.buildError("", "", "Vertex:%s/%s has no deps and successors", p.g.Name, p.getDotLabel())
 		}
 	} else {
 		if !p.isDepsEmpty() {
-			return fmt.Errorf("Vertex:%s/%s is start vertex, but has non empty deps.", p.g.Name, p.getDotLabel())
+			return p.buildError("start", "", "Vertex:%s/%s is start vertex, but has non empty deps.", p.g.Name, p.getDotLabel())
 		}
 	}
 	return nil
 }
 
 func (p *Vertex) getDotId() string {
-	return p.g.Name + "_" + p.ID
+	return p.dotName(p.g.Name)
+}
+
+// dotName is the unquoted dot id of the vertex in the scope.
+func (p *Vertex) dotName(scope string) string {
+	return scope + "_" + p.ID
+}
+
+func (p *Vertex) dotId(scope string) string {
+	return dotQuote(p.dotName(scope))
 }
+
+// dotQuote quotes the dot id unless it's a plain identifier, ids like 'g_foo@v1' are invalid unquoted.
+func dotQuote(id string) string {
+	for i, r := range id {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return "\"" + strings.ReplaceAll(id, "\"", "\\\"") + "\""
+	}
+	return id
+}
+
 func (p *Vertex) getDotLabel() string {
 	if len(p.Cond) > 0 {
 		return strings.ReplaceAll(p.Cond, "\"", "\\\"")
@@ -180,7 +246,7 @@ func (p *Vertex) buildInputOutput() error {
 	}
 	meta := p.g.cluster.getOpMeta(p.Processor)
 	if nil == meta {
-		return fmt.Errorf("No Processor:%s found", p.Processor)
+		return p.buildError("processor", p.Processor, "No Processor:%s found", p.Processor)
 	}
 	for _, opInput := range meta.Input {
 		match := false
@@ -220,43 +286,112 @@ func (p *Vertex) buildInputOutput() error {
 	}
 	return nil
 }
-func (p *Vertex) depend(v *Vertex, expected int) {
+func (p *Vertex) depend(v *Vertex, expected int) error {
 	if nil == p.depsResults {
 		p.depsResults = make(map[string]int)
 	}
+	// the stricter expectation wins if the vertex is depended multiple times, like input + 'deps_on_ok',
+	// while 'ok' & 'err' on the same dep could never be both matched
+	if prev, exist := p.depsResults[v.ID]; exist && prev != V_RESULT_ALL {
+		if expected != V_RESULT_ALL && expected != prev {
+			return p.buildError("", v.ID, "[%s/%s]Conflict expectations %s & %s on dep vertex:%s", p.g.Name, p.getDotLabel(), expectString(prev), expectString(expected), v.getDotLabel())
+		}
+		expected = prev
+	}
 	p.depsResults[v.ID] = expected
 	if nil == v.successorVertex {
 		v.successorVertex = make(map[string]*Vertex)
 	}
 	v.successorVertex[p.ID] = p
 	//log.Printf("####[%s/%s]depend %s->%s  %d", p.g.Name, p.getDotLabel(), v.getDotLabel(), p.getDotLabel(), len(p.depsResults))
+	return nil
 }
-func (p *Vertex) buildDeps(deps []string, expectedResult int) error {
+func (p *Vertex) buildDeps(key string, deps []string, expectedResult int) error {
 	for _, id := range deps {
 		dep := p.g.getVertexById(id)
 		if nil == dep {
-			return fmt.Errorf("[%s/%s]No dep vertex id:%s", p.g.Name, p.getDotLabel(), id)
+			return p.buildError(key, id, "[%s/%s]No dep vertex id:%s", p.g.Name, p.getDotLabel(), id)
+		}
+		if err := p.depend(dep, expectedResult); nil != err {
+			return err
 		}
-		p.depend(dep, expectedResult)
 	}
 	return nil
 }
 
-func (p *Vertex) buildSuccessor(sucessors []string, expectedResult int) error {
+func (p *Vertex) buildSuccessor(key string, sucessors []string, expectedResult int) error {
 	for _, id := range sucessors {
 		successor := p.g.getVertexById(id)
 		if nil == successor {
-			return fmt.Errorf("[%s]No successor id:%s", p.getDotLabel(), id)
+			return p.buildError(key, id, "[%s]No successor id:%s", p.getDotLabel(), id)
+		}
+		if err := successor.depend(p, expectedResult); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseVertexDuration(v *Vertex, key string, s string) (time.Duration, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if nil != err || d <= 0 {
+		return 0, v.buildError(key, s, "[%s/%s]Invalid %s:%s, expect positive duration like '100ms'", v.g.Name, v.getDotLabel(), key, s)
+	}
+	return d, nil
+}
+
+// buildFaultTolerance verifies timeout/retry/fallback/default_output of the vertex.
+func (p *Vertex) buildFaultTolerance() error {
+	var err error
+	if p.timeout, err = parseVertexDuration(p, "timeout", p.Timeout); nil != err {
+		return err
+	}
+	if p.retryBackoff, err = parseVertexDuration(p, "retry_backoff", p.RetryBackoff); nil != err {
+		return err
+	}
+	if p.Retry < 0 {
+		return p.buildError("retry", "", "[%s/%s]Invalid retry:%d", p.g.Name, p.getDotLabel(), p.Retry)
+	}
+	if (len(p.Fallback) > 0 || len(p.DefaultOutput) > 0) && (len(p.Processor) == 0 || len(p.Cond) > 0) {
+		key := "fallback"
+		if len(p.Fallback) == 0 {
+			key = "default_output"
+		}
+		return p.buildError(key, "", "[%s/%s]Only processor vertex could config 'fallback' or 'default_output'", p.g.Name, p.getDotLabel())
+	}
+	if len(p.Fallback) > 0 && p.g.cluster.StrictDsl && nil == p.g.cluster.getOpMeta(p.Fallback) {
+		return p.buildError("fallback", p.Fallback, "[%s/%s]No fallback Processor:%s found", p.g.Name, p.getDotLabel(), p.Fallback)
+	}
+	for id := range p.DefaultOutput {
+		if len(p.Output) == 0 {
+			break
+		}
+		match := false
+		for _, data := range p.Output {
+			if data.ID == id {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return p.buildError("default_output."+id, "", "[%s/%s]No output id:%s for default_output", p.g.Name, p.getDotLabel(), id)
 		}
-		successor.depend(p, expectedResult)
 	}
 	return nil
 }
 
 func (p *Vertex) build() error {
+	if err := p.buildFaultTolerance(); nil != err {
+		return err
+	}
+	p.opName = p.g.cluster.resolveOpName(p.Processor)
+	p.fallbackOpName = p.g.cluster.resolveOpName(p.Fallback)
 	for _, cond := range p.SelectArgs {
 		if !p.g.cluster.ContainsConfigSetting(cond.Match) {
-			return fmt.Errorf("No config_setting with name:%s defined.", cond.Match)
+			return p.buildError("select_args.match", cond.Match, "No config_setting with name:%s defined.", cond.Match)
 		}
 	}
 
@@ -264,7 +399,7 @@ func (p *Vertex) build() error {
 		if len(data.Aggregate) == 0 && !data.IsMapInput {
 			dep := p.g.getVertexByData(data.ID)
 			if nil == dep && !data.IsExtern {
-				return fmt.Errorf("[%s/%s]No dep input id:%s", p.g.Name, p.getDotLabel(), data.ID)
+				return p.buildError("input", data.ID, "[%s/%s]No dep input id:%s", p.g.Name, p.getDotLabel(), data.ID)
 			}
 			if nil == dep {
 				continue
@@ -272,44 +407,48 @@ func (p *Vertex) build() error {
 			if data.IsInOut && dep == p {
 				continue
 			}
+			expected := V_RESULT_ALL
 			if data.Required {
-				p.depend(dep, V_RESULT_OK)
-			} else {
-				p.depend(dep, V_RESULT_ALL)
+				expected = V_RESULT_OK
+			}
+			if err := p.depend(dep, expected); nil != err {
+				return err
 			}
 		} else {
 			for _, id := range data.Aggregate {
 				dep := p.g.getVertexByData(id)
 				if nil == dep && !data.IsExtern {
-					return fmt.Errorf("[%s/%s]No dep input id:%s", p.g.Name, p.getDotLabel(), data.ID)
+					return p.buildError("input.aggregate", id, "[%s/%s]No dep input id:%s", p.g.Name, p.getDotLabel(), id)
 				}
 				if nil == dep {
 					continue
 				}
+				expected := V_RESULT_ALL
 				if data.Required {
-					p.depend(dep, V_RESULT_OK)
-				} else {
-					p.depend(dep, V_RESULT_ALL)
+					expected = V_RESULT_OK
+				}
+				if err := p.depend(dep, expected); nil != err {
+					return err
 				}
 			}
 		}
 	}
-	if err := p.buildDeps(p.DepsOnErr, V_RESULT_ERR); nil != err {
+	if err := p.buildDeps("deps_on_err", p.DepsOnErr, V_RESULT_ERR); nil != err {
 		return err
 	}
-	if err := p.buildDeps(p.DepsOnOk, V_RESULT_OK); nil != err {
+	if err := p.buildDeps("deps_on_ok", p.DepsOnOk, V_RESULT_OK); nil != err {
 		return err
 	}
-	if err := p.buildDeps(p.Deps, V_RESULT_ALL); nil != err {
+	if err := p.buildDeps("deps", p.Deps, V_RESULT_ALL); nil != err {
 		return err
 	}
-	if err := p.buildSuccessor(p.SuccessorOnErr, V_RESULT_ERR); nil != err {
+	if err := p.buildSuccessor("else", p.SuccessorOnErr, V_RESULT_ERR); nil != err {
 		return err
 	}
-	if err := p.buildSuccessor(p.SuccessorOnOk, V_RESULT_OK); nil != err {
+	if err := p.buildSuccessor("if", p.SuccessorOnOk, V_RESULT_OK); nil != err {
 		return err
 	}
-	if err := p.buildSuccessor(p.Successor, V_RESULT_ALL); nil != err {
+	if err := p.buildSuccessor("successor", p.Successor, V_RESULT_ALL); nil != err {
 		return err
 	}
 	//log.Printf("%s/%s has if:%v else %v, all %v", p.g.Name, p.getDotLabel(), p.SuccessorOnOk, p.SuccessorOnErr, p.Successor)
@@ -317,14 +456,17 @@ func (p *Vertex) build() error {
 }
 
 type ConfigSetting struct {
-	Name      string `toml:"name"`
-	Cond      string `toml:"cond"`
-	Processor string `toml:"processor"`
+	Name      string `toml:"name" json:"name"`
+	Cond      string `toml:"cond" json:"cond"`
+	Processor string `toml:"processor" json:"processor,omitempty"`
 }
 
 type Graph struct {
 	Name   string   `toml:"name"`
 	Vertex []Vertex `toml:"vertex"`
+	// Template prepends the vertices of the template with Params substituted.
+	Template string                 `toml:"template"`
+	Params   map[string]interface{} `toml:"params"`
 
 	cluster     *GraphCluster
 	genVertexs  map[string]*Vertex
@@ -335,38 +477,19 @@ type Graph struct {
 }
 
 func (p *Graph) dumpDot(buffer *strings.Builder) {
-	buffer.WriteString("  subgraph cluster_")
-	buffer.WriteString(p.Name)
-	buffer.WriteString("{\n")
-	buffer.WriteString("    style = rounded;\n")
-	buffer.WriteString(fmt.Sprintf("    label = \"%s\";\n", p.Name))
-	buffer.WriteString("    ")
-	buffer.WriteString(p.Name + "__START__")
-	buffer.WriteString("[color=black fillcolor=deepskyblue style=filled shape=Msquare label=\"START\"];\n")
-	buffer.WriteString("    ")
-	buffer.WriteString(p.Name + "__STOP__")
-	buffer.WriteString("[color=black fillcolor=deepskyblue style=filled shape=Msquare label=\"STOP\"];\n")
-
-	for _, v := range p.vertexMap {
-		v.dumpDotDefine(buffer)
-	}
-
-	for _, c := range p.cluster.ConfigSetting {
-		buffer.WriteString("    ")
-		buffer.WriteString(p.Name + "_" + c.Name)
-		buffer.WriteString(" [label=\"")
-		buffer.WriteString(c.Name)
-		buffer.WriteString("\"")
-		buffer.WriteString(" shape=diamond color=black fillcolor=aquamarine style=filled];\n")
-	}
+	r := newDotRender(buffer, p.cluster, nil)
+	r.dumpGraph(p, p.Name, p.Name, nil)
+}
 
+func (p *Graph) sortedVertexs() []*Vertex {
+	vs := make([]*Vertex, 0, len(p.vertexMap))
 	for _, v := range p.vertexMap {
-		if v.isGenerated {
-			continue
-		}
-		v.dumpDotEdge(buffer)
+		vs = append(vs, v)
 	}
-	buffer.WriteString("};\n")
+	sort.Slice(vs, func(i, j int) bool {
+		return vs[i].ID < vs[j].ID
+	})
+	return vs
 }
 
 func (p *Graph) genVertexId() string {
@@ -389,6 +512,50 @@ func (p *Graph) getVertexById(id string) *Vertex {
 	return nil
 }
 
+// getConsumersByData returns vertices which take the data as input or aggregate input.
+func (p *Graph) getConsumersByData(data string) []*Vertex {
+	var consumers []*Vertex
+	for _, v := range p.vertexMap {
+		for _, input := range v.Input {
+			match := input.ID == data
+			for _, id := range input.Aggregate {
+				if id == data {
+					match = true
+				}
+			}
+			if match {
+				consumers = append(consumers, v)
+				break
+			}
+		}
+	}
+	return consumers
+}
+
+// collectUpstream marks the vertex and all vertices it transitively depends on.
+func (p *Graph) collectUpstream(v *Vertex, visited map[string]bool) {
+	if visited[v.ID] {
+		return
+	}
+	visited[v.ID] = true
+	for id := range v.depsResults {
+		if dep := p.getVertexById(id); nil != dep {
+			p.collectUpstream(dep, visited)
+		}
+	}
+}
+
+// collectDownstream marks the vertex and all vertices transitively depending on it.
+func (p *Graph) collectDownstream(v *Vertex, visited map[string]bool) {
+	if visited[v.ID] {
+		return
+	}
+	visited[v.ID] = true
+	for _, successor := range v.successorVertex {
+		p.collectDownstream(successor, visited)
+	}
+}
+
 func (p *Graph) genCondVertex(cond string) *Vertex {
 	v := &Vertex{}
 	v.ID = p.genVertexId()
@@ -437,11 +604,11 @@ func (p *Graph) build() error {
 		}
 
 		if len(v.Expect) > 0 && len(v.ExpectConfig) > 0 {
-			return fmt.Errorf("Vertex:%s can NOT both config 'expect' & 'expect_config'", v.ID)
+			return newBuildError(p.Name, v.ID, "expect_config", "", "Vertex:%s can NOT both config 'expect' & 'expect_config'", v.ID)
 		}
 		if len(v.ExpectConfig) > 0 {
 			if !p.cluster.ContainsConfigSetting(v.ExpectConfig) {
-				return fmt.Errorf("No config_setting with name:%s defined", v.ExpectConfig)
+				return newBuildError(p.Name, v.ID, "expect_config", v.ExpectConfig, "No config_setting with name:%s defined", v.ExpectConfig)
 			}
 		}
 		if len(v.Expect) > 0 {
@@ -461,7 +628,9 @@ func (p *Graph) build() error {
 			}
 		}
 		if _, exist := p.vertexMap[v.ID]; exist {
-			return fmt.Errorf("Duplcate vertex id:%s", v.ID)
+			err := newBuildError(p.Name, "", "vertex.id", v.ID, "Duplcate vertex id:%s", v.ID)
+			err.Duplicate = true
+			return err
 		}
 		v.g = p
 		p.vertexMap[v.ID] = v
@@ -475,7 +644,7 @@ func (p *Graph) build() error {
 		for idx := range v.Input {
 			data := &v.Input[idx]
 			if len(data.Field) == 0 {
-				return fmt.Errorf("Empty data field in intput for node:%s", v.ID)
+				return v.buildError("input", "", "Empty data field in intput for node:%s", v.ID)
 			}
 			if len(data.ID) == 0 {
 				data.ID = data.Field
@@ -487,14 +656,14 @@ func (p *Graph) build() error {
 		for idx := range v.Output {
 			data := &v.Output[idx]
 			if len(data.Field) == 0 {
-				return fmt.Errorf("Empty data field in output for node:%s", v.ID)
+				return v.buildError("output", "", "Empty data field in output for node:%s", v.ID)
 			}
 			if len(data.ID) == 0 {
 				data.ID = data.Field
 			}
 			if p.cluster.StrictDsl {
 				if prev, exist := p.dataMapping[data.ID]; exist {
-					return fmt.Errorf("Duplicate data name:%s in vertex:%s/%s, prev vertex:%s", data.ID, v.g.Name, v.getDotLabel(), prev.getDotLabel())
+					return v.buildError("output", data.ID, "Duplicate data name:%s in vertex:%s/%s, prev vertex:%s", data.ID, v.g.Name, v.getDotLabel(), prev.getDotLabel())
 				}
 			}
 			//do NOT mapping out if this field is inout
@@ -525,20 +694,50 @@ func (p *Graph) build() error {
 		// }
 	}
 	if p.testCircle() {
-		return fmt.Errorf("Circle Exist")
+		return newBuildError(p.Name, "", "", "", "Circle Exist")
 	}
 	return nil
 }
 
+// ContextPoolSize decodes both integer and the deprecated numeric string form, like 16 or "16".
+type ContextPoolSize int
+
+func (p *ContextPoolSize) UnmarshalTOML(v interface{}) error {
+	switch size := v.(type) {
+	case int64:
+		*p = ContextPoolSize(size)
+		return nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if nil != err {
+			return fmt.Errorf("Invalid default_context_pool_size:'%s'", size)
+		}
+		*p = ContextPoolSize(n)
+		return nil
+	}
+	return fmt.Errorf("Invalid default_context_pool_size:%v, expect integer", v)
+}
+
 type GraphCluster struct {
+	// Name is a display name of the script, sub graph vertices still refer the cluster by the script file name.
+	Name                   string          `toml:"name"`
 	Desc                   string          `toml:"desc"`
 	StrictDsl              bool            `toml:"strict_dsl"`
 	DefaultExprProcessor   string          `toml:"default_expr_processor"`
-	DefaultDefaultPoolSize string          `toml:"default_context_pool_size"`
+	DefaultContextPoolSize ContextPoolSize `toml:"default_context_pool_size"`
 	Graph                  []Graph         `toml:"graph"`
 	ConfigSetting          []ConfigSetting `toml:"config_setting"`
+	// Include imports config_setting/graph/template from other scripts, relative to this script and confined under
+	// the directory of the loaded script file. Scripts loaded by content can NOT include.
+	Include  []string        `toml:"include"`
+	Template []GraphTemplate `toml:"template"`
+	// Deprecated: use DefaultContextPoolSize, it's only used if DefaultContextPoolSize is not set.
+	DefaultDefaultPoolSize string `toml:"-"`
 
 	name string
+	dir  string
+	// unknown keys of included scripts when 'strict_dsl' is not enabled
+	includeWarnings []Diagnostic
 
 	graphMap map[string]*Graph
 	opsMap   map[string]OperatorMeta
@@ -560,25 +759,104 @@ func (p *GraphCluster) ContainsConfigSetting(name string) bool {
 	return false
 }
 
+func (p *GraphCluster) getGraphByName(name string) *Graph {
+	g, exist := p.graphMap[name]
+	if !exist {
+		return nil
+	}
+	return g
+}
+
+// verifySubGraphs checks that every sub graph vertex refers to an existing graph in the clusters.
+func (p *GraphCluster) verifySubGraphs(clusters map[string]*GraphCluster) []error {
+	var errs []error
+	for i := range p.Graph {
+		g := &p.Graph[i]
+		for _, v := range g.sortedVertexs() {
+			if len(v.Graph) == 0 {
+				continue
+			}
+			cluster, exist := clusters[v.Cluster]
+			if !exist {
+				errs = append(errs, newBuildError(g.Name, v.ID, "cluster", v.Cluster, "[%s/%s]No cluster:%s found for sub graph:%s", g.Name, v.ID, v.Cluster, v.Graph))
+				continue
+			}
+			if nil == cluster.getGraphByName(v.Graph) {
+				errs = append(errs, newBuildError(g.Name, v.ID, "graph", v.Graph, "[%s/%s]No graph:%s found in cluster:%s", g.Name, v.ID, v.Graph, v.Cluster))
+			}
+		}
+	}
+	return errs
+}
+
+// getOpMeta finds operator meta by 'name@version', or by 'name' for the unversioned one or the latest version.
 func (p *GraphCluster) getOpMeta(name string) *OperatorMeta {
 	v, exist := p.opsMap[name]
-	if !exist {
+	if exist {
+		return &v
+	}
+	if strings.Contains(name, OP_VERSION_SEPARATOR) {
 		return nil
 	}
-	return &v
+	var latest *OperatorMeta
+	for _, op := range p.opsMap {
+		if op.Name != name {
+			continue
+		}
+		if nil == latest || compareOpVersion(op.Version, latest.Version) > 0 {
+			v := op
+			latest = &v
+		}
+	}
+	return latest
+}
+
+// resolveOpName returns the full name of the operator meta found by the name, the name itself if not found.
+func (p *GraphCluster) resolveOpName(name string) string {
+	if len(name) == 0 {
+		return name
+	}
+	if meta := p.getOpMeta(name); nil != meta {
+		return meta.FullName()
+	}
+	return name
 }
 
 func (p *GraphCluster) build(ops []OperatorMeta) error {
+	if p.DefaultContextPoolSize == 0 && len(p.DefaultDefaultPoolSize) > 0 {
+		size, err := strconv.Atoi(p.DefaultDefaultPoolSize)
+		if nil != err {
+			return newBuildError("", "", "default_context_pool_size", p.DefaultDefaultPoolSize, "Invalid default_context_pool_size:%s", p.DefaultDefaultPoolSize)
+		}
+		p.DefaultContextPoolSize = ContextPoolSize(size)
+	}
+	if p.DefaultContextPoolSize < 0 {
+		return newBuildError("", "", "default_context_pool_size", "", "Invalid default_context_pool_size:%d", p.DefaultContextPoolSize)
+	}
 	p.opsMap = make(map[string]OperatorMeta)
 	for _, op := range ops {
-		p.opsMap[op.Name] = op
+		p.opsMap[op.FullName()] = op
+	}
+	if err := p.loadIncludes(); nil != err {
+		return err
+	}
+	if err := p.expandTemplates(); nil != err {
+		return err
+	}
+	for i := range p.Graph {
+		p.Graph[i].cluster = p
+		if err := p.Graph[i].expandFanOuts(); nil != err {
+			return err
+		}
 	}
 	p.graphMap = make(map[string]*Graph)
 	for i := range p.Graph {
 		g := &p.Graph[i]
 		g.cluster = p
 		if _, exist := p.graphMap[g.Name]; exist {
-			return fmt.Errorf("Duplicate graph name:%v", g.Name)
+			err := newBuildError("", "", "graph.name", g.Name, "Duplicate graph name:%v", g.Name)
+			err.Duplicate = true
+			return err
 		}
 		p.graphMap[g.Name] = g
 		err := g.build()
@@ -589,11 +867,12 @@ func (p *GraphCluster) build(ops []OperatorMeta) error {
 	return nil
 }
 
-func (p *GraphCluster) dumpDot(buffer *strings.Builder) {
+func (p *GraphCluster) dumpDot(buffer *strings.Builder, opt *RenderOptions) {
+	r := newDotRender(buffer, p, opt)
 	buffer.WriteString("digraph G {\n")
 	buffer.WriteString("    rankdir=LR;\n")
 	for i := len(p.Graph) - 1; i >= 0; i-- {
-		p.Graph[i].dumpDot(buffer)
+		r.dumpRootGraph(&p.Graph[i])
 	}
 	buffer.WriteString("}\n")
 }
@@ -0,0 +1,237 @@
+package didagle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// goroutineId parses the id of the current goroutine from the stack header 'goroutine 18 [running]:'.
+func goroutineId() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if idx := bytes.IndexByte(buf, ' '); idx > 0 {
+		buf = buf[:idx]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+// dataSize returns the length of string/slice/map/array/chan data, 0 for nil and 1 for others.
+func dataSize(data interface{}) int {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array, reflect.Chan:
+		return v.Len()
+	}
+	return 1
+}
+
+func (p *graphContext) traceCond(result *VertexResult, cond string, ok bool) {
+	if !p.e.executor.trace {
+		return
+	}
+	if nil == result.Conds {
+		result.Conds = make(map[string]bool)
+	}
+	result.Conds[cond] = ok
+}
+
+func (p *graphContext) traceOutputs(result *VertexResult, v *Vertex) {
+	if !p.e.executor.trace {
+		return
+	}
+	ids := make(map[string]bool)
+	for _, data := range v.Output {
+		ids[data.ID] = true
+	}
+	if r := p.e.executor.registry.get(v.opName); nil != r {
+		for _, binding := range r.outputs {
+			if data := dataIdOf(v.Output, binding.name); nil == data {
+				ids[binding.name] = true
+			}
+		}
+	}
+	for id := range v.DefaultOutput {
+		ids[id] = true
+	}
+	for id := range ids {
+		if data, exist := p.e.store.get(id); exist {
+			if nil == result.DataSizes {
+				result.DataSizes = make(map[string]int)
+			}
+			result.DataSizes[id] = dataSize(data)
+		}
+	}
+}
+
+func resultName(r *VertexResult) string {
+	switch {
+	case r.Cancelled:
+		return "cancelled"
+	case r.Skipped:
+		return "skipped"
+	case r.Result == V_RESULT_OK:
+		return "ok"
+	}
+	return "err"
+}
+
+func isExecuted(traced map[string]*VertexResult, id string) bool {
+	r, exist := traced[id]
+	return exist && !r.Skipped
+}
+
+// dumpTraceAnnotations overrides the style & label of traced vertices defined in the scope.
+func dumpTraceAnnotations(s *strings.Builder, g *Graph, scope string, traced map[string]*VertexResult) {
+	for _, v := range g.sortedVertexs() {
+		r, exist := traced[v.ID]
+		if !exist {
+			continue
+		}
+		outcome := resultName(r)
+		if len(v.Cond) > 0 && !r.Skipped && nil == r.Err {
+			outcome = fmt.Sprintf("%v", r.Result == V_RESULT_OK)
+		}
+		label := v.getDotLabel() + "\\n" + outcome
+		if !r.Start.IsZero() && !r.Skipped {
+			label += " " + r.End.Sub(r.Start).String()
+		}
+		if r.Attempts > 1 {
+			label += fmt.Sprintf(" x%d", r.Attempts)
+		}
+		if ok, exist := r.Conds[v.ExpectConfig]; exist && len(v.ExpectConfig) > 0 {
+			label += fmt.Sprintf("\\n%s=%v", v.ExpectConfig, ok)
+		}
+		s.WriteString("    " + v.dotId(scope) + " [label=\"" + label + "\"")
+		switch {
+		case r.Skipped:
+			s.WriteString(" style=\"filled,dashed\" color=gray fontcolor=gray fillcolor=whitesmoke")
+		case r.Result == V_RESULT_OK:
+			s.WriteString(" style=filled fillcolor=palegreen penwidth=2")
+		case nil == r.Err:
+			// cond evaluated to false
+			s.WriteString(" style=filled fillcolor=khaki penwidth=2")
+		default:
+			s.WriteString(" style=filled fillcolor=salmon penwidth=2")
+		}
+		if nil != r.Err {
+			s.WriteString(" tooltip=\"" + strings.ReplaceAll(r.Err.Error(), "\"", "\\\"") + "\"")
+		}
+		s.WriteString("];\n")
+	}
+}
+
+// byGraph groups the vertex results by graph path.
+func (p *ExecuteResult) byGraph() map[string]map[string]*VertexResult {
+	traced := make(map[string]map[string]*VertexResult)
+	for _, r := range p.Vertexs {
+		if nil == traced[r.Graph] {
+			traced[r.Graph] = make(map[string]*VertexResult)
+		}
+		traced[r.Graph][r.ID] = r
+	}
+	return traced
+}
+
+// DumpTraceDot renders the executed graph annotated with the result, executed vertices are coloured by result,
+// skipped vertices are dashed gray and edges between executed vertices are highlighted.
+// Sub graphs are annotated if inlined by opt.SubGraphDepth.
+func (p *DAGConfig) DumpTraceDot(result *ExecuteResult, opt *RenderOptions) string {
+	o := RenderOptions{}
+	if nil != opt {
+		o = *opt
+	}
+	o.Graph = result.Graph
+	var buffer strings.Builder
+	r := newDotRender(&buffer, &p.graph, &o)
+	r.trace = result.byGraph()
+	buffer.WriteString("digraph G {\n")
+	buffer.WriteString("    rankdir=LR;\n")
+	if g := p.graph.getGraphByName(result.Graph); nil != g {
+		r.dumpRootGraph(g)
+	}
+	buffer.WriteString("}\n")
+	return buffer.String()
+}
+
+type chromeTraceEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat"`
+	Phase string                 `json:"ph"`
+	Ts    float64                `json:"ts"`
+	Dur   float64                `json:"dur,omitempty"`
+	Pid   int                    `json:"pid"`
+	Tid   uint64                 `json:"tid"`
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// ChromeTrace exports the traced execution as chrome trace-event json, loadable by chrome://tracing or perfetto.
+// Executed vertices are complete events on their goroutines, skipped vertices are instant events.
+func (p *ExecuteResult) ChromeTrace() ([]byte, error) {
+	var base time.Time
+	for _, r := range p.Vertexs {
+		if !r.Start.IsZero() && (base.IsZero() || r.Start.Before(base)) {
+			base = r.Start
+		}
+	}
+	if base.IsZero() {
+		return nil, fmt.Errorf("No trace recorded, enable ExecutorOptions.Trace")
+	}
+	micros := func(d time.Duration) float64 {
+		return float64(d.Nanoseconds()) / 1000
+	}
+	events := make([]chromeTraceEvent, 0, len(p.Vertexs))
+	for _, r := range p.Vertexs {
+		event := chromeTraceEvent{
+			Name:  r.ID,
+			Cat:   r.Graph,
+			Phase: "X",
+			Ts:    micros(r.Start.Sub(base)),
+			Dur:   micros(r.End.Sub(r.Start)),
+			Pid:   1,
+			Tid:   r.Goroutine,
+			Args:  map[string]interface{}{"graph": r.Graph, "result": resultName(r)},
+		}
+		if r.Skipped {
+			event.Phase = "i"
+			event.Dur = 0
+			event.Scope = "t"
+		}
+		if nil != r.Err {
+			event.Args["error"] = r.Err.Error()
+		}
+		if r.Attempts > 1 {
+			event.Args["attempts"] = r.Attempts
+		}
+		if r.Fallback {
+			event.Args["fallback"] = true
+		}
+		if len(r.Conds) > 0 {
+			event.Args["conds"] = r.Conds
+		}
+		if len(r.DataSizes) > 0 {
+			event.Args["data_sizes"] = r.DataSizes
+		}
+		events = append(events, event)
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	}, "", "  ")
+}
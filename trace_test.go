@@ -0,0 +1,184 @@
+package didagle
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceVersionedOperator(t *testing.T) {
+	registry := NewOperatorRegistry()
+	registry.Register("foo@v1", &testVersionV1Op{})
+	registry.Register("foo@v2", &testVersionV2Op{})
+	registry.Register("nop", &testNopOp{})
+	cfg, err := NewDAGConfigByProviders(writeTestScript(t, `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+processor = "foo"
+successor = ["nop"]
+[[graph.vertex]]
+processor = "nop"
+`), registry)
+	if nil != err {
+		t.Fatalf("Failed to build with err:%v", err)
+	}
+	executor := NewExecutor(cfg, &ExecutorOptions{Registry: registry, Trace: true})
+	rs, err := executor.Execute(context.Background(), "g", nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, v := range rs.Vertexs {
+		// the implicit output of 'foo@v2' is traced
+		if size, exist := v.DataSizes["version"]; v.ID == "foo" && (!exist || size != 2) {
+			t.Errorf("Expect traced data size of 'version', but got %v", v.DataSizes)
+		}
+	}
+}
+
+type testSleepOp struct{}
+
+func (p *testSleepOp) Execute(ctx context.Context, args map[string]interface{}) error {
+	time.Sleep(20 * time.Millisecond)
+	return nil
+}
+
+const testTraceScript = `
+[[graph]]
+name = "g"
+[[graph.vertex]]
+id = "gen"
+processor = "produce"
+successor = ["left", "right"]
+[[graph.vertex]]
+id = "left"
+processor = "sleep"
+successor = ["bad"]
+[[graph.vertex]]
+id = "right"
+processor = "sleep"
+successor = ["bad"]
+[[graph.vertex]]
+id = "bad"
+processor = "fail"
+retry = 1
+if = ["after"]
+[[graph.vertex]]
+id = "after"
+processor = "nop"
+`
+
+func executeTraced(t *testing.T) (*DAGConfig, *ExecuteResult) {
+	t.Helper()
+	registry := newTestRegistry(t)
+	registry.Register("produce", &testProduceOp{})
+	registry.Register("sleep", &testSleepOp{})
+	cfg, err := NewDAGConfigByProviders(writeTestScript(t, testTraceScript), registry)
+	if nil != err {
+		t.Fatalf("Failed to build with err:%v", err)
+	}
+	executor := NewExecutor(cfg, &ExecutorOptions{Registry: registry, Trace: true})
+	rs, err := executor.Execute(context.Background(), "g", nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return cfg, rs
+}
+
+func TestChromeTrace(t *testing.T) {
+	_, rs := executeTraced(t)
+	content, err := rs.ChromeTrace()
+	if nil != err {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents     []chromeTraceEvent `json:"traceEvents"`
+		DisplayTimeUnit string             `json:"displayTimeUnit"`
+	}
+	if err := json.Unmarshal(content, &trace); nil != err {
+		t.Fatalf("Invalid chrome trace json:%v", err)
+	}
+	if trace.DisplayTimeUnit != "ms" || len(trace.TraceEvents) != len(rs.Vertexs) {
+		t.Fatalf("Unexpected chrome trace:%s", content)
+	}
+	events := make(map[string]chromeTraceEvent)
+	for _, event := range trace.TraceEvents {
+		events[event.Name] = event
+		r := rs.Get("g", event.Name)
+		if event.Cat != "g" || event.Pid != 1 || event.Tid != r.Goroutine || event.Tid == 0 || event.Ts < 0 {
+			t.Errorf("Unexpected event:%+v of result:%+v", event, r)
+		}
+		if event.Args["graph"] != "g" || event.Args["result"] != resultName(r) {
+			t.Errorf("Unexpected args of %s:%v", event.Name, event.Args)
+		}
+	}
+	for _, id := range []string{"gen", "left", "right", "bad"} {
+		if event := events[id]; event.Phase != "X" || event.Dur <= 0 || len(event.Scope) > 0 {
+			t.Errorf("Expect complete event of %s, but got %+v", id, event)
+		}
+	}
+	gen, left, right, bad, after := events["gen"], events["left"], events["right"], events["bad"], events["after"]
+	if gen.Ts != 0 || !reflect.DeepEqual(gen.Args["data_sizes"], map[string]interface{}{"v": float64(8)}) {
+		t.Errorf("Expect the first event at 0 with data sizes, but got %+v", gen)
+	}
+	// left & right sleep concurrently on their own goroutines
+	if left.Dur < 20000 || right.Dur < 20000 || left.Tid == right.Tid {
+		t.Errorf("Expect concurrent events on different goroutines, but got %+v & %+v", left, right)
+	}
+	if bad.Ts+1 < left.Ts+left.Dur || bad.Ts+1 < right.Ts+right.Dur {
+		t.Errorf("Expect bad after its deps, but got %+v", bad)
+	}
+	if bad.Args["result"] != "err" || bad.Args["error"] != "[g/bad]fail" || bad.Args["attempts"] != float64(2) {
+		t.Errorf("Unexpected args of failed vertex:%v", bad.Args)
+	}
+	if after.Phase != "i" || after.Scope != "t" || after.Dur != 0 || after.Args["result"] != "skipped" {
+		t.Errorf("Expect thread scoped instant event of skipped vertex, but got %+v", after)
+	}
+
+	for _, r := range rs.Vertexs {
+		r.Start = time.Time{}
+	}
+	if _, err := rs.ChromeTrace(); nil == err || !strings.Contains(err.Error(), "No trace recorded") {
+		t.Errorf("Expect no trace error, but got:%v", err)
+	}
+}
+
+func TestDumpTraceDot(t *testing.T) {
+	cfg, rs := executeTraced(t)
+	dot := cfg.DumpTraceDot(rs, nil)
+	annotations := regexp.MustCompile(`(?m)^\s*(g_\w+) \[label="([^"]*)"(.*)\];$`)
+	annotated := make(map[string][2]string)
+	for _, match := range annotations.FindAllStringSubmatch(dot, -1) {
+		// traced annotations override the plain vertex definitions
+		annotated[match[1]] = [2]string{match[2], match[3]}
+	}
+	tests := []struct {
+		id     string
+		label  string
+		styles string
+	}{
+		{"g_gen", `^gen\\nok \S+$`, `^ style=filled fillcolor=palegreen penwidth=2$`},
+		{"g_left", `^left\\nok \S+$`, `^ style=filled fillcolor=palegreen penwidth=2$`},
+		{"g_bad", `^bad\\nerr \S+ x2$`, `^ style=filled fillcolor=salmon penwidth=2 tooltip="\[g/bad\]fail"$`},
+		{"g_after", `^after\\nskipped$`, `^ style="filled,dashed" color=gray fontcolor=gray fillcolor=whitesmoke$`},
+	}
+	for _, test := range tests {
+		got := annotated[test.id]
+		if !regexp.MustCompile(test.label).MatchString(got[0]) || !regexp.MustCompile(test.styles).MatchString(got[1]) {
+			t.Errorf("%s: unexpected annotation label:%s styles:%s", test.id, got[0], got[1])
+		}
+	}
+	// only edges between executed vertices are highlighted
+	for _, edge := range []string{"g_gen -> g_left", "g_right -> g_bad"} {
+		if !strings.Contains(dot, edge+" [style=bold label=\"all\" color=darkgreen penwidth=2];") {
+			t.Errorf("Expect highlighted edge:%s in\n%s", edge, dot)
+		}
+	}
+	if !strings.Contains(dot, "g_bad -> g_after [style=dashed label=\"ok\"];") {
+		t.Errorf("Expect plain edge to the skipped vertex in\n%s", dot)
+	}
+}
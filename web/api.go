@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+// MAX_REQUEST_BODY limits the body size of api requests, larger requests are rejected with 413.
+const MAX_REQUEST_BODY = 1 << 20
+
+type ScriptRequest struct {
+	Ops    string `json:"ops"`
+	Script string `json:"script"`
+}
+
+type RenderRequest struct {
+	ScriptRequest
+	// Format is one of dot/svg/png/mermaid/json, dot by default.
+	Format         string `json:"format"`
+	Graph          string `json:"graph"`
+	SubGraphDepth  int    `json:"sub_graph_depth"`
+	FocusVertex    string `json:"focus_vertex"`
+	FocusData      string `json:"focus_data"`
+	FocusDirection int    `json:"focus_direction"`
+}
+
+type DiffRequest struct {
+	Ops string `json:"ops"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+type ValidateResponse struct {
+	Ok          bool                 `json:"ok"`
+	Diagnostics []didagle.Diagnostic `json:"diagnostics"`
+}
+
+// RenderResponse carries text formats in Content, png in Data(base64 in json).
+type RenderResponse struct {
+	Format  string `json:"format"`
+	Content string `json:"content,omitempty"`
+	Data    []byte `json:"data,omitempty"`
+}
+
+type PlanResponse struct {
+	Plans []*didagle.GraphPlan `json:"plans"`
+}
+
+type LintResponse struct {
+	Issues []didagle.LintIssue `json:"issues"`
+}
+
+type DiffResponse struct {
+	Entries []didagle.DiffEntry `json:"entries"`
+}
+
+type ErrorResponse struct {
+	Error       string               `json:"error"`
+	Diagnostics []didagle.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if nil != err {
+		log.Printf("Failed to marshal response with err:%v", err)
+		status = http.StatusInternalServerError
+		b, _ = json.Marshal(&ErrorResponse{Error: err.Error()})
+	}
+	// headers must be set before WriteHeader
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &ErrorResponse{Error: err.Error()})
+}
+
+// decodeRequest decodes the json body into req, it writes the error response and returns false on failure.
+func decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method:%s not allowed", r.Method))
+		return false
+	}
+	if ct := r.Header.Get("Content-Type"); len(ct) > 0 && !strings.HasPrefix(ct, "application/json") {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("Unsupported content type:%s", ct))
+		return false
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_REQUEST_BODY))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); nil != err {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("Request body exceeds %d bytes", MAX_REQUEST_BODY))
+		} else {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid request json:%v", err))
+		}
+		return false
+	}
+	return true
+}
+
+// buildScript builds the script, it writes 422 with diagnostics and returns nil if the script is invalid.
+func buildScript(w http.ResponseWriter, ops string, script string) *didagle.DAGConfig {
+	if len(strings.TrimSpace(script)) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("Empty script"))
+		return nil
+	}
+	dag, err := didagle.NewDAGConfigByContent(ops, script)
+	if nil != err {
+		writeJSON(w, http.StatusUnprocessableEntity, &ErrorResponse{Error: err.Error(), Diagnostics: didagle.LocateError(script, err)})
+		return nil
+	}
+	return dag
+}
+
+func validateHandler(w http.ResponseWriter, r *http.Request) {
+	var req ScriptRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	rs := &ValidateResponse{Ok: true, Diagnostics: []didagle.Diagnostic{}}
+	dag, err := didagle.NewDAGConfigByContent(req.Ops, req.Script)
+	if nil != err {
+		rs.Ok = false
+		rs.Diagnostics = didagle.LocateError(req.Script, err)
+	} else {
+		rs.Diagnostics = append(rs.Diagnostics, dag.Warnings()...)
+	}
+	writeJSON(w, http.StatusOK, rs)
+}
+
+func renderHandler(w http.ResponseWriter, r *http.Request) {
+	var req RenderRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if len(req.Format) == 0 {
+		req.Format = "dot"
+	}
+	switch req.Format {
+	case "dot", "svg", "png", "mermaid", "json":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("Unsupported render format:%s", req.Format))
+		return
+	}
+	dag := buildScript(w, req.Ops, req.Script)
+	if nil == dag {
+		return
+	}
+	opt := &didagle.RenderOptions{
+		Graph:          req.Graph,
+		SubGraphDepth:  req.SubGraphDepth,
+		FocusVertex:    req.FocusVertex,
+		FocusData:      req.FocusData,
+		FocusDirection: req.FocusDirection,
+	}
+	if err := dag.VerifyRenderOptions(opt); nil != err {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	content, err := dag.Render(req.Format, opt)
+	if nil != err {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	rs := &RenderResponse{Format: req.Format}
+	if req.Format == "png" {
+		rs.Data = content
+	} else {
+		rs.Content = string(content)
+	}
+	writeJSON(w, http.StatusOK, rs)
+}
+
+func planHandler(w http.ResponseWriter, r *http.Request) {
+	var req ScriptRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	dag := buildScript(w, req.Ops, req.Script)
+	if nil == dag {
+		return
+	}
+	plans, err := dag.Plan()
+	if nil != err {
+		writeJSON(w, http.StatusUnprocessableEntity, &ErrorResponse{Error: err.Error(), Diagnostics: didagle.LocateError(req.Script, err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, &PlanResponse{Plans: plans})
+}
+
+func lintHandler(w http.ResponseWriter, r *http.Request) {
+	var req ScriptRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	dag := buildScript(w, req.Ops, req.Script)
+	if nil == dag {
+		return
+	}
+	rs := &LintResponse{Issues: dag.Lint()}
+	if nil == rs.Issues {
+		rs.Issues = []didagle.LintIssue{}
+	}
+	writeJSON(w, http.StatusOK, rs)
+}
+
+func diffHandler(w http.ResponseWriter, r *http.Request) {
+	var req DiffRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	from := buildScript(w, req.Ops, req.Old)
+	if nil == from {
+		return
+	}
+	to := buildScript(w, req.Ops, req.New)
+	if nil == to {
+		return
+	}
+	rs := &DiffResponse{Entries: from.Diff(to)}
+	if nil == rs.Entries {
+		rs.Entries = []didagle.DiffEntry{}
+	}
+	writeJSON(w, http.StatusOK, rs)
+}
+
+func registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/validate", validateHandler)
+	mux.HandleFunc("/api/v1/render", renderHandler)
+	mux.HandleFunc("/api/v1/plan", planHandler)
+	mux.HandleFunc("/api/v1/lint", lintHandler)
+	mux.HandleFunc("/api/v1/diff", diffHandler)
+	mux.Handle("/api/v1/live", liveHandler())
+	mux.HandleFunc("/api/v1/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		http.ServeFileFS(w, r, assets, "openapi.yaml")
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// postAPI posts the body to the api path, returns the status and the decoded json response.
+func postAPI(t *testing.T, base string, path string, contentType string, body string) (int, map[string]interface{}) {
+	t.Helper()
+	res, err := http.Post(base+path, contentType, strings.NewReader(body))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s: unexpected content type:%s", path, ct)
+	}
+	rs := make(map[string]interface{})
+	if err := json.NewDecoder(res.Body).Decode(&rs); nil != err {
+		t.Fatalf("%s: invalid json response:%v", path, err)
+	}
+	return res.StatusCode, rs
+}
+
+func scriptBody(script string) string {
+	b, _ := json.Marshal(&ScriptRequest{Script: script})
+	return string(b)
+}
+
+func mustJSON(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func TestAPIStatusCodes(t *testing.T) {
+	server := newTestServer(t)
+	valid := scriptBody(fmt.Sprintf(testScript, "main"))
+	invalid := scriptBody("[[graph]]\nname = \"main\"\n[[graph.vertex]]\nid = \"a\"\nprocessor = \"a\"\nsuccessor = [\"missing\"]\n")
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+		status      int
+		errMsg      string
+	}{
+		{"/api/v1/validate", "text/plain", valid, http.StatusUnsupportedMediaType, "Unsupported content type:text/plain"},
+		{"/api/v1/validate", "application/json", `{"script":`, http.StatusBadRequest, "Invalid request json"},
+		{"/api/v1/validate", "application/json", `{"scripts":""}`, http.StatusBadRequest, "unknown field"},
+		{"/api/v1/plan", "application/json", scriptBody(" \n"), http.StatusBadRequest, "Empty script"},
+		{"/api/v1/plan", "application/json", invalid, http.StatusUnprocessableEntity, "missing"},
+		{"/api/v1/lint", "application/json", invalid, http.StatusUnprocessableEntity, "missing"},
+		{"/api/v1/render", "application/json", `{"script":"x","format":"gif"}`, http.StatusBadRequest, "Unsupported render format:gif"},
+		{"/api/v1/render", "application/json", `{"script":` + mustJSON(fmt.Sprintf(testScript, "main")) + `,"graph":"nope"}`, http.StatusBadRequest, "No graph with name:nope"},
+		{"/api/v1/render", "application/json", `{"script":` + mustJSON(fmt.Sprintf(testScript, "main")) + `,"format":"png","focus_vertex":"nope"}`, http.StatusBadRequest, "No vertex:nope found"},
+		{"/api/v1/render", "application/json", `{"script":` + mustJSON(fmt.Sprintf(testScript, "main")) + `,"format":"svg","focus_direction":7}`, http.StatusBadRequest, "Invalid focus direction:7"},
+		{"/api/v1/render", "application/json", `{"script":` + mustJSON(fmt.Sprintf(testScript, "main")) + `,"format":"json","focus_vertex":"b"}`, http.StatusOK, ""},
+		{"/api/v1/diff", "application/json", `{"old":` + mustJSON(fmt.Sprintf(testScript, "main")) + `,"new":""}`, http.StatusBadRequest, "Empty script"},
+		{"/api/v1/validate", "", valid, http.StatusOK, ""},
+		{"/api/v1/plan", "application/json; charset=utf-8", valid, http.StatusOK, ""},
+		{"/api/v1/lint", "application/json", valid, http.StatusOK, ""},
+		{"/api/v1/render", "application/json", `{"script":` + mustJSON(fmt.Sprintf(testScript, "main")) + `,"format":"mermaid"}`, http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		status, rs := postAPI(t, server.URL, test.path, test.contentType, test.body)
+		if status != test.status {
+			t.Errorf("%s %s: expect status:%d, but got %d %v", test.path, test.body, test.status, status, rs)
+			continue
+		}
+		if msg, _ := rs["error"].(string); !strings.Contains(msg, test.errMsg) || (len(test.errMsg) == 0) != (len(msg) == 0) {
+			t.Errorf("%s %s: expect error:%s, but got %v", test.path, test.body, test.errMsg, rs)
+		}
+		if test.status == http.StatusUnprocessableEntity {
+			if diags, _ := rs["diagnostics"].([]interface{}); len(diags) == 0 || diags[0].(map[string]interface{})["line"] != float64(6) {
+				t.Errorf("%s: expect diagnostics located at line 6 in %v", test.path, rs)
+			}
+		}
+	}
+
+	// invalid scripts are not request errors for validate
+	status, rs := postAPI(t, server.URL, "/api/v1/validate", "application/json", invalid)
+	if diags, _ := rs["diagnostics"].([]interface{}); status != http.StatusOK || rs["ok"] != false || len(diags) == 0 {
+		t.Errorf("Unexpected validate response:%d %v", status, rs)
+	}
+	status, rs = postAPI(t, server.URL, "/api/v1/lint", "application/json", valid)
+	if issues, exist := rs["issues"].([]interface{}); status != http.StatusOK || !exist || nil == issues {
+		t.Errorf("Expect issues array, but got:%d %v", status, rs)
+	}
+	status, rs = postAPI(t, server.URL, "/api/v1/diff", "application/json", `{"old":`+mustJSON(fmt.Sprintf(testScript, "main"))+`,"new":`+mustJSON(fmt.Sprintf(testScript, "other"))+`}`)
+	if entries, _ := rs["entries"].([]interface{}); status != http.StatusOK || len(entries) == 0 {
+		t.Errorf("Expect diff entries, but got:%d %v", status, rs)
+	}
+	status, rs = postAPI(t, server.URL, "/api/v1/render", "application/json", valid)
+	if content, _ := rs["content"].(string); status != http.StatusOK || rs["format"] != "dot" || !strings.Contains(content, "digraph G") {
+		t.Errorf("Expect dot content, but got:%d %v", status, rs)
+	}
+
+	res, err := http.Get(server.URL + "/api/v1/validate")
+	if nil != err {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed || res.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("Expect 405 with Allow header, but got %d %v", res.StatusCode, res.Header)
+	}
+	res, err = http.Get(server.URL + "/api/v1/openapi.yaml")
+	if nil != err {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/yaml" {
+		t.Errorf("Unexpected openapi response:%d %v", res.StatusCode, res.Header)
+	}
+}
+
+func TestAPIRequestSizeLimit(t *testing.T) {
+	server := newTestServer(t)
+	body := scriptBody(strings.Repeat("#", MAX_REQUEST_BODY))
+	status, rs := postAPI(t, server.URL, "/api/v1/validate", "application/json", body)
+	if status != http.StatusRequestEntityTooLarge || rs["error"] != fmt.Sprintf("Request body exceeds %d bytes", MAX_REQUEST_BODY) {
+		t.Errorf("Expect 413, but got %d %v", status, rs)
+	}
+	// a body just under the limit is accepted
+	script := fmt.Sprintf(testScript, "main")
+	body = scriptBody(script + strings.Repeat("#", MAX_REQUEST_BODY-len(scriptBody(script))-1))
+	status, rs = postAPI(t, server.URL, "/api/v1/validate", "application/json", body)
+	if status != http.StatusOK || rs["ok"] != true {
+		t.Errorf("Expect 200 under the limit, but got %d %v", status, rs)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MAX_CACHED_IMAGES limits the rendered images kept in memory, the oldest image is evicted first.
+const MAX_CACHED_IMAGES = 256
+
+// imageCache keeps rendered images keyed by the sha256 of their content, so identical renders share one entry
+// and a path never changes its content.
+type imageCache struct {
+	mutex  sync.RWMutex
+	images map[string][]byte
+	order  []string
+}
+
+func newImageCache() *imageCache {
+	return &imageCache{images: make(map[string][]byte)}
+}
+
+// put stores the image and returns its content hash.
+func (p *imageCache) put(image []byte) string {
+	sum := sha256.Sum256(image)
+	hash := hex.EncodeToString(sum[:])
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, exist := p.images[hash]; exist {
+		return hash
+	}
+	if len(p.order) >= MAX_CACHED_IMAGES {
+		delete(p.images, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.images[hash] = image
+	p.order = append(p.order, hash)
+	return hash
+}
+
+func (p *imageCache) get(hash string) ([]byte, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	image, exist := p.images[hash]
+	return image, exist
+}
+
+// ServeHTTP serves '<hash>.png' with the hash as ETag. Images are rendered from posted scripts and may be
+// evicted, so clients revalidate on every use instead of caching them as immutable, a revalidation of a cached
+// image is answered by 304 while an evicted one is 404.
+func (p *imageCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hash := strings.TrimSuffix(r.URL.Path, ".png")
+	image, exist := p.get(hash)
+	if !exist {
+		http.NotFound(w, r)
+		return
+	}
+	etag := "\"" + hash + "\""
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(image)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testScript = `
+[[graph]]
+name = "%s"
+[[graph.vertex]]
+processor = "a"
+successor = ["b"]
+[[graph.vertex]]
+processor = "b"
+`
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ws, err := newWorkspace(t.TempDir())
+	if nil != err {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newMux(ws, newImageCache()))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func requireDot(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("dot"); nil != err {
+		t.Skip("graphviz 'dot' not found in PATH")
+	}
+}
+
+func TestGenPngParallel(t *testing.T) {
+	requireDot(t)
+	server := newTestServer(t)
+	const workers = 16
+	const requests = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*requests)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				// half of the workers render the same script to share cache entries
+				graph := fmt.Sprintf("g%d", worker%(workers/2))
+				if err := genAndFetchPng(server.URL, fmt.Sprintf(testScript, graph)); nil != err {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func genAndFetchPng(base string, script string) error {
+	res, err := http.PostForm(base+"/gen_png", url.Values{"script": {script}})
+	if nil != err {
+		return err
+	}
+	defer res.Body.Close()
+	var rs WebRes
+	if err := json.NewDecoder(res.Body).Decode(&rs); nil != err {
+		return err
+	}
+	if res.StatusCode != http.StatusOK || len(rs.Err) > 0 {
+		return fmt.Errorf("gen_png status:%d err:%s", res.StatusCode, rs.Err)
+	}
+	if !strings.HasPrefix(rs.Path, "/pngs/") || !strings.HasSuffix(rs.Path, ".png") {
+		return fmt.Errorf("Unexpected png path:%s", rs.Path)
+	}
+	png, err := http.Get(base + rs.Path)
+	if nil != err {
+		return err
+	}
+	defer png.Body.Close()
+	content, err := io.ReadAll(png.Body)
+	if nil != err {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	if png.StatusCode != http.StatusOK || rs.Path != "/pngs/"+hash+".png" {
+		return fmt.Errorf("png:%s status:%d content hash:%s", rs.Path, png.StatusCode, hash)
+	}
+	return nil
+}
+
+func TestImageCacheETag(t *testing.T) {
+	images := newImageCache()
+	hash := images.put([]byte("png"))
+	server := httptest.NewServer(http.StripPrefix("/pngs/", images))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/pngs/"+hash+".png", nil)
+	req.Header.Set("If-None-Match", "\""+hash+"\"")
+	res, err := http.DefaultClient.Do(req)
+	if nil != err {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("Expect 304, but got %d", res.StatusCode)
+	}
+	res, err = http.Get(server.URL + "/pngs/" + hash + ".png")
+	if nil != err {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(content) != "png" || res.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("Unexpected image status:%d content:%s type:%s", res.StatusCode, content, res.Header.Get("Content-Type"))
+	}
+	if res.Header.Get("ETag") != "\""+hash+"\"" || res.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("Unexpected cache headers:%v", res.Header)
+	}
+	res, err = http.Get(server.URL + "/pngs/nope.png")
+	if nil != err {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("Expect 404, but got %d", res.StatusCode)
+	}
+}
+
+func TestImageCacheEviction(t *testing.T) {
+	images := newImageCache()
+	first := images.put([]byte("0"))
+	if images.put([]byte("0")) != first {
+		t.Fatalf("Expect the same hash for the same content")
+	}
+	for i := 1; i <= MAX_CACHED_IMAGES; i++ {
+		images.put([]byte(fmt.Sprint(i)))
+	}
+	if _, exist := images.get(first); exist {
+		t.Errorf("Expect the oldest image evicted")
+	}
+	// a client revalidating the evicted image gets 404 instead of a stale 304
+	req := httptest.NewRequest(http.MethodGet, "/"+first+".png", nil)
+	req.Header.Set("If-None-Match", "\""+first+"\"")
+	w := httptest.NewRecorder()
+	images.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expect 404 for evicted image, but got %d", w.Code)
+	}
+	if len(images.images) != MAX_CACHED_IMAGES || len(images.order) != MAX_CACHED_IMAGES {
+		t.Errorf("Unexpected cache size:%d/%d", len(images.images), len(images.order))
+	}
+}
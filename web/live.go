@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/yinqiwen/go-didagle"
+	"golang.org/x/net/websocket"
+)
+
+// LIVE_DEBOUNCE is the quiet period after the last edit before validating, so only the latest edit of a burst
+// of keystrokes is built and rendered.
+const LIVE_DEBOUNCE = 300 * time.Millisecond
+
+// LiveEdit is sent by the editor on every change, Seq is echoed in the result to drop stale results.
+type LiveEdit struct {
+	Seq    int64  `json:"seq"`
+	Ops    string `json:"ops"`
+	Script string `json:"script"`
+}
+
+// LiveResult carries the diagnostics of the edit, and the rendered svg if the script is valid.
+// Error is set if the svg fails to render, diagnostics are still valid then.
+type LiveResult struct {
+	Seq         int64                `json:"seq"`
+	Ok          bool                 `json:"ok"`
+	Diagnostics []didagle.Diagnostic `json:"diagnostics"`
+	Svg         string               `json:"svg,omitempty"`
+	Error       string               `json:"error,omitempty"`
+}
+
+func liveValidate(edit *LiveEdit) *LiveResult {
+	rs := &LiveResult{Seq: edit.Seq, Ok: true, Diagnostics: []didagle.Diagnostic{}}
+	dag, err := didagle.NewDAGConfigByContent(edit.Ops, edit.Script)
+	if nil != err {
+		rs.Ok = false
+		rs.Diagnostics = didagle.LocateError(edit.Script, err)
+		return rs
+	}
+	rs.Diagnostics = append(rs.Diagnostics, dag.Warnings()...)
+	svg, err := dag.Render("svg", nil)
+	if nil != err {
+		rs.Error = err.Error()
+	} else {
+		rs.Svg = string(svg)
+	}
+	return rs
+}
+
+// serveLive reads edits from the connection and writes results from this goroutine only,
+// edits arriving within LIVE_DEBOUNCE replace the pending one.
+func serveLive(ws *websocket.Conn) {
+	ws.MaxPayloadBytes = MAX_REQUEST_BODY
+	edits := make(chan *LiveEdit)
+	done := make(chan struct{})
+	go func() {
+		defer close(edits)
+		for {
+			edit := &LiveEdit{}
+			if err := websocket.JSON.Receive(ws, edit); nil != err {
+				return
+			}
+			select {
+			case edits <- edit:
+			case <-done:
+				return
+			}
+		}
+	}()
+	defer close(done)
+	var pending *LiveEdit
+	timer := time.NewTimer(LIVE_DEBOUNCE)
+	timer.Stop()
+	for {
+		select {
+		case edit, ok := <-edits:
+			if !ok {
+				return
+			}
+			pending = edit
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(LIVE_DEBOUNCE)
+		case <-timer.C:
+			if nil == pending {
+				continue
+			}
+			if err := websocket.JSON.Send(ws, liveValidate(pending)); nil != err {
+				log.Printf("Failed to send live result with err:%v", err)
+				return
+			}
+			pending = nil
+		}
+	}
+}
+
+// checkSameOrigin rejects cross site connections, browsers don't apply CORS to websockets.
+func checkSameOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	if nil != err || origin.Host != r.Host {
+		return fmt.Errorf("Cross origin websocket from:%s", r.Header.Get("Origin"))
+	}
+	config.Origin = origin
+	return nil
+}
+
+func liveHandler() http.Handler {
+	return websocket.Server{Handler: serveLive, Handshake: checkSameOrigin}
+}
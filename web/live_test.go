@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func dialLive(t *testing.T, base string, origin string) (*websocket.Conn, error) {
+	t.Helper()
+	return websocket.Dial("ws"+strings.TrimPrefix(base, "http")+"/api/v1/live", "", origin)
+}
+
+// receiveLive returns the next live result, nil if none arrives within the timeout.
+func receiveLive(t *testing.T, ws *websocket.Conn, timeout time.Duration) *LiveResult {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(timeout))
+	rs := &LiveResult{}
+	if err := websocket.JSON.Receive(ws, rs); nil != err {
+		if strings.Contains(err.Error(), "timeout") {
+			return nil
+		}
+		t.Fatalf("Failed to receive live result with err:%v", err)
+	}
+	return rs
+}
+
+func TestLiveDebounce(t *testing.T) {
+	server := newTestServer(t)
+	ws, err := dialLive(t, server.URL, server.URL)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	// a burst of edits is validated once with the latest edit
+	for seq := int64(1); seq <= 5; seq++ {
+		script := fmt.Sprintf(testScript, "main")
+		if seq < 5 {
+			script = "[[graph]"
+		}
+		if err := websocket.JSON.Send(ws, &LiveEdit{Seq: seq, Script: script}); nil != err {
+			t.Fatal(err)
+		}
+		time.Sleep(LIVE_DEBOUNCE / 10)
+	}
+	rs := receiveLive(t, ws, 10*LIVE_DEBOUNCE)
+	if nil == rs || rs.Seq != 5 || !rs.Ok || len(rs.Diagnostics) != 0 {
+		t.Fatalf("Expect the valid result of the last edit, but got %+v", rs)
+	}
+	if len(rs.Svg) == 0 && len(rs.Error) == 0 {
+		t.Errorf("Expect svg or render error, but got %+v", rs)
+	}
+	if rs := receiveLive(t, ws, 2*LIVE_DEBOUNCE); nil != rs {
+		t.Fatalf("Expect no result for debounced edits, but got seq:%d", rs.Seq)
+	}
+
+	// edits separated by the quiet period are all validated
+	websocket.JSON.Send(ws, &LiveEdit{Seq: 6, Script: "[[graph]"})
+	rs = receiveLive(t, ws, 10*LIVE_DEBOUNCE)
+	if nil == rs || rs.Seq != 6 || rs.Ok || len(rs.Diagnostics) == 0 || rs.Diagnostics[0].Line != 1 {
+		t.Fatalf("Expect located diagnostics of the invalid edit, but got %+v", rs)
+	}
+	// build errors are located at the offending value
+	websocket.JSON.Send(ws, &LiveEdit{Seq: 7, Script: "[[graph]]\nname = \"main\"\n[[graph.vertex]]\nid = \"a\"\nprocessor = \"a\"\ndeps = [\"missing\"]\n"})
+	rs = receiveLive(t, ws, 10*LIVE_DEBOUNCE)
+	if nil == rs || rs.Seq != 7 || rs.Ok || len(rs.Diagnostics) != 1 || rs.Diagnostics[0].Line != 6 || rs.Diagnostics[0].Column != 9 {
+		t.Fatalf("Expect build error located at the dep, but got %+v", rs)
+	}
+	websocket.JSON.Send(ws, &LiveEdit{Seq: 8, Script: fmt.Sprintf(testScript, "main")})
+	if rs := receiveLive(t, ws, 10*LIVE_DEBOUNCE); nil == rs || rs.Seq != 8 || !rs.Ok {
+		t.Fatalf("Expect the result of the next edit, but got %+v", rs)
+	}
+}
+
+func TestLiveCrossOrigin(t *testing.T) {
+	server := newTestServer(t)
+	if ws, err := dialLive(t, server.URL, "http://evil.example.com"); nil == err {
+		ws.Close()
+		t.Errorf("Expect cross origin connection rejected")
+	}
+}
@@ -1,109 +1,81 @@
 package main
 
 import (
+	"embed"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"html/template"
-	"io/ioutil"
 	"log"
 	"net/http"
 
 	"github.com/yinqiwen/go-didagle"
 )
 
-type Page struct {
-	Title string
-	Body  []byte
-}
-
-func (p *Page) save() error {
-	filename := p.Title + ".txt"
-	return ioutil.WriteFile(filename, p.Body, 0600)
-}
+//go:embed edit.html openapi.yaml
+var assets embed.FS
 
-func loadPage(title string) (*Page, error) {
-	filename := title + ".txt"
-	body, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-	return &Page{Title: title, Body: body}, nil
-}
-
-func renderTemplate(w http.ResponseWriter, tmpl string, p *Page) {
-	t, _ := template.ParseFiles(tmpl + ".html")
-	t.Execute(w, p)
+type WebRes struct {
+	Path string `json:",omitempty"`
+	Err  string `json:",omitempty"`
 }
 
-func viewHandler(w http.ResponseWriter, r *http.Request) {
-	title := r.URL.Path[len("/view/"):]
-	p, _ := loadPage(title)
-	renderTemplate(w, "view", p)
+func writeWebRes(w http.ResponseWriter, status int, rs *WebRes) {
+	b, _ := json.Marshal(rs)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
 }
 
-func editHandler(w http.ResponseWriter, r *http.Request) {
-	title := r.URL.Path[len("/edit/"):]
-	p, err := loadPage(title)
-	if err != nil {
-		p = &Page{Title: title}
+// genPngHandler renders the form script in memory, the png is served by its content hash.
+func genPngHandler(w http.ResponseWriter, r *http.Request, images *imageCache) {
+	r.ParseForm()
+	ops := r.FormValue("ops")
+	script := r.FormValue("script")
+	dag, err := didagle.NewDAGConfigByContent(ops, script)
+	if nil != err {
+		writeWebRes(w, http.StatusBadRequest, &WebRes{Err: fmt.Sprintf("%v", err)})
+		return
 	}
-	renderTemplate(w, "edit", p)
-}
-
-type WebRes struct {
-	Path string `json:",omitempty"`
-	Err  string `json:",omitempty"`
+	png, err := dag.Render("png", nil)
+	if nil != err {
+		log.Printf("Error:%v", err)
+		writeWebRes(w, http.StatusInternalServerError, &WebRes{Err: fmt.Sprintf("%v", err)})
+		return
+	}
+	rs := &WebRes{Path: "/pngs/" + images.put(png) + ".png"}
+	writeWebRes(w, http.StatusOK, rs)
+	log.Printf("Response:%v", rs.Path)
 }
 
-func main() {
-	log.Printf("Start web server")
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "edit.html")
-	})
-	http.Handle("/pngs/", http.StripPrefix("/pngs/", http.FileServer(http.Dir("./pngs"))))
-	var cursor int64
-	http.HandleFunc("/gen_png", func(w http.ResponseWriter, r *http.Request) {
-		r.ParseForm()
-		ops := r.FormValue("ops")
-		script := r.FormValue("script")
-		// log.Printf("Receive form ops:%s", ops)
-		// log.Printf("Receive form script:%s", script)
-		dag, err := didagle.NewDAGConfigByContent(ops, script)
-		rs := &WebRes{}
-		if nil != err {
-			rs.Err = fmt.Sprintf("%v", err)
-			b, _ := json.Marshal(rs)
-			w.WriteHeader(400)
-			w.Header().Set("Content-Type", "application/json")
-			w.Write(b)
+// newMux registers the editor, the api, the workspace and the png handlers.
+func newMux(ws *workspace, images *imageCache) *http.ServeMux {
+	mux := http.NewServeMux()
+	registerAPI(mux)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
 			return
 		}
-		path := fmt.Sprintf("/pngs/%d", cursor)
-		err = dag.GenPng("." + path)
-		if nil != err {
-			log.Printf("Error:%v", err)
-			rs.Err = fmt.Sprintf("%v", err)
-			b, _ := json.Marshal(rs)
-			w.WriteHeader(400)
-			w.Header().Set("Content-Type", "application/json")
-			w.Write(b)
-			return
-		}
-		png := path + ".png"
-
-		rs.Path = png
-		//w.WriteHeader(200)
-		b, _ := json.Marshal(rs)
-
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(b)
-		log.Printf("Response:%v", string(b))
-		cursor++
-		if 100 == cursor {
-			cursor = 0
-		}
+		http.ServeFileFS(w, r, assets, "edit.html")
 	})
+	mux.Handle("/api/v1/scripts/", http.StripPrefix("/api/v1/scripts", ws))
+	mux.Handle("/p/", http.StripPrefix("/p", http.HandlerFunc(ws.permalinkHandler)))
+	mux.Handle("/pngs/", http.StripPrefix("/pngs/", images))
+	mux.HandleFunc("/gen_png", func(w http.ResponseWriter, r *http.Request) {
+		genPngHandler(w, r, images)
+	})
+	return mux
+}
 
-	log.Fatal(http.ListenAndServe(":8080", nil))
-
+func main() {
+	listen := flag.String("listen", ":8080", "Address to listen")
+	dataDir := flag.String("data-dir", DEFAULT_WORKSPACE_DIR, "Directory to save scripts")
+	flag.Parse()
+	ws, err := newWorkspace(*dataDir)
+	if nil != err {
+		log.Fatal(err)
+	}
+	mux := newMux(ws, newImageCache())
+	log.Printf("Start web server on %s, scripts saved in %s", *listen, *dataDir)
+	log.Fatal(http.ListenAndServe(*listen, mux))
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedAssets(t *testing.T) {
+	server := newTestServer(t)
+	res, err := http.Get(server.URL + "/")
+	if nil != err {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(page), "<script>") {
+		t.Fatalf("Unexpected editor page:%d %v", res.StatusCode, res.Header)
+	}
+	// the editor works offline, no script/style is loaded from other sites
+	if external := regexp.MustCompile(`(src|href)\s*=\s*["']?(https?:)?//`).FindString(string(page)); len(external) > 0 {
+		t.Errorf("Unexpected external asset:%s", external)
+	}
+	for _, path := range []string{"/edit.html", "/openapi.yaml", "/nope"} {
+		res, err := http.Get(server.URL + path)
+		if nil != err {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expect 404, but got %d", path, res.StatusCode)
+		}
+	}
+}
+
+func TestGenPngInvalidScript(t *testing.T) {
+	server := newTestServer(t)
+	res, err := http.PostForm(server.URL+"/gen_png", url.Values{"script": {"[[graph]"}})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var rs WebRes
+	if err := json.NewDecoder(res.Body).Decode(&rs); nil != err {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadRequest || len(rs.Err) == 0 || len(rs.Path) > 0 {
+		t.Errorf("Unexpected response:%d %+v", res.StatusCode, rs)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yinqiwen/go-didagle"
+)
+
+// DEFAULT_WORKSPACE_DIR is the directory saving scripts, relative to the working dir.
+const DEFAULT_WORKSPACE_DIR = "workspace"
+
+// scriptNameRegex matches names usable as a directory, a leading '.' is rejected so '.' & '..' never escape the
+// workspace dir.
+var scriptNameRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,63}$`)
+
+// Revision is an immutable saved version of a named op meta + script pair, Ops & Script are omitted in listings.
+type Revision struct {
+	Name      string    `json:"name"`
+	Rev       int       `json:"rev"`
+	Time      time.Time `json:"time"`
+	Message   string    `json:"message,omitempty"`
+	Ops       string    `json:"ops,omitempty"`
+	Script    string    `json:"script,omitempty"`
+	Permalink string    `json:"permalink"`
+}
+
+type SaveRequest struct {
+	Ops     string `json:"ops"`
+	Script  string `json:"script"`
+	Message string `json:"message"`
+}
+
+// workspace stores revisions as '<dir>/<name>/<rev>.json', revisions are numbered from 1 and never overwritten.
+type workspace struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+func newWorkspace(dir string) (*workspace, error) {
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return nil, fmt.Errorf("Failed to create workspace dir:%s with err:%v", dir, err)
+	}
+	return &workspace{dir: dir}, nil
+}
+
+func permalink(name string, rev int) string {
+	return fmt.Sprintf("/p/%s/%d.png", name, rev)
+}
+
+// revisions returns the sorted revision numbers of the script.
+func (p *workspace) revisions(name string) ([]int, error) {
+	files, err := ioutil.ReadDir(filepath.Join(p.dir, name))
+	if nil != err {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var revs []int
+	for _, file := range files {
+		if rev, err := strconv.Atoi(strings.TrimSuffix(file.Name(), ".json")); nil == err && rev > 0 {
+			revs = append(revs, rev)
+		}
+	}
+	sort.Ints(revs)
+	return revs, nil
+}
+
+func (p *workspace) load(name string, rev int) (*Revision, error) {
+	content, err := ioutil.ReadFile(filepath.Join(p.dir, name, strconv.Itoa(rev)+".json"))
+	if nil != err {
+		return nil, err
+	}
+	r := &Revision{}
+	if err := json.Unmarshal(content, r); nil != err {
+		return nil, fmt.Errorf("Invalid revision:%s/%d with err:%v", name, rev, err)
+	}
+	r.Permalink = permalink(r.Name, r.Rev)
+	return r, nil
+}
+
+func (p *workspace) save(name string, req *SaveRequest) (*Revision, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	revs, err := p.revisions(name)
+	if nil != err {
+		return nil, err
+	}
+	r := &Revision{Name: name, Rev: 1, Time: time.Now(), Message: req.Message, Ops: req.Ops, Script: req.Script}
+	if len(revs) > 0 {
+		r.Rev = revs[len(revs)-1] + 1
+	}
+	if err := os.MkdirAll(filepath.Join(p.dir, name), 0755); nil != err {
+		return nil, err
+	}
+	content, _ := json.MarshalIndent(r, "", "  ")
+	// O_EXCL keeps revisions immutable even if another process shares the dir
+	file, err := os.OpenFile(filepath.Join(p.dir, name, strconv.Itoa(r.Rev)+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if nil != err {
+		return nil, err
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); nil == err {
+		err = closeErr
+	}
+	if nil != err {
+		return nil, err
+	}
+	r.Permalink = permalink(name, r.Rev)
+	return r, nil
+}
+
+func (p *workspace) list() ([]*Revision, error) {
+	files, err := ioutil.ReadDir(p.dir)
+	if nil != err {
+		return nil, err
+	}
+	latest := []*Revision{}
+	for _, file := range files {
+		if !file.IsDir() || !scriptNameRegex.MatchString(file.Name()) {
+			continue
+		}
+		revs, err := p.revisions(file.Name())
+		if nil != err || len(revs) == 0 {
+			continue
+		}
+		r, err := p.load(file.Name(), revs[len(revs)-1])
+		if nil != err {
+			log.Printf("Failed to load script:%s with err:%v", file.Name(), err)
+			continue
+		}
+		r.Ops, r.Script = "", ""
+		latest = append(latest, r)
+	}
+	return latest, nil
+}
+
+// parseScriptPath splits 'name[/revisions[/rev]]', rev is 0 if absent and -1 if invalid.
+func parseScriptPath(path string) (name string, history bool, rev int) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	name = parts[0]
+	if !scriptNameRegex.MatchString(name) || len(parts) > 3 || (len(parts) > 1 && parts[1] != "revisions") {
+		return "", false, -1
+	}
+	history = len(parts) == 2
+	if len(parts) == 3 {
+		n, err := strconv.Atoi(parts[2])
+		if nil != err || n <= 0 {
+			return "", false, -1
+		}
+		rev = n
+	}
+	return name, history, rev
+}
+
+// ServeHTTP serves the workspace api under '/api/v1/scripts':
+//
+//	GET  /                            latest revision of every script
+//	GET  /<name>                      latest revision
+//	POST /<name>                      save a new revision
+//	GET  /<name>/revisions            history, oldest first
+//	GET  /<name>/revisions/<rev>      the revision
+func (p *workspace) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(strings.Trim(r.URL.Path, "/")) == 0 {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method:%s not allowed", r.Method))
+			return
+		}
+		scripts, err := p.list()
+		if nil != err {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, scripts)
+		return
+	}
+	name, history, rev := parseScriptPath(r.URL.Path)
+	if rev < 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Invalid script path:%s", r.URL.Path))
+		return
+	}
+	if r.Method == http.MethodPost && !history && rev == 0 {
+		var req SaveRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if len(strings.TrimSpace(req.Script)) == 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Empty script"))
+			return
+		}
+		saved, err := p.save(name, &req)
+		if nil != err {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		saved.Ops, saved.Script = "", ""
+		writeJSON(w, http.StatusCreated, saved)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method:%s not allowed", r.Method))
+		return
+	}
+	revs, err := p.revisions(name)
+	if nil != err {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(revs) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("No script:%s found", name))
+		return
+	}
+	if history {
+		all := make([]*Revision, 0, len(revs))
+		for _, n := range revs {
+			if saved, err := p.load(name, n); nil == err {
+				saved.Ops, saved.Script = "", ""
+				all = append(all, saved)
+			}
+		}
+		writeJSON(w, http.StatusOK, all)
+		return
+	}
+	if rev == 0 {
+		rev = revs[len(revs)-1]
+	}
+	saved, err := p.load(name, rev)
+	if nil != err {
+		writeError(w, http.StatusNotFound, fmt.Errorf("No revision:%d of script:%s found", rev, name))
+		return
+	}
+	writeJSON(w, http.StatusOK, saved)
+}
+
+// permalinkHandler renders '/p/<name>/<rev>.png|svg', a revision never changes so the image is cached forever.
+func (p *workspace) permalinkHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || !scriptNameRegex.MatchString(parts[0]) {
+		http.NotFound(w, r)
+		return
+	}
+	format := "png"
+	if ext := filepath.Ext(parts[1]); len(ext) > 0 {
+		format = ext[1:]
+	}
+	rev, err := strconv.Atoi(strings.TrimSuffix(parts[1], filepath.Ext(parts[1])))
+	if nil != err || (format != "png" && format != "svg") {
+		http.NotFound(w, r)
+		return
+	}
+	saved, err := p.load(parts[0], rev)
+	if nil != err {
+		http.NotFound(w, r)
+		return
+	}
+	dag, err := didagle.NewDAGConfigByContent(saved.Ops, saved.Script)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	image, err := dag.Render(format, nil)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+	}
+	w.Write(image)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseScriptPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		name    string
+		history bool
+		rev     int
+	}{
+		{"/a", "a", false, 0},
+		{"/a_b-1.toml/", "a_b-1.toml", false, 0},
+		{"/a/revisions", "a", true, 0},
+		{"/a/revisions/12", "a", false, 12},
+		{"/" + strings.Repeat("x", 64), strings.Repeat("x", 64), false, 0},
+		{"/" + strings.Repeat("x", 65), "", false, -1},
+		{"/.", "", false, -1},
+		{"/..", "", false, -1},
+		{"/.hidden", "", false, -1},
+		{"/a b", "", false, -1},
+		{"/a/history", "", false, -1},
+		{"/a/revisions/0", "", false, -1},
+		{"/a/revisions/x", "", false, -1},
+		{"/a/revisions/1/png", "", false, -1},
+	}
+	for _, test := range tests {
+		name, history, rev := parseScriptPath(test.path)
+		if name != test.name || history != test.history || rev != test.rev {
+			t.Errorf("%s: expect %s/%v/%d, but got %s/%v/%d", test.path, test.name, test.history, test.rev, name, history, rev)
+		}
+	}
+}
+
+// doWorkspace sends the request to the workspace handler, decodes the json response into rs if not nil.
+func doWorkspace(t *testing.T, ws *workspace, method string, path string, body string, rs interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	ws.ServeHTTP(w, req)
+	if nil != rs {
+		if err := json.Unmarshal(w.Body.Bytes(), rs); nil != err {
+			t.Fatalf("%s %s: invalid json response:%v %s", method, path, err, w.Body.String())
+		}
+	}
+	return w.Code
+}
+
+func TestWorkspaceRevisions(t *testing.T) {
+	dir := t.TempDir()
+	ws, err := newWorkspace(dir)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		var saved Revision
+		body := fmt.Sprintf(`{"script":%q,"message":"v%d"}`, fmt.Sprintf(testScript, fmt.Sprint("g", i)), i)
+		if code := doWorkspace(t, ws, http.MethodPost, "/demo", body, &saved); code != http.StatusCreated {
+			t.Fatalf("Expect 201, but got %d", code)
+		}
+		if saved.Rev != i || saved.Name != "demo" || saved.Permalink != fmt.Sprintf("/p/demo/%d.png", i) || len(saved.Script) > 0 {
+			t.Errorf("Unexpected saved revision:%+v", saved)
+		}
+	}
+	// files not named by revision numbers are ignored
+	ioutil.WriteFile(filepath.Join(dir, "demo", "notes.txt"), []byte("x"), 0644)
+
+	var latest Revision
+	if code := doWorkspace(t, ws, http.MethodGet, "/demo", "", &latest); code != http.StatusOK || latest.Rev != 2 || !strings.Contains(latest.Script, "g2") {
+		t.Errorf("Unexpected latest revision:%d %+v", code, latest)
+	}
+	var first Revision
+	if code := doWorkspace(t, ws, http.MethodGet, "/demo/revisions/1", "", &first); code != http.StatusOK || first.Rev != 1 || first.Message != "v1" || !strings.Contains(first.Script, "g1") {
+		t.Errorf("Unexpected first revision:%d %+v", code, first)
+	}
+	var history []Revision
+	if code := doWorkspace(t, ws, http.MethodGet, "/demo/revisions", "", &history); code != http.StatusOK || len(history) != 2 ||
+		history[0].Rev != 1 || history[1].Rev != 2 || len(history[0].Script) > 0 {
+		t.Errorf("Unexpected history:%d %+v", code, history)
+	}
+	var scripts []Revision
+	if code := doWorkspace(t, ws, http.MethodGet, "/", "", &scripts); code != http.StatusOK || len(scripts) != 1 || scripts[0].Rev != 2 || len(scripts[0].Script) > 0 {
+		t.Errorf("Unexpected scripts:%d %+v", code, scripts)
+	}
+	// revisions are never overwritten
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "demo", "1.json"))
+	if !strings.Contains(string(content), "g1") {
+		t.Errorf("Unexpected revision file:%s", content)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "/demo/revisions/3", "", http.StatusNotFound},
+		{http.MethodGet, "/nope", "", http.StatusNotFound},
+		{http.MethodGet, "/nope/revisions", "", http.StatusNotFound},
+		{http.MethodGet, "/demo/history", "", http.StatusNotFound},
+		{http.MethodPost, "/.hidden", `{"script":"x"}`, http.StatusNotFound},
+		{http.MethodPost, "/demo", `{"script":" "}`, http.StatusBadRequest},
+		{http.MethodPost, "/demo", `{"scripts":"x"}`, http.StatusBadRequest},
+		{http.MethodPost, "/demo/revisions/1", `{"script":"x"}`, http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/demo", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/", `{"script":"x"}`, http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		var rs ErrorResponse
+		if code := doWorkspace(t, ws, test.method, test.path, test.body, &rs); code != test.status || len(rs.Error) == 0 {
+			t.Errorf("%s %s: expect status:%d with error, but got %d %+v", test.method, test.path, test.status, code, rs)
+		}
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expect only the demo script saved, but got %d entries", len(files))
+	}
+}
+
+func TestWorkspacePermalink(t *testing.T) {
+	ws, err := newWorkspace(t.TempDir())
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := ws.save("demo", &SaveRequest{Script: fmt.Sprintf(testScript, "main")}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := ws.save("bad", &SaveRequest{Script: "[[graph]]\nname = \"g\"\n[[graph.vertex]]\nsuccessor = [\"x\"]\n"}); nil != err {
+		t.Fatal(err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ws.permalinkHandler(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	for path, status := range map[string]int{
+		"/demo/1.gif":    http.StatusNotFound,
+		"/demo/2.png":    http.StatusNotFound,
+		"/demo/x.png":    http.StatusNotFound,
+		"/../demo/1.png": http.StatusNotFound,
+		"/demo":          http.StatusNotFound,
+		"/bad/1.png":     http.StatusUnprocessableEntity,
+	} {
+		if w := get(path); w.Code != status {
+			t.Errorf("%s: expect status:%d, but got %d %s", path, status, w.Code, w.Body.String())
+		}
+	}
+
+	requireDot(t)
+	for path, contentType := range map[string]string{"/demo/1.png": "image/png", "/demo/1.svg": "image/svg+xml"} {
+		w := get(path)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != contentType || w.Body.Len() == 0 {
+			t.Errorf("%s: unexpected response:%d %v", path, w.Code, w.Header())
+		}
+	}
+}